@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/layer-3/nitewatch/config"
+	"github.com/layer-3/nitewatch/service"
+)
+
+const circuitBreakerUsage = "usage: nitewatch circuit-breaker status | pause [--operator <name>] <reason> | resume [--operator <name>]"
+
+// runCircuitBreaker shows, pauses and resumes a running worker through its
+// admin API. The operator defaults to $USER.
+func runCircuitBreaker(conf *config.Config, args []string) error {
+	client, err := newAdminClient(conf)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New(circuitBreakerUsage)
+	}
+	cmd, args := args[0], args[1:]
+	operator := os.Getenv("USER")
+	if len(args) >= 2 && args[0] == "--operator" {
+		operator, args = args[1], args[2:]
+	}
+
+	var state service.PauseState
+	switch {
+	case cmd == "status" && len(args) == 0:
+		if err := client.do(http.MethodGet, "/admin/pause", nil, &state); err != nil {
+			return err
+		}
+	case cmd == "pause" && len(args) > 0:
+		req := map[string]string{"operator": operator, "reason": strings.Join(args, " ")}
+		if err := client.do(http.MethodPost, "/admin/pause", req, &state); err != nil {
+			return err
+		}
+	case cmd == "resume" && len(args) == 0:
+		req := map[string]string{"operator": operator}
+		if err := client.do(http.MethodPost, "/admin/resume", req, &state); err != nil {
+			return err
+		}
+	default:
+		return errors.New(circuitBreakerUsage)
+	}
+
+	if state.Paused {
+		fmt.Printf("paused by %s since %s: %s\n", state.By, state.Since.Format(time.RFC3339), state.Reason)
+	} else if state.By != "" {
+		fmt.Printf("running, resumed by %s at %s\n", state.By, state.Since.Format(time.RFC3339))
+	} else {
+		fmt.Println("running")
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/layer-3/nitewatch/config"
+	"github.com/layer-3/nitewatch/service"
+)
+
+const deadLettersUsage = "usage: nitewatch dead-letters list [--all] | replay [--chain <name>] <id>"
+
+// runDeadLetters inspects and replays dead letters through the admin API of
+// a running worker.
+func runDeadLetters(conf *config.Config, args []string) error {
+	client, err := newAdminClient(conf)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) >= 1 && args[0] == "list":
+		all := len(args) == 2 && args[1] == "--all"
+		if len(args) > 2 || (len(args) == 2 && !all) {
+			return errors.New(deadLettersUsage)
+		}
+		path := "/admin/dead-letters"
+		if all {
+			path += "?all=true"
+		}
+		var letters []service.DeadLetter
+		if err := client.do(http.MethodGet, path, nil, &letters); err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "CHAIN\tID\tSTREAM\tBLOCK\tTX\tLOG\tREPLAYED\tREASON")
+		for _, l := range letters {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t%d\t%t\t%s\n", l.Chain, l.ID, l.Stream, l.BlockNumber, l.TxHash, l.LogIndex, l.Replayed, l.Reason)
+		}
+		return w.Flush()
+	case len(args) >= 2 && args[0] == "replay":
+		var chain string
+		if len(args) == 4 && args[1] == "--chain" {
+			chain, args = args[2], args[2:]
+		}
+		if len(args) != 2 {
+			return errors.New(deadLettersUsage)
+		}
+		id, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid dead letter id: %s", args[1])
+		}
+		var resp struct {
+			Event string `json:"event"`
+		}
+		path := fmt.Sprintf("/admin/dead-letters/%d/replay", id)
+		if chain != "" {
+			path += "?chain=" + url.QueryEscape(chain)
+		}
+		if err := client.do(http.MethodPost, path, nil, &resp); err != nil {
+			return err
+		}
+		fmt.Printf("replayed dead letter %d (%s)\n", id, resp.Event)
+		return nil
+	default:
+		return errors.New(deadLettersUsage)
+	}
+}
+
+// adminURL returns the base URL of the worker's admin API: NITEWATCH_ADMIN_URL
+// if set, otherwise listen_addr on the local host.
+func adminURL(listenAddr string) string {
+	if u := os.Getenv("NITEWATCH_ADMIN_URL"); u != "" {
+		return u
+	}
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "http://" + listenAddr
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// newAdminClient returns a client of the admin API of the configured worker.
+func newAdminClient(conf *config.Config) (*adminClient, error) {
+	if conf.AdminToken == "" {
+		return nil, errors.New("admin_token is not configured")
+	}
+	return &adminClient{
+		baseURL: adminURL(conf.ListenAddr),
+		token:   conf.AdminToken,
+		http:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+type adminClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// do sends a request to the admin API, with in as JSON body unless nil, and
+// decodes the response into out.
+func (c *adminClient) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("admin API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read admin API response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("admin API: %s", apiErr.Error)
+		}
+		return fmt.Errorf("admin API: %s", resp.Status)
+	}
+	return json.Unmarshal(respBody, out)
+}
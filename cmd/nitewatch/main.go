@@ -14,8 +14,8 @@ import (
 )
 
 func main() {
-	if len(os.Args) < 2 || os.Args[1] != "worker" {
-		fmt.Fprintln(os.Stderr, "usage: nitewatch worker")
+	if len(os.Args) < 2 || (os.Args[1] != "worker" && os.Args[1] != "dead-letters" && os.Args[1] != "circuit-breaker") {
+		fmt.Fprintln(os.Stderr, "usage: nitewatch worker | dead-letters | circuit-breaker")
 		os.Exit(1)
 	}
 
@@ -25,7 +25,22 @@ func main() {
 		os.Exit(1)
 	}
 
-	if conf.Blockchain.PrivateKey == "" {
+	if os.Args[1] == "dead-letters" {
+		if err := runDeadLetters(conf, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if os.Args[1] == "circuit-breaker" {
+		if err := runCircuitBreaker(conf, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if missing := missingKeys(conf); len(missing) > 0 {
 		fmt.Print("Enter private key: ")
 		bytePassword, err := term.ReadPassword(int(syscall.Stdin))
 		if err != nil {
@@ -33,11 +48,14 @@ func main() {
 			os.Exit(1)
 		}
 		fmt.Println() // Print newline after input
-		conf.Blockchain.PrivateKey = strings.TrimSpace(string(bytePassword))
-		if conf.Blockchain.PrivateKey == "" {
+		privateKey := strings.TrimSpace(string(bytePassword))
+		if privateKey == "" {
 			slog.Error("Private key cannot be empty")
 			os.Exit(1)
 		}
+		for _, bc := range missing {
+			bc.PrivateKey = privateKey
+		}
 	}
 
 	svc, err := service.New(*conf)
@@ -52,6 +70,32 @@ func main() {
 	}
 }
 
+// missingKeys returns the blockchain sections whose private key is needed,
+// because a contract has no signer key of its own, but not configured.
+func missingKeys(conf *config.Config) []*config.BlockchainConfig {
+	sections := []*config.BlockchainConfig{&conf.Blockchain}
+	if len(conf.Chains) > 0 {
+		sections = sections[:0]
+		for i := range conf.Chains {
+			sections = append(sections, &conf.Chains[i].Blockchain)
+		}
+	}
+
+	var missing []*config.BlockchainConfig
+	for _, bc := range sections {
+		if bc.PrivateKey != "" {
+			continue
+		}
+		for _, contract := range bc.CustodyContracts() {
+			if contract.PrivateKey == "" {
+				missing = append(missing, bc)
+				break
+			}
+		}
+	}
+	return missing
+}
+
 func loadConfig() (*config.Config, error) {
 	if raw := os.Getenv("NITEWATCH_CONFIG"); raw != "" {
 		return config.LoadFromEnv(raw)
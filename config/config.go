@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,44 +18,634 @@ type Config struct {
 	Blockchain       BlockchainConfig        `yaml:"blockchain"`
 	Limits           LimitsConfig            `yaml:"limits"`
 	PerUserOverrides map[string]LimitsConfig `yaml:"per_user_overrides"`
+	UserLimits       LimitsConfig            `yaml:"user_limits"`
+	Tiers            map[string]LimitsConfig `yaml:"tiers"`
+	FiatLimits       FiatLimitsConfig        `yaml:"fiat_limits"`
+	// Chains runs one pipeline per chain, replacing blockchain, limits,
+	// per_user_overrides, user_limits, tiers and fiat_limits.
+	Chains []ChainConfig `yaml:"chains"`
+	// UserTiers assigns users to tiers on every chain. Users not listed are
+	// looked up in the tier assignments stored in the chain's database, which
+	// the admin API maintains.
+	UserTiers map[string]string `yaml:"user_tiers"`
+	// CrossChainLimits cap the combined withdrawals of an asset across chains.
+	CrossChainLimits []CrossChainLimitConfig `yaml:"cross_chain_limits"`
 	ListenAddr       string                  `yaml:"listen_addr"`
 	DBPath           string                  `yaml:"db_path"`
+	// AdminToken is the bearer token required by the operator API under
+	// /admin. The API is disabled when it is empty.
+	AdminToken string `yaml:"admin_token"`
+	// Recipients restricts who withdrawals may be sent to, on every chain.
+	Recipients RecipientsConfig `yaml:"recipients"`
+	Policy     PolicyConfig     `yaml:"policy"`
+	Anomaly    AnomalyConfig    `yaml:"anomaly"`
+	Review     ReviewConfig     `yaml:"review"`
+	// CircuitBreaker pauses the worker when it trips.
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+}
+
+// CircuitBreakerConfig sets when the worker pauses by itself. While paused,
+// withdrawals the policy does not reject are held for review instead of
+// finalized, until an operator resumes the worker through the admin API.
+//   - max_consecutive_rejections pauses after that many withdrawals in a row
+//     were rejected by the policy, across all chains;
+//   - max_outflow_percent pauses instead of finalizing a withdrawal that would
+//     bring the amount of its token withdrawn within outflow_window (default
+//     1h) above that percentage of the custody contracts' balance of it.
+//
+// Zero disables a trigger.
+type CircuitBreakerConfig struct {
+	MaxConsecutiveRejections int           `yaml:"max_consecutive_rejections"`
+	MaxOutflowPercent        float64       `yaml:"max_outflow_percent"`
+	OutflowWindow            time.Duration `yaml:"outflow_window"`
+}
+
+// ReviewConfig governs withdrawals held for manual review. Operators approve
+// or reject them through the admin API until their deadline: timeout (default
+// 24h) after the request, but for contracts with an operation expiry no later
+// than margin (default 5m) before it, so that an approval can still be mined;
+// margin must be shorter than the operation expiry.
+// Past the deadline, held withdrawals are rejected; the worker looks for them
+// every check_interval (default 30s).
+type ReviewConfig struct {
+	Timeout       time.Duration `yaml:"timeout"`
+	Margin        time.Duration `yaml:"margin"`
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+// PolicyConfig sets what happens when a withdrawal violates one of the
+// built-in rules: recipients, limits, user_limits, cross_chain_limits,
+// prices (the prices of fiat_limits cannot be read), fiat_limits, anomaly or
+// liquidity. Actions maps rule names to "reject" (the default except for
+// prices, anomaly and liquidity), "hold" (leave it to an operator, the
+// default for prices, anomaly and liquidity) or "log" (only log the
+// violation).
+type PolicyConfig struct {
+	Actions map[string]string `yaml:"actions"`
+}
+
+// RecipientsConfig points to address list files (one address per line, '#'
+// starts a comment) that are reloaded when they change, checking every
+// reload_interval (default 10s). Withdrawals to an address on the denylist are
+// rejected. Allowlists maps token addresses to lists: withdrawals of such a
+// token are rejected unless the recipient is on its list. The files must exist
+// at startup; a file that later fails to load keeps its previous contents.
+type RecipientsConfig struct {
+	Denylist       string            `yaml:"denylist"`
+	Allowlists     map[string]string `yaml:"allowlists"`
+	ReloadInterval time.Duration     `yaml:"reload_interval"`
+}
+
+// AnomalyConfig flags withdrawals that are unusual for the user, compared to
+// their withdrawals within lookback (default 90d) on the same chain. Amounts
+// and frequency are only judged once the user withdrew the token min_history
+// times (default 5):
+//   - amount_factor flags amounts above that many times the user's median;
+//   - frequency_factor flags a withdrawal when the user's withdrawals within
+//     frequency_window (default 1h) exceed that many times their average.
+//
+// new_token flags the first withdrawal of a token by a user who withdrew
+// others before. Detection is enabled by setting any of the three.
+type AnomalyConfig struct {
+	Lookback        string  `yaml:"lookback"`
+	MinHistory      int     `yaml:"min_history"`
+	AmountFactor    float64 `yaml:"amount_factor"`
+	FrequencyFactor float64 `yaml:"frequency_factor"`
+	FrequencyWindow string  `yaml:"frequency_window"`
+	NewToken        bool    `yaml:"new_token"`
+}
+
+// Enabled reports whether any anomaly check is configured.
+func (c AnomalyConfig) Enabled() bool {
+	return c.AmountFactor > 0 || c.FrequencyFactor > 0 || c.NewToken
 }
 
 type BlockchainConfig struct {
-	RPCURL             string        `yaml:"rpc_url"`
-	ContractAddr       string        `yaml:"contract_address"`
-	PrivateKey         string        `yaml:"private_key"`
-	StartBlock         uint64        `yaml:"start_block"`
+	RPCURL string `yaml:"rpc_url"`
+	// FallbackRPCURLs lists additional endpoints that calls fail over to
+	// when the current one errors.
+	FallbackRPCURLs []string `yaml:"fallback_rpc_urls"`
+	// RPCQuorum, when > 1, requires that many endpoints to return identical
+	// confirmed headers and logs before events are processed.
+	RPCQuorum    int    `yaml:"rpc_quorum"`
+	ContractAddr string `yaml:"contract_address"`
+	PrivateKey   string `yaml:"private_key"`
+	StartBlock   uint64 `yaml:"start_block"`
+	// OperationExpiry is how long after a withdrawal request the custody
+	// contract accepts its finalization. It is read from the contract's
+	// OPERATION_EXPIRY() if unset; 0 when that fails means no expiry.
+	OperationExpiry    time.Duration `yaml:"operation_expiry"`
 	ConfirmationBlocks uint64        `yaml:"confirmation_blocks"`
-	PollInterval       time.Duration `yaml:"poll_interval"`
+	// ConfirmationMode is "depth" (default: confirmation_blocks below the
+	// latest block), "safe" or "finalized" (follow the node's block tag,
+	// falling back to depth on chains that lack it).
+	ConfirmationMode string        `yaml:"confirmation_mode"`
+	PollInterval     time.Duration `yaml:"poll_interval"`
+	// ListenMode selects how new blocks are discovered: "subscribe" (default)
+	// reacts to newHeads pushed over the WebSocket connection and polls only
+	// while the subscription is down; "poll" only polls every poll_interval.
+	ListenMode string `yaml:"listen_mode"`
+	// ListenerMaxRestarts is how many times in a row the worker restarts a
+	// failed event listener before it gives up and exits. 0 means forever.
+	ListenerMaxRestarts int `yaml:"listener_max_restarts"`
+	// BackfillConcurrency is how many eth_getLogs queries run in parallel
+	// when catching up on a long block range. 0 or 1 fetches sequentially.
+	BackfillConcurrency int `yaml:"backfill_concurrency"`
+	// Contracts lists the custody contracts the worker watches and enforces
+	// limits over, instead of the single contract_address.
+	Contracts []ContractConfig `yaml:"contracts"`
+}
+
+// ContractConfig is a custody contract watched by the worker. Label names it
+// in logs and recorded decisions and defaults to the address. PrivateKey,
+// StartBlock and OperationExpiry default to those of the blockchain section.
+type ContractConfig struct {
+	Address         string        `yaml:"address"`
+	Label           string        `yaml:"label"`
+	PrivateKey      string        `yaml:"private_key"`
+	StartBlock      uint64        `yaml:"start_block"`
+	OperationExpiry time.Duration `yaml:"operation_expiry"`
+}
+
+// ChainConfig configures the pipeline of one chain. Name identifies the chain
+// in logs, metrics, the admin API and cross_chain_limits. Each chain keeps its
+// decisions and cursors in its own database, DBPath, which defaults to db_path
+// with the name appended (e.g. nitewatch-mainnet.db).
+//
+// The per-user limit of a token is taken from the first of these that has an
+// entry for it: the user's per_user_overrides, the tiers entry of the user's
+// tier, user_limits. Users it is found for in none have no per-user limit.
+type ChainConfig struct {
+	Name             string                  `yaml:"name"`
+	Blockchain       BlockchainConfig        `yaml:"blockchain"`
+	Limits           LimitsConfig            `yaml:"limits"`
+	PerUserOverrides map[string]LimitsConfig `yaml:"per_user_overrides"`
+	// UserLimits are the per-user limits of users without an override or a
+	// tier limit for the token.
+	UserLimits LimitsConfig `yaml:"user_limits"`
+	// Tiers maps tier names, e.g. retail or vip, to the per-user limits of
+	// their users.
+	Tiers      map[string]LimitsConfig `yaml:"tiers"`
+	FiatLimits FiatLimitsConfig        `yaml:"fiat_limits"`
+	DBPath     string                  `yaml:"db_path"`
+}
+
+// CrossChainLimitConfig caps the total withdrawn of tokens that represent the
+// same asset on different chains, e.g. USDC. Tokens maps chain names to the
+// token address on that chain. Amounts are added up in base units, so the
+// tokens must have the same decimals, which is checked at startup for limits
+// in whole tokens.
+type CrossChainLimitConfig struct {
+	Name        string            `yaml:"name"`
+	Tokens      map[string]string `yaml:"tokens"`
+	LimitConfig `yaml:",inline"`
+}
+
+// FiatLimitsConfig caps the combined value of withdrawals across tokens in a
+// quote currency such as USD. Tokens maps the token addresses whose
+// withdrawals are counted to their settings; withdrawals of other tokens are
+// not. Amounts are decimal strings in the quote currency. The limits are
+// disabled when no tokens are configured.
+type FiatLimitsConfig struct {
+	Currency        string                     `yaml:"currency"`
+	PriceSource     PriceSourceConfig          `yaml:"price_source"`
+	Tokens          map[string]FiatTokenConfig `yaml:"tokens"`
+	FiatLimitConfig `yaml:",inline"`
+	// PerUser caps the value withdrawn by each user.
+	PerUser FiatLimitConfig `yaml:"per_user"`
+	// Window is "calendar" (default) or "rolling", as for LimitConfig.
+	Window string `yaml:"window"`
+}
+
+// FiatLimitConfig caps the value withdrawn per hour and day.
+type FiatLimitConfig struct {
+	Hourly string `yaml:"hourly"`
+	Daily  string `yaml:"daily"`
+}
+
+// FiatTokenConfig describes a token counted towards fiat limits. Decimals
+// converts its base-unit amounts to whole tokens, which prices refer to.
+type FiatTokenConfig struct {
+	Decimals *uint8 `yaml:"decimals"`
+}
+
+// PriceSourceConfig selects where token prices are read from: a JSON file or
+// an HTTP endpoint serving the same document, see package price. Prices are
+// reloaded every refresh_interval (default 1m) and withdrawals are rejected
+// when the latest prices are older than max_age (default 10m).
+type PriceSourceConfig struct {
+	File            string        `yaml:"file"`
+	URL             string        `yaml:"url"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	MaxAge          time.Duration `yaml:"max_age"`
 }
 
+// Enabled reports whether fiat limits are configured.
+func (c FiatLimitsConfig) Enabled() bool {
+	return len(c.Tokens) > 0
+}
+
+const (
+	ListenModeSubscribe = "subscribe"
+	ListenModePoll      = "poll"
+
+	ConfirmationModeDepth     = "depth"
+	ConfirmationModeSafe      = "safe"
+	ConfirmationModeFinalized = "finalized"
+
+	LimitWindowCalendar = "calendar"
+	LimitWindowRolling  = "rolling"
+)
+
 // LimitsConfig maps token contract addresses to their withdrawal rate limits.
 type LimitsConfig map[string]LimitConfig
 
+// LimitConfig caps the amount of a token withdrawn per window. Amounts are
+// base units (wei), unless the entry declares decimals or symbol or an amount
+// carries a unit, in which case all its amounts are whole tokens such as
+// "10", "10.5" or "10.5 ETH". Decimals and symbol that are not declared are
+// read from the token contract at startup, and declared ones must match it.
+// The native token (the zero address) has 18 decimals and symbol ETH unless
+// declared otherwise.
 type LimitConfig struct {
-	Hourly string `yaml:"hourly"`
-	Daily  string `yaml:"daily"`
+	Hourly   string `yaml:"hourly"`
+	Daily    string `yaml:"daily"`
+	Decimals *uint8 `yaml:"decimals"`
+	Symbol   string `yaml:"symbol"`
+	// Window is "calendar" (default: the current UTC hour and day) or
+	// "rolling" (the last 60 minutes and 24 hours).
+	Window string `yaml:"window"`
+	// Windows adds caps over other periods, e.g. weekly or monthly.
+	Windows []WindowLimitConfig `yaml:"windows"`
+	// Count caps the number of withdrawals: of the token as a whole in
+	// limits, of the user in per_user_overrides.
+	Count CountLimitConfig `yaml:"count"`
+	// UserCount caps the number of withdrawals of the token by each user. It
+	// is only valid in limits.
+	UserCount CountLimitConfig `yaml:"user_count"`
+	// Reserve is the balance of the token each custody contract keeps:
+	// withdrawals that would take it below are held or rejected by the
+	// liquidity rule. It is only valid in limits.
+	Reserve string `yaml:"reserve"`
+}
+
+// CountLimitConfig caps the number of withdrawals per window, using the window
+// mode of the enclosing limit. Zero means no cap.
+type CountLimitConfig struct {
+	Hourly  uint64              `yaml:"hourly"`
+	Daily   uint64              `yaml:"daily"`
+	Windows []WindowCountConfig `yaml:"windows"`
+}
+
+// WindowCountConfig caps the number of withdrawals within Period, which has
+// the syntax of WindowLimitConfig.Period.
+type WindowCountConfig struct {
+	Period string `yaml:"period"`
+	Max    uint64 `yaml:"max"`
+}
+
+// InTokens reports whether the amounts of the limit are whole tokens rather
+// than base units.
+func (c LimitConfig) InTokens() bool {
+	if c.Decimals != nil || c.Symbol != "" {
+		return true
+	}
+	for _, amount := range c.amounts() {
+		if _, unit, _ := splitAmount(amount); unit != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// amounts returns the non-empty amounts of the limit.
+func (c LimitConfig) amounts() []string {
+	var amounts []string
+	for _, amount := range append([]string{c.Hourly, c.Daily, c.Reserve}, windowMaxes(c.Windows)...) {
+		if amount != "" {
+			amounts = append(amounts, amount)
+		}
+	}
+	return amounts
+}
+
+func windowMaxes(windows []WindowLimitConfig) []string {
+	maxes := make([]string, len(windows))
+	for i, w := range windows {
+		maxes[i] = w.Max
+	}
+	return maxes
+}
+
+// ParseAmount parses an amount of whole tokens such as "10", "10.5" or
+// "10.5 ETH" into base units of a token with the given decimals. The unit, if
+// any, must be symbol, ignoring case.
+func ParseAmount(s string, decimals uint8, symbol string) (*big.Int, error) {
+	number, unit, err := splitAmount(s)
+	if err != nil {
+		return nil, err
+	}
+	if unit != "" && !strings.EqualFold(unit, symbol) {
+		return nil, fmt.Errorf("invalid amount %q: unit %s does not match token symbol %s", s, unit, symbol)
+	}
+	whole, frac, _ := strings.Cut(number, ".")
+	if len(frac) > int(decimals) {
+		return nil, fmt.Errorf("invalid amount %q: more than %d decimals", s, decimals)
+	}
+	v, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", int(decimals)-len(frac)), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	return v, nil
+}
+
+// splitAmount splits an amount of whole tokens into its number, checked to be
+// a non-negative decimal, and its optional unit.
+func splitAmount(s string) (number, unit string, err error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", "", fmt.Errorf("invalid amount %q", s)
+	}
+	number = fields[0]
+	if len(fields) == 2 {
+		unit = fields[1]
+	}
+	whole, frac, hasFrac := strings.Cut(number, ".")
+	if whole == "" || !isDigits(whole) || (hasFrac && (frac == "" || !isDigits(frac))) {
+		return "", "", fmt.Errorf("invalid amount %q", s)
+	}
+	return number, unit, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (c CountLimitConfig) isZero() bool {
+	return c.Hourly == 0 && c.Daily == 0 && len(c.Windows) == 0
+}
+
+// WindowLimitConfig caps the amount withdrawn within Period, a duration such
+// as "10m", "1h", "7d" or "30d". In calendar mode, windows start at multiples
+// of Period since 0001-01-01 UTC, so e.g. "7d" windows start on Mondays.
+type WindowLimitConfig struct {
+	Period string `yaml:"period"`
+	Max    string `yaml:"max"`
+}
+
+// ParsePeriod parses a limit window period. It accepts time.ParseDuration
+// syntax plus whole days ("7d") and weeks ("2w").
+func ParsePeriod(s string) (time.Duration, error) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	default:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid period %q", s)
+		}
+		return d, nil
+	}
+	n, err := strconv.ParseUint(s[:len(s)-1], 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid period %q", s)
+	}
+	return time.Duration(n) * unit, nil
 }
 
 func (c Config) Validate() error {
+	if len(c.Chains) > 0 && (c.Blockchain.RPCURL != "" || len(c.Limits) > 0 || len(c.PerUserOverrides) > 0 ||
+		len(c.UserLimits) > 0 || len(c.Tiers) > 0 || c.FiatLimits.Enabled()) {
+		return errors.New("chains replaces blockchain, limits, per_user_overrides, user_limits, tiers and fiat_limits; set one or the other")
+	}
+	if len(c.Chains) == 0 && len(c.CrossChainLimits) > 0 {
+		return errors.New("cross_chain_limits requires chains")
+	}
+
+	names := make(map[string]bool)
+	tiers := make(map[string]bool)
+	for _, chain := range c.ChainConfigs() {
+		section := "chains[" + chain.Name + "]."
+		if len(c.Chains) == 0 {
+			section = ""
+		} else {
+			if chain.Name == "" || len(chain.Name) > 32 {
+				return errors.New("every chain needs a name of at most 32 characters")
+			}
+			if names[chain.Name] {
+				return fmt.Errorf("duplicate chain name: %s", chain.Name)
+			}
+			names[chain.Name] = true
+		}
+		if err := chain.validate(section); err != nil {
+			return err
+		}
+		for tier := range chain.Tiers {
+			tiers[tier] = true
+		}
+	}
+	for user, tier := range c.UserTiers {
+		if !common.IsHexAddress(user) {
+			return fmt.Errorf("invalid user address in user_tiers: %s", user)
+		}
+		if !tiers[tier] {
+			return fmt.Errorf("unknown tier for %s in user_tiers: %q", user, tier)
+		}
+	}
+
+	for rule, action := range c.Policy.Actions {
+		switch action {
+		case "reject", "hold", "log":
+		default:
+			return fmt.Errorf("invalid action for policy rule %s: %q (must be reject, hold or log)", rule, action)
+		}
+	}
+
+	if c.Review.Timeout < 0 || c.Review.Margin < 0 || c.Review.CheckInterval < 0 {
+		return errors.New("review: durations must not be negative")
+	}
+	for _, chain := range c.ChainConfigs() {
+		for _, contract := range chain.Blockchain.CustodyContracts() {
+			if contract.OperationExpiry > 0 && contract.OperationExpiry <= c.Review.Margin {
+				return fmt.Errorf("operation_expiry of %s (%s) must exceed review.margin (%s)",
+					contract.Label, contract.OperationExpiry, c.Review.Margin)
+			}
+		}
+	}
+
+	if c.CircuitBreaker.MaxConsecutiveRejections < 0 || c.CircuitBreaker.MaxOutflowPercent < 0 || c.CircuitBreaker.OutflowWindow < 0 {
+		return errors.New("circuit_breaker: values must not be negative")
+	}
+
+	if c.Anomaly.Enabled() {
+		if err := c.Anomaly.validate(); err != nil {
+			return err
+		}
+	}
+
+	for token := range c.Recipients.Allowlists {
+		if !common.IsHexAddress(token) {
+			return fmt.Errorf("invalid token address in recipients.allowlists: %s", token)
+		}
+	}
+
+	for _, limit := range c.CrossChainLimits {
+		section := fmt.Sprintf("cross_chain_limits[%s]", limit.Name)
+		if len(limit.Tokens) == 0 {
+			return fmt.Errorf("%s: at least one token must be configured", section)
+		}
+		for chain, token := range limit.Tokens {
+			if !names[chain] {
+				return fmt.Errorf("%s: unknown chain %s", section, chain)
+			}
+			if !common.IsHexAddress(token) {
+				return fmt.Errorf("%s: invalid token address for %s: %s", section, chain, token)
+			}
+		}
+		if err := validateLimitConfig(limit.LimitConfig, limit.Name, section); err != nil {
+			return err
+		}
+		if !limit.Count.isZero() || !limit.UserCount.isZero() {
+			return fmt.Errorf("%s: count limits are not supported across chains", section)
+		}
+		if limit.Reserve != "" {
+			return fmt.Errorf("%s: reserves are set per chain in limits", section)
+		}
+	}
+	return nil
+}
+
+func (c ChainConfig) validate(section string) error {
 	if err := c.Blockchain.Validate(); err != nil {
-		return fmt.Errorf("invalid blockchain config: %w", err)
+		return fmt.Errorf("invalid %sblockchain config: %w", section, err)
 	}
-	if len(c.Limits) == 0 {
-		return errors.New("at least one token limit must be configured")
+	if len(c.Limits) == 0 && !c.FiatLimits.Enabled() {
+		return fmt.Errorf("at least one token limit must be configured in %slimits or %sfiat_limits", section, section)
 	}
-	if err := validateLimitsConfig(c.Limits, "limits"); err != nil {
+	if err := validateLimitsConfig(c.Limits, section+"limits"); err != nil {
 		return err
 	}
+	if c.FiatLimits.Enabled() {
+		if err := c.FiatLimits.validate(section + "fiat_limits"); err != nil {
+			return err
+		}
+	}
+	userSections := make(map[string]LimitsConfig)
 	for userAddr, tokenLimits := range c.PerUserOverrides {
 		if !common.IsHexAddress(userAddr) {
-			return fmt.Errorf("invalid user address in per_user_overrides: %s", userAddr)
+			return fmt.Errorf("invalid user address in %sper_user_overrides: %s", section, userAddr)
+		}
+		userSections[fmt.Sprintf("%sper_user_overrides[%s]", section, userAddr)] = tokenLimits
+	}
+	if len(c.UserLimits) > 0 {
+		userSections[section+"user_limits"] = c.UserLimits
+	}
+	for tier, tokenLimits := range c.Tiers {
+		if tier == "" || len(tier) > 64 {
+			return fmt.Errorf("every tier in %stiers needs a name of at most 64 characters", section)
 		}
-		if err := validateLimitsConfig(tokenLimits, fmt.Sprintf("per_user_overrides[%s]", userAddr)); err != nil {
+		userSections[fmt.Sprintf("%stiers[%s]", section, tier)] = tokenLimits
+	}
+	for userSection, tokenLimits := range userSections {
+		if err := validateLimitsConfig(tokenLimits, userSection); err != nil {
 			return err
 		}
+		for token, lim := range tokenLimits {
+			if !lim.UserCount.isZero() {
+				return fmt.Errorf("user_count for %s in %s is only valid in limits; use count", token, userSection)
+			}
+			if lim.Reserve != "" {
+				return fmt.Errorf("reserve for %s in %s is only valid in limits", token, userSection)
+			}
+		}
+	}
+	return nil
+}
+
+// ChainConfigs returns the configured chains, or a single unnamed chain made of
+// blockchain, limits, per_user_overrides, user_limits, tiers, fiat_limits and
+// db_path.
+func (c Config) ChainConfigs() []ChainConfig {
+	if len(c.Chains) == 0 {
+		return []ChainConfig{{
+			Blockchain:       c.Blockchain,
+			Limits:           c.Limits,
+			PerUserOverrides: c.PerUserOverrides,
+			UserLimits:       c.UserLimits,
+			Tiers:            c.Tiers,
+			FiatLimits:       c.FiatLimits,
+			DBPath:           c.DBPath,
+		}}
+	}
+	chains := make([]ChainConfig, len(c.Chains))
+	for i, chain := range c.Chains {
+		if chain.DBPath == "" {
+			ext := filepath.Ext(c.DBPath)
+			chain.DBPath = strings.TrimSuffix(c.DBPath, ext) + "-" + chain.Name + ext
+		}
+		chains[i] = chain
+	}
+	return chains
+}
+
+func (c FiatLimitsConfig) validate(section string) error {
+	if c.Currency == "" {
+		return fmt.Errorf("%s: missing currency", section)
+	}
+	if (c.PriceSource.File == "") == (c.PriceSource.URL == "") {
+		return fmt.Errorf("%s: price_source needs exactly one of file and url", section)
+	}
+	if c.PriceSource.URL != "" && !strings.HasPrefix(c.PriceSource.URL, "http://") && !strings.HasPrefix(c.PriceSource.URL, "https://") {
+		return fmt.Errorf("%s: price_source url must use http:// or https://, got: %s", section, c.PriceSource.URL)
+	}
+	if c.PriceSource.MaxAge < c.PriceSource.RefreshInterval {
+		return fmt.Errorf("%s: price_source max_age must not be shorter than refresh_interval", section)
+	}
+	for addr, token := range c.Tokens {
+		if !common.IsHexAddress(addr) {
+			return fmt.Errorf("invalid token address in %s: %s", section, addr)
+		}
+		if token.Decimals == nil {
+			return fmt.Errorf("%s: missing decimals for %s", section, addr)
+		}
+	}
+	for name, lim := range map[string]FiatLimitConfig{"": c.FiatLimitConfig, "per_user ": c.PerUser} {
+		for window, amount := range map[string]string{"hourly": lim.Hourly, "daily": lim.Daily} {
+			if amount == "" {
+				continue
+			}
+			if v, ok := new(big.Rat).SetString(amount); !ok || v.Sign() < 0 {
+				return fmt.Errorf("%s: invalid %s%s limit: %s", section, name, window, amount)
+			}
+		}
+	}
+	switch c.Window {
+	case "", LimitWindowCalendar, LimitWindowRolling:
+	default:
+		return fmt.Errorf("%s: invalid limit window: %s (must be %q or %q)", section, c.Window, LimitWindowCalendar, LimitWindowRolling)
+	}
+	return nil
+}
+
+func (c AnomalyConfig) validate() error {
+	if c.AmountFactor < 0 || c.FrequencyFactor < 0 {
+		return errors.New("anomaly: factors must not be negative")
+	}
+	if c.MinHistory < 1 {
+		return errors.New("anomaly: min_history must be positive")
+	}
+	for name, period := range map[string]string{"lookback": c.Lookback, "frequency_window": c.FrequencyWindow} {
+		if _, err := ParsePeriod(period); err != nil {
+			return fmt.Errorf("anomaly: invalid %s: %w", name, err)
+		}
 	}
 	return nil
 }
@@ -63,15 +655,99 @@ func validateLimitsConfig(lc LimitsConfig, section string) error {
 		if !common.IsHexAddress(addr) {
 			return fmt.Errorf("invalid token address in %s: %s", section, addr)
 		}
-		if lim.Hourly != "" {
-			if _, ok := new(big.Int).SetString(lim.Hourly, 10); !ok {
-				return fmt.Errorf("invalid hourly limit for %s in %s: %s", addr, section, lim.Hourly)
-			}
+		if err := validateLimitConfig(lim, addr, section); err != nil {
+			return err
 		}
-		if lim.Daily != "" {
-			if _, ok := new(big.Int).SetString(lim.Daily, 10); !ok {
-				return fmt.Errorf("invalid daily limit for %s in %s: %s", addr, section, lim.Daily)
-			}
+	}
+	return nil
+}
+
+func validateLimitConfig(lim LimitConfig, name, section string) error {
+	inTokens := lim.InTokens()
+	windows := append([]WindowLimitConfig{{"hourly", lim.Hourly}, {"daily", lim.Daily}}, lim.Windows...)
+	for i, w := range windows {
+		if w.Max == "" && i < 2 {
+			continue
+		}
+		if err := validateAmount(w.Max, lim, inTokens); err != nil {
+			return fmt.Errorf("invalid %s limit for %s in %s: %w", w.Period, name, section, err)
+		}
+	}
+	if lim.Reserve != "" {
+		if err := validateAmount(lim.Reserve, lim, inTokens); err != nil {
+			return fmt.Errorf("invalid reserve for %s in %s: %w", name, section, err)
+		}
+	}
+	if err := validateCountLimitConfig(lim.Count, name, section+" count"); err != nil {
+		return err
+	}
+	if err := validateCountLimitConfig(lim.UserCount, name, section+" user_count"); err != nil {
+		return err
+	}
+	periods := make(map[time.Duration]bool)
+	for _, w := range lim.Windows {
+		period, err := ParsePeriod(w.Period)
+		if err != nil {
+			return fmt.Errorf("invalid limit window for %s in %s: %w", name, section, err)
+		}
+		if period <= 0 {
+			return fmt.Errorf("invalid limit window for %s in %s: period must be positive, got %s", name, section, w.Period)
+		}
+		if periods[period] {
+			return fmt.Errorf("duplicate limit window for %s in %s: %s", name, section, w.Period)
+		}
+		periods[period] = true
+	}
+	switch lim.Window {
+	case "", LimitWindowCalendar, LimitWindowRolling:
+	default:
+		return fmt.Errorf("invalid limit window for %s in %s: %s (must be %q or %q)",
+			name, section, lim.Window, LimitWindowCalendar, LimitWindowRolling)
+	}
+	return nil
+}
+
+// validateAmount checks the syntax of a limit amount. Amounts in whole tokens
+// are fully checked when the decimals are declared, and otherwise against the
+// decimals read from the token at startup.
+func validateAmount(amount string, lim LimitConfig, inTokens bool) error {
+	if !inTokens {
+		if _, ok := new(big.Int).SetString(amount, 10); !ok {
+			return fmt.Errorf("%q is not an integer amount of base units; set decimals or symbol, or add the unit, to use whole tokens", amount)
+		}
+		return nil
+	}
+	_, unit, err := splitAmount(amount)
+	if err != nil {
+		return err
+	}
+	if lim.Symbol != "" && unit != "" && !strings.EqualFold(unit, lim.Symbol) {
+		return fmt.Errorf("unit of %q does not match symbol %s", amount, lim.Symbol)
+	}
+	if lim.Decimals != nil {
+		if _, err := ParseAmount(amount, *lim.Decimals, unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateCountLimitConfig(lim CountLimitConfig, name, section string) error {
+	periods := make(map[time.Duration]bool)
+	for _, w := range lim.Windows {
+		period, err := ParsePeriod(w.Period)
+		if err != nil {
+			return fmt.Errorf("invalid limit window for %s in %s: %w", name, section, err)
+		}
+		if period <= 0 {
+			return fmt.Errorf("invalid limit window for %s in %s: period must be positive, got %s", name, section, w.Period)
+		}
+		if periods[period] {
+			return fmt.Errorf("duplicate limit window for %s in %s: %s", name, section, w.Period)
+		}
+		periods[period] = true
+		if w.Max == 0 {
+			return fmt.Errorf("invalid %s count limit for %s in %s: max must be positive", w.Period, name, section)
 		}
 	}
 	return nil
@@ -81,21 +757,93 @@ func (c BlockchainConfig) Validate() error {
 	if c.RPCURL == "" {
 		return errors.New("missing blockchain RPC URL")
 	}
-	if !strings.HasPrefix(c.RPCURL, "ws://") && !strings.HasPrefix(c.RPCURL, "wss://") {
-		return fmt.Errorf("RPC URL must use WebSocket (ws:// or wss://), got: %s", c.RPCURL)
+	for _, url := range c.RPCURLs() {
+		if !strings.HasPrefix(url, "ws://") && !strings.HasPrefix(url, "wss://") {
+			return fmt.Errorf("RPC URL must use WebSocket (ws:// or wss://), got: %s", url)
+		}
+	}
+	if c.RPCQuorum < 0 || c.RPCQuorum > len(c.RPCURLs()) {
+		return fmt.Errorf("rpc_quorum must be between 0 and the number of RPC URLs (%d), got: %d", len(c.RPCURLs()), c.RPCQuorum)
 	}
-	if !common.IsHexAddress(c.ContractAddr) {
-		return fmt.Errorf("invalid contract address: %s", c.ContractAddr)
+	if c.ContractAddr != "" && len(c.Contracts) > 0 {
+		return errors.New("contract_address and contracts are mutually exclusive")
+	}
+	addrs := make(map[common.Address]bool)
+	labels := make(map[string]bool)
+	for _, contract := range c.CustodyContracts() {
+		if !common.IsHexAddress(contract.Address) {
+			return fmt.Errorf("invalid contract address: %s", contract.Address)
+		}
+		addr := common.HexToAddress(contract.Address)
+		if addrs[addr] {
+			return fmt.Errorf("duplicate contract address: %s", contract.Address)
+		}
+		addrs[addr] = true
+		if len(contract.Label) > 64 {
+			return fmt.Errorf("contract label must be at most 64 characters, got: %s", contract.Label)
+		}
+		if labels[contract.Label] {
+			return fmt.Errorf("duplicate contract label: %s", contract.Label)
+		}
+		labels[contract.Label] = true
 	}
 	if c.ConfirmationBlocks == 0 {
 		return errors.New("confirmation_blocks must be > 0")
 	}
+	switch c.ConfirmationMode {
+	case "", ConfirmationModeDepth, ConfirmationModeSafe, ConfirmationModeFinalized:
+	default:
+		return fmt.Errorf("confirmation_mode must be %q, %q or %q, got: %s",
+			ConfirmationModeDepth, ConfirmationModeSafe, ConfirmationModeFinalized, c.ConfirmationMode)
+	}
 	if c.PollInterval < 1*time.Second {
 		return fmt.Errorf("poll_interval must be >= 1s, got: %s", c.PollInterval)
 	}
+	switch c.ListenMode {
+	case "", ListenModeSubscribe, ListenModePoll:
+	default:
+		return fmt.Errorf("listen_mode must be %q or %q, got: %s", ListenModeSubscribe, ListenModePoll, c.ListenMode)
+	}
+	if c.ListenerMaxRestarts < 0 {
+		return fmt.Errorf("listener_max_restarts must be >= 0, got: %d", c.ListenerMaxRestarts)
+	}
+	if c.BackfillConcurrency < 0 {
+		return fmt.Errorf("backfill_concurrency must be >= 0, got: %d", c.BackfillConcurrency)
+	}
 	return nil
 }
 
+// CustodyContracts returns the configured contracts, or contract_address as
+// the only one, with defaults applied.
+func (c BlockchainConfig) CustodyContracts() []ContractConfig {
+	contracts := c.Contracts
+	if len(contracts) == 0 {
+		contracts = []ContractConfig{{Address: c.ContractAddr}}
+	}
+	resolved := make([]ContractConfig, len(contracts))
+	for i, contract := range contracts {
+		if contract.Label == "" {
+			contract.Label = contract.Address
+		}
+		if contract.PrivateKey == "" {
+			contract.PrivateKey = c.PrivateKey
+		}
+		if contract.StartBlock == 0 {
+			contract.StartBlock = c.StartBlock
+		}
+		if contract.OperationExpiry == 0 {
+			contract.OperationExpiry = c.OperationExpiry
+		}
+		resolved[i] = contract
+	}
+	return resolved
+}
+
+// RPCURLs returns the primary RPC URL followed by the fallback ones.
+func (c BlockchainConfig) RPCURLs() []string {
+	return append([]string{c.RPCURL}, c.FallbackRPCURLs...)
+}
+
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -124,9 +872,64 @@ func parse(data []byte) (*Config, error) {
 		cfg.ListenAddr = ":8080"
 	}
 
-	if cfg.Blockchain.PollInterval == 0 {
-		cfg.Blockchain.PollInterval = 12 * time.Second
+	if cfg.Recipients.ReloadInterval == 0 {
+		cfg.Recipients.ReloadInterval = 10 * time.Second
+	}
+
+	if cfg.Review.Timeout == 0 {
+		cfg.Review.Timeout = 24 * time.Hour
+	}
+	if cfg.Review.Margin == 0 {
+		cfg.Review.Margin = 5 * time.Minute
+	}
+	if cfg.Review.CheckInterval == 0 {
+		cfg.Review.CheckInterval = 30 * time.Second
+	}
+
+	if cfg.CircuitBreaker.OutflowWindow == 0 {
+		cfg.CircuitBreaker.OutflowWindow = time.Hour
+	}
+
+	if cfg.Anomaly.Lookback == "" {
+		cfg.Anomaly.Lookback = "90d"
+	}
+	if cfg.Anomaly.MinHistory == 0 {
+		cfg.Anomaly.MinHistory = 5
+	}
+	if cfg.Anomaly.FrequencyWindow == "" {
+		cfg.Anomaly.FrequencyWindow = "1h"
+	}
+
+	applyBlockchainDefaults(&cfg.Blockchain)
+	applyPriceSourceDefaults(&cfg.FiatLimits.PriceSource)
+	for i := range cfg.Chains {
+		applyBlockchainDefaults(&cfg.Chains[i].Blockchain)
+		applyPriceSourceDefaults(&cfg.Chains[i].FiatLimits.PriceSource)
 	}
 
 	return &cfg, nil
 }
+
+func applyBlockchainDefaults(c *BlockchainConfig) {
+	if c.PollInterval == 0 {
+		c.PollInterval = 12 * time.Second
+	}
+
+	if c.ConfirmationMode == "" {
+		c.ConfirmationMode = ConfirmationModeDepth
+	}
+
+	if c.ListenMode == "" {
+		c.ListenMode = ListenModeSubscribe
+	}
+}
+
+func applyPriceSourceDefaults(c *PriceSourceConfig) {
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = time.Minute
+	}
+
+	if c.MaxAge == 0 {
+		c.MaxAge = 10 * time.Minute
+	}
+}
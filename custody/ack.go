@@ -0,0 +1,42 @@
+package custody
+
+import (
+	"context"
+	"sync"
+)
+
+// ackHandle is the acknowledgement handle embedded in every event delivered by
+// a Listener created with WithAcknowledgements. A nil handle (the default)
+// makes Ack a no-op.
+type ackHandle struct {
+	once sync.Once
+	done chan error
+}
+
+func newAckHandle() *ackHandle {
+	return &ackHandle{done: make(chan error, 1)}
+}
+
+// Ack reports that the consumer finished processing the event. A nil err
+// commits the event: the Listener advances its cursor past it and delivers the
+// next one. A non-nil err makes the Listener redeliver the event after a
+// back-off. Only the first call has an effect.
+func (h *ackHandle) Ack(err error) {
+	if h == nil {
+		return
+	}
+	h.once.Do(func() { h.done <- err })
+}
+
+// awaitAck blocks until the event is acknowledged or ctx is done.
+func (h *ackHandle) awaitAck(ctx context.Context) error {
+	if h == nil {
+		return nil
+	}
+	select {
+	case err := <-h.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,237 @@
+package custody
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/layer-3/clearsync/pkg/debounce"
+)
+
+const (
+	// maxBackfillStep bounds how far the adaptive range size may grow.
+	maxBackfillStep = 10 * blockStep
+	// backfillGrowBelow is the number of logs under which a fetched range is
+	// considered sparse enough to try a larger one next.
+	backfillGrowBelow = 1000
+	// backfillProgressInterval is how often backfill progress is logged.
+	backfillProgressInterval = 10 * time.Second
+)
+
+// rangeSizer adapts the block range of eth_getLogs queries shared by the
+// backfill workers: it shrinks whenever the node refuses a range as too large
+// and grows again while ranges come back sparse.
+type rangeSizer struct {
+	mu   sync.Mutex
+	size uint64
+}
+
+func (s *rangeSizer) current() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+func (s *rangeSizer) shrink(size uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size = max(min(s.size, size), 1)
+}
+
+func (s *rangeSizer) observe(size uint64, logCount int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if logCount < backfillGrowBelow && size >= s.size {
+		s.size = min(s.size*2, maxBackfillStep)
+	}
+}
+
+// backfillChunk is a block range fetched by a backfill worker. done is closed
+// once logs or err is set.
+type backfillChunk struct {
+	from, to uint64
+	logs     []types.Log
+	err      error
+	done     chan struct{}
+}
+
+// backfillRange delivers the logs between lastBlock and currentBlock like
+// reconcileBlockRange, but fetches consecutive chunks with up to concurrency
+// parallel queries. Chunks are handed to historicalCh strictly in block and
+// log order; at most 2*concurrency chunks are held in memory at a time.
+func backfillRange(
+	ctx context.Context,
+	client bind.ContractBackend,
+	subID string,
+	addresses []common.Address,
+	currentBlock uint64,
+	lastBlock uint64,
+	lastIndex uint32,
+	topics [][]common.Hash,
+	concurrency int,
+	historicalCh chan types.Log,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		// Stop the dispatcher and workers before waiting for them.
+		cancel()
+		wg.Wait()
+	}()
+
+	sizer := &rangeSizer{size: blockStep}
+	work := make(chan *backfillChunk)
+	ordered := make(chan *backfillChunk, 2*concurrency)
+
+	// The dispatcher cuts the range into chunks using the current range size.
+	go func() {
+		defer close(work)
+		defer close(ordered)
+		for next := lastBlock; next <= currentBlock; {
+			chunk := &backfillChunk{from: next, to: min(next+sizer.current()-1, currentBlock), done: make(chan struct{})}
+			select {
+			case ordered <- chunk:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case work <- chunk:
+			case <-ctx.Done():
+				return
+			}
+			next = chunk.to + 1
+		}
+	}()
+
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range work {
+				chunk.logs, chunk.err = fetchChunk(ctx, client, subID, addresses, topics, chunk.from, chunk.to, sizer)
+				close(chunk.done)
+			}
+		}()
+	}
+
+	total := currentBlock - lastBlock + 1
+	started := time.Now()
+	lastReport := started
+	listenerLogger.Infow("starting parallel backfill", "subID", subID, "fromBlock", lastBlock, "toBlock", currentBlock, "concurrency", concurrency)
+
+	for chunk := range ordered {
+		select {
+		case <-chunk.done:
+		case <-ctx.Done():
+			return nil
+		}
+		if chunk.err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return chunk.err
+		}
+
+		for _, ethLog := range chunk.logs {
+			if ethLog.BlockNumber == lastBlock && ethLog.Index <= uint(lastIndex) {
+				continue
+			}
+			select {
+			case historicalCh <- ethLog:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		if time.Since(lastReport) >= backfillProgressInterval || chunk.to == currentBlock {
+			lastReport = time.Now()
+			done := chunk.to - lastBlock + 1
+			remaining := total - done
+			elapsed := time.Since(started)
+			rate := float64(done) / elapsed.Seconds()
+			var eta time.Duration
+			if rate > 0 {
+				eta = time.Duration(float64(remaining)/rate) * time.Second
+			}
+			listenerLogger.Infow("backfill progress", "subID", subID, "block", chunk.to, "blocksRemaining", remaining,
+				"blocksPerSecond", fmt.Sprintf("%.0f", rate), "eta", eta.Round(time.Second), "rangeSize", sizer.current())
+		}
+	}
+	return nil
+}
+
+// fetchChunk fetches the logs in [from, to], splitting the range whenever the
+// node refuses it as too large and retrying other failures with back-off.
+func fetchChunk(
+	ctx context.Context,
+	client bind.ContractBackend,
+	subID string,
+	addresses []common.Address,
+	topics [][]common.Hash,
+	from, to uint64,
+	sizer *rangeSizer,
+) ([]types.Log, error) {
+	var (
+		result       []types.Log
+		backOffCount int
+		lastErr      error
+	)
+	size := to - from + 1
+	for start := from; start <= to; {
+		if !waitForBackOffTimeout(ctx, backOffCount, "backfill block range") {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to fetch logs from block %d: %w: %w", start, ErrBackOffExceeded, lastErr)
+		}
+
+		end := min(start+size-1, to)
+		query := ethereum.FilterQuery{
+			Addresses: addresses,
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Topics:    topics,
+		}
+
+		var logs []types.Log
+		logsCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+		err := debounce.Debounce(logsCtx, listenerLogger, func(ctx context.Context) error {
+			var err error
+			logs, err = client.FilterLogs(ctx, query)
+			return err
+		})
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if strings.Contains(err.Error(), "Exceeded max range limit for eth_getLogs:") && size > 1 {
+				size = max(size/2, 1)
+				sizer.shrink(size)
+				continue
+			}
+			if advisedStart, advisedEnd, extractErr := extractAdvisedBlockRange(err.Error()); extractErr == nil && advisedEnd >= advisedStart {
+				size = max(min(advisedEnd-advisedStart+1, size/2), 1)
+				sizer.shrink(size)
+				continue
+			}
+			listenerLogger.Errorw("failed to filter logs", "error", err, "subID", subID, "startBlock", start, "endBlock", end)
+			lastErr = err
+			backOffCount++
+			continue
+		}
+
+		sizer.observe(end-start+1, len(logs))
+		result = append(result, logs...)
+		backOffCount = 0
+		start = end + 1
+	}
+	return result, nil
+}
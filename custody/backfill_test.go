@@ -0,0 +1,108 @@
+package custody
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeSizer(t *testing.T) {
+	s := &rangeSizer{size: blockStep}
+
+	s.shrink(2500)
+	require.Equal(t, uint64(2500), s.current())
+	s.shrink(5000)
+	require.Equal(t, uint64(2500), s.current(), "shrink grew the size")
+	s.shrink(0)
+	require.Equal(t, uint64(1), s.current())
+
+	s.shrink(1)
+	s.observe(1, backfillGrowBelow)
+	require.Equal(t, uint64(1), s.current(), "grew after a dense range")
+	s.observe(1, 0)
+	require.Equal(t, uint64(2), s.current())
+	s.observe(1, 0)
+	require.Equal(t, uint64(2), s.current(), "grew after a range smaller than the current size")
+
+	for range 20 {
+		s.observe(s.current(), 0)
+	}
+	require.Equal(t, uint64(maxBackfillStep), s.current())
+}
+
+func TestBackfillRange(t *testing.T) {
+	const (
+		lastBlock    = 100
+		currentBlock = 12000
+		maxRange     = 3000
+	)
+	for name, tooLarge := range map[string]func(q ethereum.FilterQuery) error{
+		"max range limit": func(ethereum.FilterQuery) error {
+			return fmt.Errorf("Exceeded max range limit for eth_getLogs: %d", maxRange)
+		},
+		"advised range": func(q ethereum.FilterQuery) error {
+			from := q.FromBlock.Uint64()
+			return fmt.Errorf("query returned more than 10000 results. Try with this block range [0x%x, 0x%x].", from, from+maxRange-1)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logsAt := map[uint64]int{lastBlock: 3, 2999: 1, 3000: 2, 5001: 1, 9999: 1, 10000: 3, 10001: 1, 11999: 1, 12000: 2, 12003: 1}
+			chain := newFakeChain(0)
+			var want []types.Log
+			id := byte(0)
+			for n := uint64(1); n <= currentBlock+5; n++ {
+				var logs []types.Log
+				for range logsAt[n] {
+					id++
+					logs = append(logs, withdrawStartedLog(t, id))
+				}
+				chain.mine(logs...)
+				for i, log := range chain.logs[n] {
+					if n <= currentBlock && (n > lastBlock || i > 1) {
+						want = append(want, log)
+					}
+				}
+			}
+			chain.filterErr = func(q ethereum.FilterQuery) error {
+				if q.ToBlock.Uint64()-q.FromBlock.Uint64()+1 > maxRange {
+					return tooLarge(q)
+				}
+				// Slow down the start of the range so that later chunks
+				// complete first.
+				if q.FromBlock.Uint64() < 5000 {
+					time.Sleep(100 * time.Millisecond)
+				}
+				return nil
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			logsCh := make(chan types.Log)
+			done := make(chan error, 1)
+			go func() {
+				done <- backfillRange(ctx, chain, "test", []common.Address{testContract}, currentBlock, lastBlock, 1, nil, 4, logsCh)
+				close(logsCh)
+			}()
+			var got []types.Log
+			for log := range logsCh {
+				got = append(got, log)
+			}
+			require.NoError(t, <-done)
+			require.Equal(t, want, got)
+
+			refused := 0
+			for _, q := range chain.queries {
+				if q.ToBlock.Uint64()-q.FromBlock.Uint64()+1 > maxRange {
+					refused++
+				}
+			}
+			require.NotZero(t, refused, "no range was refused")
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package custody
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// blockTimeCacheSize bounds how many block timestamps a Listener remembers.
+// Events are delivered in block order, so only recent blocks are looked up again.
+const blockTimeCacheSize = 1024
+
+// blockTimeCache remembers the timestamps of recently seen blocks by hash. It
+// is shared by all streams of a Listener.
+type blockTimeCache struct {
+	mu      sync.Mutex
+	entries map[common.Hash]time.Time
+	order   []common.Hash
+}
+
+func newBlockTimeCache() *blockTimeCache {
+	return &blockTimeCache{entries: make(map[common.Hash]time.Time)}
+}
+
+func (c *blockTimeCache) get(hash common.Hash) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.entries[hash]
+	return t, ok
+}
+
+func (c *blockTimeCache) put(hash common.Hash, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[hash]; ok {
+		return
+	}
+	c.entries[hash] = t
+	c.order = append(c.order, hash)
+	if len(c.order) > blockTimeCacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// blockTime returns the timestamp of the block a log was emitted in. Nodes
+// that include blockTimestamp in eth_getLogs results save the header lookup.
+func (l *Listener) blockTime(ctx context.Context, log types.Log) (time.Time, error) {
+	if log.BlockTimestamp != 0 {
+		return time.Unix(int64(log.BlockTimestamp), 0).UTC(), nil
+	}
+	if t, ok := l.blockTimes.get(log.BlockHash); ok {
+		return t, nil
+	}
+
+	header, err := headerByNumber(ctx, l.client, new(big.Int).SetUint64(log.BlockNumber))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get header of block %d: %w", log.BlockNumber, err)
+	}
+	if header.Hash() != log.BlockHash {
+		// The block was reorged out since the log was fetched; the next
+		// canonical check rewinds the stream.
+		return time.Time{}, fmt.Errorf("block %d is no longer canonical", log.BlockNumber)
+	}
+
+	t := time.Unix(int64(header.Time), 0).UTC()
+	l.blockTimes.put(log.BlockHash, t)
+	return t, nil
+}
@@ -0,0 +1,122 @@
+package custody
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ConfirmationMode selects how the Listener decides that a block is confirmed.
+type ConfirmationMode string
+
+const (
+	// ConfirmDepth treats blocks with at least confirmationBlocks on top as confirmed.
+	ConfirmDepth ConfirmationMode = "depth"
+	// ConfirmSafe follows the node's "safe" block tag.
+	ConfirmSafe ConfirmationMode = "safe"
+	// ConfirmFinalized follows the node's "finalized" block tag.
+	ConfirmFinalized ConfirmationMode = "finalized"
+)
+
+// invalidParamsCode is the JSON-RPC error code of a request with invalid
+// parameters, which nodes that do not know a block tag answer with.
+const invalidParamsCode = -32602
+
+// confirmer computes the confirmed block of a stream. In safe or finalized
+// mode it falls back to depth-based confirmation for good if the node rejects
+// the block tag; other errors are returned so the stream retries.
+type confirmer struct {
+	subID string
+	mode  ConfirmationMode
+	depth uint64
+}
+
+func newConfirmer(subID string, mode ConfirmationMode, depth uint64) *confirmer {
+	if mode == "" {
+		mode = ConfirmDepth
+	}
+	c := &confirmer{subID: subID, mode: mode, depth: depth}
+	c.reportMode()
+	return c
+}
+
+// confirmedBlock returns the newest confirmed block given the latest head, or
+// ok=false if no block is confirmed yet.
+func (c *confirmer) confirmedBlock(ctx context.Context, client bind.ContractBackend, latest *types.Header) (block uint64, ok bool, err error) {
+	if c.mode != ConfirmDepth {
+		tag := rpc.SafeBlockNumber
+		if c.mode == ConfirmFinalized {
+			tag = rpc.FinalizedBlockNumber
+		}
+
+		header, err := headerByNumber(ctx, client, big.NewInt(tag.Int64()))
+		if err == nil {
+			return min(header.Number.Uint64(), latest.Number.Uint64()), true, nil
+		}
+		if errors.Is(err, ethereum.NotFound) {
+			// The node knows the tag but has not tagged a block yet.
+			return 0, false, nil
+		}
+		if !isUnsupportedTag(err) {
+			return 0, false, err
+		}
+
+		listenerLogger.Warnw("node does not support block tag, falling back to depth-based confirmation",
+			"subID", c.subID, "tag", c.mode, "confirmationBlocks", c.depth, "error", err)
+		c.mode = ConfirmDepth
+		c.reportMode()
+	}
+
+	latestBlock := latest.Number.Uint64()
+	if latestBlock < c.depth {
+		return 0, false, nil
+	}
+	return latestBlock - c.depth, true, nil
+}
+
+func (c *confirmer) reportMode() {
+	for _, mode := range []ConfirmationMode{ConfirmDepth, ConfirmSafe, ConfirmFinalized} {
+		value := 0.0
+		if mode == c.mode {
+			value = 1
+		}
+		confirmationModeGauge.WithLabelValues(c.subID, string(mode)).Set(value)
+	}
+}
+
+// isUnsupportedTag reports whether err means the node rejected the block tag,
+// as opposed to a failure worth retrying. An error that joins the errors of
+// several endpoints only qualifies if every endpoint rejected the tag.
+func isUnsupportedTag(err error) bool {
+	if rpcErr, ok := err.(rpc.Error); ok {
+		if rpcErr.ErrorCode() == invalidParamsCode {
+			return true
+		}
+		msg := strings.ToLower(rpcErr.Error())
+		return strings.Contains(msg, "invalid block tag") || strings.Contains(msg, "unknown block tag")
+	}
+
+	switch err := err.(type) {
+	case interface{ Unwrap() []error }:
+		found := false
+		for _, e := range err.Unwrap() {
+			if e == ErrNoQuorum {
+				continue
+			}
+			if !isUnsupportedTag(e) {
+				return false
+			}
+			found = true
+		}
+		return found
+	case interface{ Unwrap() error }:
+		return isUnsupportedTag(err.Unwrap())
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package custody
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// rpcError is a JSON-RPC error as returned by a node.
+type rpcError struct {
+	code int
+	msg  string
+}
+
+func (e rpcError) Error() string  { return e.msg }
+func (e rpcError) ErrorCode() int { return e.code }
+
+func TestConfirmer_FallsBackOnUnsupportedTag(t *testing.T) {
+	for name, err := range map[string]error{
+		"invalid params":    rpcError{code: invalidParamsCode, msg: "invalid argument 0: hex string without 0x prefix"},
+		"invalid block tag": rpcError{code: -32000, msg: "Invalid block tag"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := newFakeBackend(20, 0)
+			b.err = err
+			c := newConfirmer("test", ConfirmFinalized, 5)
+
+			block, ok, err := c.confirmedBlock(context.Background(), b, b.header(20))
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, uint64(15), block)
+			require.Equal(t, ConfirmDepth, c.mode)
+		})
+	}
+}
+
+func TestConfirmer_RetriesOtherErrors(t *testing.T) {
+	for name, err := range map[string]error{
+		"rate limited":   rpcError{code: -32005, msg: "request rate limited"},
+		"internal error": rpcError{code: -32603, msg: "internal error"},
+		"timeout":        context.DeadlineExceeded,
+		"transport":      errors.New("connection reset by peer"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := newFakeBackend(20, 0)
+			b.err = err
+			c := newConfirmer("test", ConfirmSafe, 5)
+
+			_, _, err := c.confirmedBlock(context.Background(), b, b.header(20))
+			require.Error(t, err)
+			require.Equal(t, ConfirmSafe, c.mode, "the confirmer does not fall back")
+
+			b.err = nil
+			b.tags[rpc.SafeBlockNumber] = 18
+			block, ok, err := c.confirmedBlock(context.Background(), b, b.header(20))
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, uint64(18), block)
+		})
+	}
+}
+
+func TestConfirmer_NoTaggedBlockYet(t *testing.T) {
+	b := newFakeBackend(20, 0)
+	b.tags[rpc.FinalizedBlockNumber] = 30
+	c := newConfirmer("test", ConfirmFinalized, 5)
+
+	_, ok, err := c.confirmedBlock(context.Background(), b, b.header(20))
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, ConfirmFinalized, c.mode)
+}
+
+func TestIsUnsupportedTag_Quorum(t *testing.T) {
+	unsupported := rpcError{code: invalidParamsCode, msg: "invalid argument"}
+	limited := rpcError{code: -32005, msg: "request rate limited"}
+	quorumErr := func(errs ...error) error {
+		for i, err := range errs {
+			errs[i] = fmt.Errorf("rpc-%d: %w", i, err)
+		}
+		return fmt.Errorf("%w for finalized header: 0 of 2 endpoints answered: %w", ErrNoQuorum, errors.Join(errs...))
+	}
+
+	require.True(t, isUnsupportedTag(quorumErr(unsupported, unsupported)))
+	require.False(t, isUnsupportedTag(quorumErr(unsupported, limited)), "one endpoint may support the tag")
+	require.False(t, isUnsupportedTag(ErrNoQuorum))
+}
@@ -0,0 +1,299 @@
+package custody
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type eventDecoder func(l *Listener, log types.Log, blockTime time.Time) (Event, error)
+
+// eventSpec describes a contract event the Listener can decode.
+type eventSpec struct {
+	meta   *bind.MetaData
+	name   string
+	decode eventDecoder
+}
+
+// eventSpecs lists every contract event the Listener can deliver. Events with
+// the same signature in several contracts (WithdrawalApproved) are listed once.
+var eventSpecs = []eventSpec{
+	{IWithdrawMetaData, "WithdrawStarted", decodeWithdrawStarted},
+	{IWithdrawMetaData, "WithdrawFinalized", decodeWithdrawFinalized},
+	{IDepositMetaData, "Deposited", decodeDeposited},
+	{ThresholdCustodyMetaData, "WithdrawalApproved", decodeWithdrawalApproved},
+	{QuorumCustodyMetaData, "SignerAdded", decodeSignerAdded},
+	{ThresholdCustodyMetaData, "ERC7913SignerAdded", decodeERC7913SignerAdded},
+	{QuorumCustodyMetaData, "SignerRemoved", decodeSignerRemoved},
+	{ThresholdCustodyMetaData, "ERC7913SignerRemoved", decodeERC7913SignerRemoved},
+	{QuorumCustodyMetaData, "QuorumChanged", decodeQuorumChanged},
+	{ThresholdCustodyMetaData, "ERC7913ThresholdSet", decodeERC7913ThresholdSet},
+	{ThresholdCustodyMetaData, "RateLimitUpdated", decodeRateLimitUpdated},
+}
+
+// eventDecoders maps the topic of each named event to its decoder.
+func (l *Listener) eventDecoders(names []string) (map[common.Hash]eventDecoder, error) {
+	decoders := make(map[common.Hash]eventDecoder, len(names))
+	for _, name := range names {
+		var spec *eventSpec
+		for i := range eventSpecs {
+			if eventSpecs[i].name == name {
+				spec = &eventSpecs[i]
+				break
+			}
+		}
+		if spec == nil {
+			return nil, fmt.Errorf("unknown event %s", name)
+		}
+
+		parsedABI, err := spec.meta.GetAbi()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ABI of event %s: %w", name, err)
+		}
+		event, ok := parsedABI.Events[name]
+		if !ok {
+			return nil, fmt.Errorf("event %s not found in ABI", name)
+		}
+		decoders[event.ID] = spec.decode
+	}
+	return decoders, nil
+}
+
+var errNoFilterer = errors.New("listener cannot decode this event")
+
+func decodeWithdrawStarted(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.withdrawFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.withdrawFilterer.ParseWithdrawStarted(log)
+	if err != nil {
+		return nil, err
+	}
+	return &WithdrawStartedEvent{
+		WithdrawalID: ev.WithdrawalId,
+		User:         ev.User,
+		Token:        ev.Token,
+		Amount:       ev.Amount,
+		Nonce:        ev.Nonce,
+		Contract:     ev.Raw.Address,
+		BlockNumber:  ev.Raw.BlockNumber,
+		BlockHash:    ev.Raw.BlockHash,
+		BlockTime:    blockTime,
+		TxHash:       ev.Raw.TxHash,
+		LogIndex:     ev.Raw.Index,
+		ackHandle:    l.newAckHandle(),
+	}, nil
+}
+
+func decodeWithdrawFinalized(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.withdrawFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.withdrawFilterer.ParseWithdrawFinalized(log)
+	if err != nil {
+		return nil, err
+	}
+	return &WithdrawFinalizedEvent{
+		WithdrawalID: ev.WithdrawalId,
+		Success:      ev.Success,
+		Contract:     ev.Raw.Address,
+		BlockNumber:  ev.Raw.BlockNumber,
+		BlockHash:    ev.Raw.BlockHash,
+		BlockTime:    blockTime,
+		TxHash:       ev.Raw.TxHash,
+		LogIndex:     ev.Raw.Index,
+		ackHandle:    l.newAckHandle(),
+	}, nil
+}
+
+func decodeDeposited(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.depositFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.depositFilterer.ParseDeposited(log)
+	if err != nil {
+		return nil, err
+	}
+	return &DepositedEvent{
+		User:        ev.User,
+		Token:       ev.Token,
+		Amount:      ev.Amount,
+		Contract:    ev.Raw.Address,
+		BlockNumber: ev.Raw.BlockNumber,
+		BlockHash:   ev.Raw.BlockHash,
+		BlockTime:   blockTime,
+		TxHash:      ev.Raw.TxHash,
+		LogIndex:    ev.Raw.Index,
+		ackHandle:   l.newAckHandle(),
+	}, nil
+}
+
+func decodeWithdrawalApproved(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.thresholdFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.thresholdFilterer.ParseWithdrawalApproved(log)
+	if err != nil {
+		return nil, err
+	}
+	return &WithdrawalApprovedEvent{
+		WithdrawalID:     ev.WithdrawalId,
+		Signer:           ev.Signer,
+		CurrentApprovals: ev.CurrentApprovals,
+		Contract:         ev.Raw.Address,
+		BlockNumber:      ev.Raw.BlockNumber,
+		BlockHash:        ev.Raw.BlockHash,
+		BlockTime:        blockTime,
+		TxHash:           ev.Raw.TxHash,
+		LogIndex:         ev.Raw.Index,
+		ackHandle:        l.newAckHandle(),
+	}, nil
+}
+
+func decodeSignerAdded(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.quorumFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.quorumFilterer.ParseSignerAdded(log)
+	if err != nil {
+		return nil, err
+	}
+	return &SignerAddedEvent{
+		Signer:      ev.Signer,
+		NewQuorum:   ev.NewQuorum,
+		Contract:    ev.Raw.Address,
+		BlockNumber: ev.Raw.BlockNumber,
+		BlockHash:   ev.Raw.BlockHash,
+		BlockTime:   blockTime,
+		TxHash:      ev.Raw.TxHash,
+		LogIndex:    ev.Raw.Index,
+		ackHandle:   l.newAckHandle(),
+	}, nil
+}
+
+func decodeERC7913SignerAdded(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.thresholdFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.thresholdFilterer.ParseERC7913SignerAdded(log)
+	if err != nil {
+		return nil, err
+	}
+	return &SignerAddedEvent{
+		SignerHash:  ev.Signers,
+		Contract:    ev.Raw.Address,
+		BlockNumber: ev.Raw.BlockNumber,
+		BlockHash:   ev.Raw.BlockHash,
+		BlockTime:   blockTime,
+		TxHash:      ev.Raw.TxHash,
+		LogIndex:    ev.Raw.Index,
+		ackHandle:   l.newAckHandle(),
+	}, nil
+}
+
+func decodeSignerRemoved(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.quorumFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.quorumFilterer.ParseSignerRemoved(log)
+	if err != nil {
+		return nil, err
+	}
+	return &SignerRemovedEvent{
+		Signer:      ev.Signer,
+		NewQuorum:   ev.NewQuorum,
+		Contract:    ev.Raw.Address,
+		BlockNumber: ev.Raw.BlockNumber,
+		BlockHash:   ev.Raw.BlockHash,
+		BlockTime:   blockTime,
+		TxHash:      ev.Raw.TxHash,
+		LogIndex:    ev.Raw.Index,
+		ackHandle:   l.newAckHandle(),
+	}, nil
+}
+
+func decodeERC7913SignerRemoved(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.thresholdFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.thresholdFilterer.ParseERC7913SignerRemoved(log)
+	if err != nil {
+		return nil, err
+	}
+	return &SignerRemovedEvent{
+		SignerHash:  ev.Signers,
+		Contract:    ev.Raw.Address,
+		BlockNumber: ev.Raw.BlockNumber,
+		BlockHash:   ev.Raw.BlockHash,
+		BlockTime:   blockTime,
+		TxHash:      ev.Raw.TxHash,
+		LogIndex:    ev.Raw.Index,
+		ackHandle:   l.newAckHandle(),
+	}, nil
+}
+
+func decodeQuorumChanged(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.quorumFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.quorumFilterer.ParseQuorumChanged(log)
+	if err != nil {
+		return nil, err
+	}
+	return &QuorumChangedEvent{
+		OldQuorum:   ev.OldQuorum,
+		NewQuorum:   ev.NewQuorum,
+		Contract:    ev.Raw.Address,
+		BlockNumber: ev.Raw.BlockNumber,
+		BlockHash:   ev.Raw.BlockHash,
+		BlockTime:   blockTime,
+		TxHash:      ev.Raw.TxHash,
+		LogIndex:    ev.Raw.Index,
+		ackHandle:   l.newAckHandle(),
+	}, nil
+}
+
+func decodeERC7913ThresholdSet(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.thresholdFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.thresholdFilterer.ParseERC7913ThresholdSet(log)
+	if err != nil {
+		return nil, err
+	}
+	return &QuorumChangedEvent{
+		NewQuorum:   ev.Threshold,
+		Contract:    ev.Raw.Address,
+		BlockNumber: ev.Raw.BlockNumber,
+		BlockHash:   ev.Raw.BlockHash,
+		BlockTime:   blockTime,
+		TxHash:      ev.Raw.TxHash,
+		LogIndex:    ev.Raw.Index,
+		ackHandle:   l.newAckHandle(),
+	}, nil
+}
+
+func decodeRateLimitUpdated(l *Listener, log types.Log, blockTime time.Time) (Event, error) {
+	if l.thresholdFilterer == nil {
+		return nil, errNoFilterer
+	}
+	ev, err := l.thresholdFilterer.ParseRateLimitUpdated(log)
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimitUpdatedEvent{
+		NewCapacity:       ev.NewCapacity,
+		NewRefillInterval: ev.NewRefillInterval,
+		Contract:          ev.Raw.Address,
+		BlockNumber:       ev.Raw.BlockNumber,
+		BlockHash:         ev.Raw.BlockHash,
+		BlockTime:         blockTime,
+		TxHash:            ev.Raw.TxHash,
+		LogIndex:          ev.Raw.Index,
+		ackHandle:         l.newAckHandle(),
+	}, nil
+}
@@ -0,0 +1,200 @@
+package custody
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// watchFirst runs a stream from the start of the chain and returns its first
+// event.
+func watchFirst[E Event](t *testing.T, watch func(context.Context, chan<- E, chan<- *ReorgEvent, Cursor) error) Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	sink := make(chan E)
+	done := make(chan error, 1)
+	go func() { done <- watch(ctx, sink, nil, Cursor{}) }()
+	defer func() {
+		cancel()
+		for range sink {
+		}
+		<-done
+	}()
+	return receive(t, sink)
+}
+
+func TestEventDecoding(t *testing.T) {
+	signer := common.HexToAddress("0x5e")
+	signerKey := []byte{0x04, 0x01, 0x02, 0x03}
+
+	tests := []struct {
+		name  string
+		meta  *bind.MetaData
+		args  []any
+		want  func(log types.Log, blockTime time.Time) Event
+		watch func(t *testing.T, l *Listener) Event
+	}{
+		{
+			name: "WithdrawStarted",
+			meta: IWithdrawMetaData,
+			args: []any{[32]byte{1}, testUser, testToken, big.NewInt(100), big.NewInt(7)},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &WithdrawStartedEvent{
+					WithdrawalID: [32]byte{1}, User: testUser, Token: testToken, Amount: big.NewInt(100), Nonce: big.NewInt(7),
+					Contract: log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchWithdrawStarted) },
+		},
+		{
+			name: "WithdrawFinalized",
+			meta: IWithdrawMetaData,
+			args: []any{[32]byte{1}, true},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &WithdrawFinalizedEvent{
+					WithdrawalID: [32]byte{1}, Success: true,
+					Contract: log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchWithdrawFinalized) },
+		},
+		{
+			name: "Deposited",
+			meta: IDepositMetaData,
+			args: []any{testUser, testToken, big.NewInt(500)},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &DepositedEvent{
+					User: testUser, Token: testToken, Amount: big.NewInt(500),
+					Contract: log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchDeposited) },
+		},
+		{
+			name: "WithdrawalApproved",
+			meta: ThresholdCustodyMetaData,
+			args: []any{[32]byte{1}, signer, big.NewInt(2)},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &WithdrawalApprovedEvent{
+					WithdrawalID: [32]byte{1}, Signer: signer, CurrentApprovals: big.NewInt(2),
+					Contract: log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchWithdrawalApproved) },
+		},
+		{
+			name: "SignerAdded",
+			meta: QuorumCustodyMetaData,
+			args: []any{signer, uint64(3)},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &SignerAddedEvent{
+					Signer: signer, NewQuorum: 3,
+					Contract: log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchSignerAdded) },
+		},
+		{
+			name: "ERC7913SignerAdded",
+			meta: ThresholdCustodyMetaData,
+			args: []any{signerKey},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &SignerAddedEvent{
+					SignerHash: crypto.Keccak256Hash(signerKey),
+					Contract:   log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchSignerAdded) },
+		},
+		{
+			name: "SignerRemoved",
+			meta: QuorumCustodyMetaData,
+			args: []any{signer, uint64(1)},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &SignerRemovedEvent{
+					Signer: signer, NewQuorum: 1,
+					Contract: log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchSignerRemoved) },
+		},
+		{
+			name: "ERC7913SignerRemoved",
+			meta: ThresholdCustodyMetaData,
+			args: []any{signerKey},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &SignerRemovedEvent{
+					SignerHash: crypto.Keccak256Hash(signerKey),
+					Contract:   log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchSignerRemoved) },
+		},
+		{
+			name: "QuorumChanged",
+			meta: QuorumCustodyMetaData,
+			args: []any{uint64(2), uint64(3)},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &QuorumChangedEvent{
+					OldQuorum: 2, NewQuorum: 3,
+					Contract: log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchQuorumChanged) },
+		},
+		{
+			name: "ERC7913ThresholdSet",
+			meta: ThresholdCustodyMetaData,
+			args: []any{uint64(4)},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &QuorumChangedEvent{
+					NewQuorum: 4,
+					Contract:  log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchQuorumChanged) },
+		},
+		{
+			name: "RateLimitUpdated",
+			meta: ThresholdCustodyMetaData,
+			args: []any{big.NewInt(1000), big.NewInt(3600)},
+			want: func(log types.Log, blockTime time.Time) Event {
+				return &RateLimitUpdatedEvent{
+					NewCapacity: big.NewInt(1000), NewRefillInterval: big.NewInt(3600),
+					Contract: log.Address, BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, BlockTime: blockTime, TxHash: log.TxHash, LogIndex: log.Index,
+				}
+			},
+			watch: func(t *testing.T, l *Listener) Event { return watchFirst(t, l.WatchRateLimitUpdated) },
+		},
+	}
+
+	covered := make(map[string]bool, len(tests))
+	for _, tt := range tests {
+		covered[tt.name] = true
+		t.Run(tt.name, func(t *testing.T) {
+			chain := newFakeChain(2)
+			chain.mine(eventLog(t, tt.meta, tt.name, tt.args...))
+			chain.mine()
+			log := chain.logs[3][0]
+			blockTime := time.Unix(int64(log.BlockTimestamp), 0).UTC()
+			want := tt.want(log, blockTime)
+			l := newTestListener(t, chain)
+
+			decoded, err := l.DecodeLog(log, blockTime)
+			require.NoError(t, err)
+			require.Equal(t, want, decoded)
+
+			require.Equal(t, want, tt.watch(t, l))
+		})
+	}
+	for _, spec := range eventSpecs {
+		require.True(t, covered[spec.name], "event %s is not tested", spec.name)
+	}
+}
@@ -0,0 +1,124 @@
+package custody
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// resubscribeInterval is how long a stream polls after its head subscription
+// failed before it tries to subscribe again.
+var resubscribeInterval = 30 * time.Second
+
+// headSubscriber is implemented by clients that push new chain heads, such as
+// *ethclient.Client connected over WebSocket.
+type headSubscriber interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// headWatcher paces the confirmed-block cycles of a stream. While a newHeads
+// subscription is alive, a cycle runs as soon as the node announces a head;
+// whenever the subscription is unavailable it falls back to running a cycle
+// every pollInterval and periodically tries to resubscribe. Gaps left by a
+// dropped subscription need no special handling since every cycle scans from
+// the stream cursor up to the confirmed head.
+type headWatcher struct {
+	subID        string
+	subscriber   headSubscriber
+	pollInterval time.Duration
+	ticker       *time.Ticker
+
+	heads         chan *types.Header
+	sub           ethereum.Subscription
+	lastHead      time.Time
+	nextSubscribe time.Time
+}
+
+// newHeadWatcher creates a headWatcher. A nil subscriber means poll-only.
+func newHeadWatcher(subID string, subscriber headSubscriber, pollInterval time.Duration) *headWatcher {
+	return &headWatcher{
+		subID:        subID,
+		subscriber:   subscriber,
+		pollInterval: pollInterval,
+		ticker:       time.NewTicker(pollInterval),
+		heads:        make(chan *types.Header, 16),
+	}
+}
+
+// next blocks until the next cycle should run. It returns the head pushed by
+// the subscription, or nil if the caller has to fetch the latest header
+// itself. ok is false once ctx is done.
+func (w *headWatcher) next(ctx context.Context) (head *types.Header, ok bool) {
+	for {
+		w.subscribe(ctx)
+
+		var subErr <-chan error
+		if w.sub != nil {
+			subErr = w.sub.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case head = <-w.heads:
+			// Only the newest head matters if several queued up meanwhile.
+			for drained := false; !drained; {
+				select {
+				case newer := <-w.heads:
+					head = newer
+				default:
+					drained = true
+				}
+			}
+			w.lastHead = time.Now()
+			return head, true
+		case err := <-subErr:
+			listenerLogger.Warnw("head subscription dropped, falling back to polling", "subID", w.subID, "error", err)
+			w.sub.Unsubscribe()
+			w.sub = nil
+			w.nextSubscribe = time.Now().Add(resubscribeInterval)
+			return nil, true
+		case <-w.ticker.C:
+			// A live subscription that keeps delivering heads makes polling
+			// redundant; poll only if it has gone quiet.
+			if w.sub != nil && time.Since(w.lastHead) < w.pollInterval {
+				continue
+			}
+			return nil, true
+		}
+	}
+}
+
+func (w *headWatcher) subscribe(ctx context.Context) {
+	if w.subscriber == nil || w.sub != nil || time.Now().Before(w.nextSubscribe) {
+		return
+	}
+
+	sub, err := w.subscriber.SubscribeNewHead(ctx, w.heads)
+	if err != nil {
+		if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+			listenerLogger.Warnw("client does not support head subscriptions, polling only", "subID", w.subID)
+			w.subscriber = nil
+			return
+		}
+		listenerLogger.Warnw("failed to subscribe to new heads, polling meanwhile", "subID", w.subID, "error", err, "retryIn", resubscribeInterval)
+		w.nextSubscribe = time.Now().Add(resubscribeInterval)
+		return
+	}
+
+	listenerLogger.Infow("subscribed to new heads", "subID", w.subID)
+	w.sub = sub
+	w.lastHead = time.Now()
+}
+
+func (w *headWatcher) stop() {
+	w.ticker.Stop()
+	if w.sub != nil {
+		w.sub.Unsubscribe()
+		w.sub = nil
+	}
+}
@@ -0,0 +1,173 @@
+package custody
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHeadSubscriber fails its first SubscribeNewHead calls with errs and
+// accepts the others. The test pushes heads and drops the subscription.
+type fakeHeadSubscriber struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+	heads chan<- *types.Header
+	drop  chan error
+}
+
+func (f *fakeHeadSubscriber) SubscribeNewHead(_ context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	f.heads = ch
+	drop := make(chan error, 1)
+	f.drop = drop
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		select {
+		case <-quit:
+			return nil
+		case err := <-drop:
+			return err
+		}
+	}), nil
+}
+
+func (f *fakeHeadSubscriber) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *fakeHeadSubscriber) push(numbers ...int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, n := range numbers {
+		f.heads <- &types.Header{Number: big.NewInt(n)}
+	}
+}
+
+// shortenResubscribeInterval makes a failed head subscription retried within
+// milliseconds for the duration of the test.
+func shortenResubscribeInterval(t *testing.T) time.Duration {
+	interval := resubscribeInterval
+	resubscribeInterval = 100 * time.Millisecond
+	t.Cleanup(func() { resubscribeInterval = interval })
+	return resubscribeInterval
+}
+
+func TestHeadWatcher_Subscription(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	subscriber := &fakeHeadSubscriber{}
+	w := newHeadWatcher("test", subscriber, 50*time.Millisecond)
+	defer w.stop()
+
+	w.subscribe(ctx)
+	subscriber.push(5, 6, 7)
+	head, ok := w.next(ctx)
+	require.True(t, ok)
+	require.NotNil(t, head)
+	require.Equal(t, int64(7), head.Number.Int64(), "queued heads were not skipped")
+
+	// A subscription that goes quiet is backed up by polling.
+	head, ok = w.next(ctx)
+	require.True(t, ok)
+	require.Nil(t, head)
+	require.Equal(t, 1, subscriber.callCount())
+
+	cancel()
+	_, ok = w.next(ctx)
+	require.False(t, ok)
+}
+
+func TestHeadWatcher_PollsWhileSubscribeFails(t *testing.T) {
+	interval := shortenResubscribeInterval(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	subscriber := &fakeHeadSubscriber{errs: []error{errors.New("connection refused")}}
+	w := newHeadWatcher("test", subscriber, 10*time.Millisecond)
+	defer w.stop()
+
+	failed := time.Now()
+	for subscriber.callCount() < 2 {
+		head, ok := w.next(ctx)
+		require.True(t, ok)
+		require.Nil(t, head)
+	}
+	require.GreaterOrEqual(t, time.Since(failed), interval, "resubscribed too early")
+
+	subscriber.push(8)
+	requireHead(ctx, t, w, 8)
+}
+
+func TestHeadWatcher_PollsAfterSubscriptionDrops(t *testing.T) {
+	interval := shortenResubscribeInterval(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	subscriber := &fakeHeadSubscriber{}
+	w := newHeadWatcher("test", subscriber, 10*time.Millisecond)
+	defer w.stop()
+
+	w.subscribe(ctx)
+	subscriber.drop <- errors.New("connection reset")
+	dropped := time.Now()
+	for w.sub != nil {
+		head, ok := w.next(ctx)
+		require.True(t, ok)
+		require.Nil(t, head)
+	}
+
+	for subscriber.callCount() < 2 {
+		head, ok := w.next(ctx)
+		require.True(t, ok)
+		require.Nil(t, head)
+	}
+	require.GreaterOrEqual(t, time.Since(dropped), interval, "resubscribed too early")
+
+	subscriber.push(9)
+	requireHead(ctx, t, w, 9)
+}
+
+func TestHeadWatcher_NotificationsUnsupported(t *testing.T) {
+	shortenResubscribeInterval(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	subscriber := &fakeHeadSubscriber{errs: []error{rpc.ErrNotificationsUnsupported}}
+	w := newHeadWatcher("test", subscriber, 10*time.Millisecond)
+	defer w.stop()
+
+	for deadline := time.Now().Add(3 * resubscribeInterval); time.Now().Before(deadline); {
+		head, ok := w.next(ctx)
+		require.True(t, ok)
+		require.Nil(t, head)
+	}
+	require.Equal(t, 1, subscriber.callCount(), "resubscribed to a client without notifications")
+}
+
+// requireHead calls next until it returns a head, which must be number n.
+func requireHead(ctx context.Context, t *testing.T, w *headWatcher, n int64) {
+	t.Helper()
+	for {
+		head, ok := w.next(ctx)
+		require.True(t, ok, "no head received")
+		if head != nil {
+			require.Equal(t, n, head.Number.Int64())
+			return
+		}
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"math"
 	"math/big"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -23,36 +26,132 @@ var listenerLogger = logging.Logger("custody-listener")
 
 const (
 	maxBackOffCount = 5
+	// blockStep is the block range of a single eth_getLogs query.
+	blockStep = 10000
 )
 
+// ErrBackOffExceeded is returned by the Watch methods when a stream gave up
+// after maxBackOffCount consecutive RPC failures.
+var ErrBackOffExceeded = errors.New("back off limit reached")
+
+// ErrRedeliveryExceeded is returned by the Watch methods when the consumer
+// still failed to process an event after it was redelivered maxRedeliveries
+// times.
+var ErrRedeliveryExceeded = errors.New("redelivery limit reached")
+
 // Listener handles monitoring the blockchain for events from the custody contract.
 type Listener struct {
 	client             bind.ContractBackend
-	contractAddr       common.Address
+	name               string
+	contracts          []common.Address
 	confirmationBlocks uint64
 	pollInterval       time.Duration
+	subscribeHeads     bool
+	confirmationMode   ConfirmationMode
+	acknowledgements   bool
+	backfillWorkers    int
 	withdrawFilterer   *IWithdrawFilterer
 	depositFilterer    *IDepositFilterer
+	thresholdFilterer  *ThresholdCustodyFilterer
+	quorumFilterer     *QuorumCustodyFilterer
+	blockTimes         *blockTimeCache
+	deadLetters        DeadLetterStore
+}
+
+// ListenerOption configures optional Listener behaviour.
+type ListenerOption func(*Listener)
+
+// WithHeadSubscription makes the Listener run a confirmed-block cycle as soon
+// as the node announces a new head instead of waiting for the next poll. It
+// requires a client that supports newHeads subscriptions (e.g. *ethclient.Client
+// over WebSocket). Polling at pollInterval takes over whenever the
+// subscription is unavailable or stalls, and resubscribing is retried.
+func WithHeadSubscription() ListenerOption {
+	return func(l *Listener) {
+		l.subscribeHeads = true
+	}
+}
+
+// WithConfirmationMode selects how blocks are considered confirmed. In
+// ConfirmSafe and ConfirmFinalized mode the Listener follows the node's block
+// tags and falls back to depth-based confirmation if the node lacks them.
+func WithConfirmationMode(mode ConfirmationMode) ListenerOption {
+	return func(l *Listener) {
+		l.confirmationMode = mode
+	}
+}
+
+// WithAcknowledgements makes every delivered event carry an acknowledgement
+// handle that the consumer must call (see EventListener).
+func WithAcknowledgements() ListenerOption {
+	return func(l *Listener) {
+		l.acknowledgements = true
+	}
+}
+
+// WithBackfillConcurrency makes the Listener fetch block ranges longer than a
+// single query with up to n parallel eth_getLogs calls, e.g. when catching up
+// after an outage or from an old start block. Logs are still delivered in
+// block and log order. n <= 1 keeps the sequential behaviour.
+func WithBackfillConcurrency(n int) ListenerOption {
+	return func(l *Listener) {
+		l.backfillWorkers = n
+	}
+}
+
+// WithDeadLetterStore makes the Listener persist logs it cannot decode to
+// store before moving past them. If saving fails, the stream retries the log
+// rather than skipping it. Without a store, such logs are only logged.
+func WithDeadLetterStore(store DeadLetterStore) ListenerOption {
+	return func(l *Listener) {
+		l.deadLetters = store
+	}
+}
+
+// WithName prefixes the stream IDs the Listener uses in logs, metrics and dead
+// letters with name, e.g. "mainnet/withdraw-started", to tell apart Listeners
+// of different chains in one process.
+func WithName(name string) ListenerOption {
+	return func(l *Listener) {
+		l.name = name
+	}
+}
+
+// WithContracts makes the Listener watch the custody contracts at addrs in
+// addition to the one it was created for. Their events are delivered on the
+// same streams, in chain order, and carry the address of the emitting
+// contract. All contracts must implement the watched events.
+func WithContracts(addrs ...common.Address) ListenerOption {
+	return func(l *Listener) {
+		for _, addr := range addrs {
+			if !slices.Contains(l.contracts, addr) {
+				l.contracts = append(l.contracts, addr)
+			}
+		}
+	}
 }
 
 // NewListener creates a new Listener instance.
 // client: an Ethereum client supporting log subscriptions (e.g. *ethclient.Client via WebSocket)
-// contractAddr: address of the custody contract
-// confirmationBlocks: number of blocks to wait before processing events (must be > 0)
+// contractAddr: address of the custody contract (see WithContracts to watch more than one)
+// confirmationBlocks: number of blocks to wait before processing events (must be > 0); also
+// used as fallback when a block-tag confirmation mode is not supported by the node
 // pollInterval: how often to poll for confirmed blocks (defaults to 12s if <= 0)
 // withdraw: bound IWithdraw contract instance
 // deposit: bound IDeposit contract instance (can be nil if deposit events are not needed)
+// opts: optional behaviour such as WithHeadSubscription, WithConfirmationMode or WithAcknowledgements
 const defaultPollInterval = 12 * time.Second
 
-func NewListener(client bind.ContractBackend, contractAddr common.Address, confirmationBlocks uint64, pollInterval time.Duration, withdraw *IWithdraw, deposit *IDeposit) *Listener {
+func NewListener(client bind.ContractBackend, contractAddr common.Address, confirmationBlocks uint64, pollInterval time.Duration, withdraw *IWithdraw, deposit *IDeposit, opts ...ListenerOption) *Listener {
 	if pollInterval <= 0 {
 		pollInterval = defaultPollInterval
 	}
 	l := &Listener{
 		client:             client,
-		contractAddr:       contractAddr,
+		contracts:          []common.Address{contractAddr},
 		confirmationBlocks: confirmationBlocks,
 		pollInterval:       pollInterval,
+		blockTimes:         newBlockTimeCache(),
 	}
 	if withdraw != nil {
 		l.withdrawFilterer = &withdraw.IWithdrawFilterer
@@ -60,6 +159,17 @@ func NewListener(client bind.ContractBackend, contractAddr common.Address, confi
 	if deposit != nil {
 		l.depositFilterer = &deposit.IDepositFilterer
 	}
+	// Approval and governance events are only emitted by the multi-signer
+	// custody contracts; their filterers are used for decoding only.
+	if f, err := NewThresholdCustodyFilterer(contractAddr, client); err == nil {
+		l.thresholdFilterer = f
+	}
+	if f, err := NewQuorumCustodyFilterer(contractAddr, client); err == nil {
+		l.quorumFilterer = f
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
 	return l
 }
 
@@ -67,170 +177,352 @@ func NewListener(client bind.ContractBackend, contractAddr common.Address, confi
 var _ EventListener = (*Listener)(nil)
 
 // WatchWithdrawStarted subscribes to WithdrawStarted events and sends them to the sink channel.
-// This function blocks forever; run it in a goroutine. The sink channel is closed when it returns.
-func (l *Listener) WatchWithdrawStarted(ctx context.Context, sink chan<- *WithdrawStartedEvent, fromBlock uint64, fromLogIndex uint32) {
-	defer close(sink)
+func (l *Listener) WatchWithdrawStarted(ctx context.Context, sink chan<- *WithdrawStartedEvent, reorgs chan<- *ReorgEvent, from Cursor) error {
+	return watchEvents(ctx, l, "withdraw-started", sink, reorgs, from, "WithdrawStarted")
+}
 
-	parsedABI, err := IWithdrawMetaData.GetAbi()
-	if err != nil {
-		return
+// WatchWithdrawFinalized subscribes to WithdrawFinalized events and sends them to the sink channel.
+func (l *Listener) WatchWithdrawFinalized(ctx context.Context, sink chan<- *WithdrawFinalizedEvent, reorgs chan<- *ReorgEvent, from Cursor) error {
+	return watchEvents(ctx, l, "withdraw-finalized", sink, reorgs, from, "WithdrawFinalized")
+}
+
+// WatchDeposited subscribes to Deposited events and sends them to the sink channel.
+func (l *Listener) WatchDeposited(ctx context.Context, sink chan<- *DepositedEvent, reorgs chan<- *ReorgEvent, from Cursor) error {
+	if l.depositFilterer == nil {
+		close(sink)
+		closeReorgs(reorgs)
+		return errors.New("listener has no deposit contract")
 	}
-	topic := parsedABI.Events["WithdrawStarted"].ID
+	return watchEvents(ctx, l, "deposited", sink, reorgs, from, "Deposited")
+}
 
-	listenEvents(ctx, l.client, "withdraw-started", l.contractAddr, l.confirmationBlocks, l.pollInterval, fromBlock, fromLogIndex,
-		[][]common.Hash{{topic}},
-		func(log types.Log) {
-			ev, err := l.withdrawFilterer.ParseWithdrawStarted(log)
-			if err != nil {
-				return
-			}
-			sink <- &WithdrawStartedEvent{
-				WithdrawalID: ev.WithdrawalId,
-				User:         ev.User,
-				Token:        ev.Token,
-				Amount:       ev.Amount,
-				Nonce:        ev.Nonce,
-				BlockNumber:  ev.Raw.BlockNumber,
-				TxHash:       ev.Raw.TxHash,
-				LogIndex:     ev.Raw.Index,
-			}
-		},
-	)
+// WatchWithdrawalApproved subscribes to the approvals signers give to withdrawals
+// (ThresholdCustody and QuorumCustody) and sends them to the sink channel.
+func (l *Listener) WatchWithdrawalApproved(ctx context.Context, sink chan<- *WithdrawalApprovedEvent, reorgs chan<- *ReorgEvent, from Cursor) error {
+	return watchEvents(ctx, l, "withdrawal-approved", sink, reorgs, from, "WithdrawalApproved")
 }
 
-// WatchWithdrawFinalized subscribes to WithdrawFinalized events and sends them to the sink channel.
-// This function blocks forever; run it in a goroutine. The sink channel is closed when it returns.
-func (l *Listener) WatchWithdrawFinalized(ctx context.Context, sink chan<- *WithdrawFinalizedEvent, fromBlock uint64, fromLogIndex uint32) {
+// WatchSignerAdded subscribes to signer additions (SignerAdded and
+// ERC7913SignerAdded) and sends them to the sink channel.
+func (l *Listener) WatchSignerAdded(ctx context.Context, sink chan<- *SignerAddedEvent, reorgs chan<- *ReorgEvent, from Cursor) error {
+	return watchEvents(ctx, l, "signer-added", sink, reorgs, from, "SignerAdded", "ERC7913SignerAdded")
+}
+
+// WatchSignerRemoved subscribes to signer removals (SignerRemoved and
+// ERC7913SignerRemoved) and sends them to the sink channel.
+func (l *Listener) WatchSignerRemoved(ctx context.Context, sink chan<- *SignerRemovedEvent, reorgs chan<- *ReorgEvent, from Cursor) error {
+	return watchEvents(ctx, l, "signer-removed", sink, reorgs, from, "SignerRemoved", "ERC7913SignerRemoved")
+}
+
+// WatchQuorumChanged subscribes to quorum and threshold changes (QuorumChanged
+// and ERC7913ThresholdSet) and sends them to the sink channel.
+func (l *Listener) WatchQuorumChanged(ctx context.Context, sink chan<- *QuorumChangedEvent, reorgs chan<- *ReorgEvent, from Cursor) error {
+	return watchEvents(ctx, l, "quorum-changed", sink, reorgs, from, "QuorumChanged", "ERC7913ThresholdSet")
+}
+
+// WatchRateLimitUpdated subscribes to RateLimitUpdated events and sends them to the sink channel.
+func (l *Listener) WatchRateLimitUpdated(ctx context.Context, sink chan<- *RateLimitUpdatedEvent, reorgs chan<- *ReorgEvent, from Cursor) error {
+	return watchEvents(ctx, l, "rate-limit-updated", sink, reorgs, from, "RateLimitUpdated")
+}
+
+// WatchAll subscribes to all custody events at once and sends them to the sink
+// channel in chain order, by block number and then log index. Every block
+// range is fetched with a single query covering all event topics, and the
+// stream has one cursor. Deposited events are only included if the Listener
+// was created with a deposit contract. Consumers switch on the concrete event
+// type, e.g. *WithdrawStartedEvent or *WithdrawalApprovedEvent.
+func (l *Listener) WatchAll(ctx context.Context, sink chan<- Event, reorgs chan<- *ReorgEvent, from Cursor) error {
+	names := make([]string, 0, len(eventSpecs))
+	for _, spec := range eventSpecs {
+		if spec.name == "Deposited" && l.depositFilterer == nil {
+			continue
+		}
+		names = append(names, spec.name)
+	}
+	return watchEvents(ctx, l, "all", sink, reorgs, from, names...)
+}
+
+// watchEvents runs a stream over the named contract events and sends them,
+// decoded, to sink. Every named event must decode to E.
+func watchEvents[E Event](ctx context.Context, l *Listener, subID string, sink chan<- E, reorgs chan<- *ReorgEvent, from Cursor, names ...string) error {
 	defer close(sink)
+	defer closeReorgs(reorgs)
 
-	parsedABI, err := IWithdrawMetaData.GetAbi()
+	if l.name != "" {
+		subID = l.name + "/" + subID
+	}
+	decoders, err := l.eventDecoders(names)
 	if err != nil {
-		return
+		return err
+	}
+	topics := make([]common.Hash, 0, len(decoders))
+	for topic := range decoders {
+		topics = append(topics, topic)
 	}
-	topic := parsedABI.Events["WithdrawFinalized"].ID
 
-	listenEvents(ctx, l.client, "withdraw-finalized", l.contractAddr, l.confirmationBlocks, l.pollInterval, fromBlock, fromLogIndex,
-		[][]common.Hash{{topic}},
-		func(log types.Log) {
-			ev, err := l.withdrawFilterer.ParseWithdrawFinalized(log)
+	return l.listenEvents(ctx, subID, from, reorgs,
+		[][]common.Hash{topics},
+		func(log types.Log) (Event, error) {
+			if len(log.Topics) == 0 {
+				return nil, l.deadLetter(subID, log, "log has no topics")
+			}
+			decode, ok := decoders[log.Topics[0]]
+			if !ok {
+				return nil, l.deadLetter(subID, log, fmt.Sprintf("unexpected topic %s", log.Topics[0].Hex()))
+			}
+			blockTime, err := l.blockTime(ctx, log)
 			if err != nil {
-				return
+				return nil, err
 			}
-			sink <- &WithdrawFinalizedEvent{
-				WithdrawalID: ev.WithdrawalId,
-				Success:      ev.Success,
-				BlockNumber:  ev.Raw.BlockNumber,
-				TxHash:       ev.Raw.TxHash,
-				LogIndex:     ev.Raw.Index,
+			ev, err := decode(l, log, blockTime)
+			if err != nil {
+				return nil, l.deadLetter(subID, log, fmt.Sprintf("failed to decode log: %v", err))
+			}
+			event, ok := ev.(E)
+			if !ok {
+				return nil, l.deadLetter(subID, log, fmt.Sprintf("unexpected event type %T", ev))
 			}
+			sink <- event
+			return event, nil
 		},
 	)
 }
 
-// WatchDeposited subscribes to Deposited events and sends them to the sink channel.
-// This function blocks forever; run it in a goroutine. The sink channel is closed when it returns.
-func (l *Listener) WatchDeposited(ctx context.Context, sink chan<- *DepositedEvent, fromBlock uint64, fromLogIndex uint32) {
-	defer close(sink)
-
-	if l.depositFilterer == nil {
-		return
+// DecodeLog decodes a log of any event the Listener knows, e.g. to replay a
+// dead letter after the decoding problem was fixed.
+func (l *Listener) DecodeLog(log types.Log, blockTime time.Time) (Event, error) {
+	if len(log.Topics) == 0 {
+		return nil, errors.New("log has no topics")
 	}
-
-	parsedABI, err := IDepositMetaData.GetAbi()
+	names := make([]string, len(eventSpecs))
+	for i, spec := range eventSpecs {
+		names[i] = spec.name
+	}
+	decoders, err := l.eventDecoders(names)
 	if err != nil {
-		return
+		return nil, err
 	}
-	topic := parsedABI.Events["Deposited"].ID
+	decode, ok := decoders[log.Topics[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown event topic %s", log.Topics[0].Hex())
+	}
+	return decode(l, log, blockTime)
+}
 
-	listenEvents(ctx, l.client, "deposited", l.contractAddr, l.confirmationBlocks, l.pollInterval, fromBlock, fromLogIndex,
-		[][]common.Hash{{topic}},
-		func(log types.Log) {
-			ev, err := l.depositFilterer.ParseDeposited(log)
-			if err != nil {
-				return
-			}
-			sink <- &DepositedEvent{
-				User:        ev.User,
-				Token:       ev.Token,
-				Amount:      ev.Amount,
-				BlockNumber: ev.Raw.BlockNumber,
-				TxHash:      ev.Raw.TxHash,
-				LogIndex:    ev.Raw.Index,
-			}
-		},
-	)
+// deadLetter records a log the stream cannot deliver. A nil return lets the
+// stream move past the log.
+func (l *Listener) deadLetter(subID string, log types.Log, reason string) error {
+	deadLettersCounter.WithLabelValues(subID).Inc()
+	listenerLogger.Errorw("undecodable log", "subID", subID, "blockNumber", log.BlockNumber,
+		"txHash", log.TxHash, "logIndex", log.Index, "reason", reason, "deadLetterStore", l.deadLetters != nil)
+	if l.deadLetters == nil {
+		return nil
+	}
+	if err := l.deadLetters.SaveDeadLetter(subID, log, reason); err != nil {
+		return fmt.Errorf("failed to save dead letter: %w", err)
+	}
+	return nil
 }
 
-// listenEvents polls for new confirmed blocks at pollInterval and only
-// processes events from blocks that have at least confirmationBlocks on top.
-type logHandler func(log types.Log)
+func (l *Listener) newAckHandle() *ackHandle {
+	if !l.acknowledgements {
+		return nil
+	}
+	return newAckHandle()
+}
+
+func closeReorgs(reorgs chan<- *ReorgEvent) {
+	if reorgs != nil {
+		close(reorgs)
+	}
+}
 
-func listenEvents(
+// listenEvents waits for new blocks (pushed by a head subscription or polled
+// at pollInterval) and only processes events from confirmed blocks: those
+// with at least confirmationBlocks on top, or at or below the node's safe or
+// finalized block depending on the confirmation mode.
+// Before each cycle it checks that the last delivered block is still
+// canonical; if a reorg orphaned it, the orphaned range is reported on reorgs
+// and the cursor is rewound to the common ancestor so that events of the new
+// canonical chain are delivered.
+// The handler returns the event it delivered, or nil if the log was skipped.
+// An error means the log could not be delivered yet and has to be retried.
+type logHandler func(log types.Log) (Event, error)
+
+func (l *Listener) listenEvents(
 	ctx context.Context,
-	client bind.ContractBackend,
 	subID string,
-	contractAddress common.Address,
-	confirmationBlocks uint64,
-	pollInterval time.Duration,
-	lastBlock uint64,
-	lastIndex uint32,
+	from Cursor,
+	reorgs chan<- *ReorgEvent,
 	topics [][]common.Hash,
 	handler logHandler,
-) {
-	var backOffCount atomic.Uint64
+) error {
+	client, addresses := l.client, l.contracts
 
-	listenerLogger.Debugw("starting confirmed-block polling", "subID", subID, "confirmationBlocks", confirmationBlocks, "pollInterval", pollInterval)
+	var (
+		backOffCount atomic.Uint64
+		lastErr      error
+	)
+	// fail records a transient error; the stream gives up once too many
+	// happen in a row.
+	fail := func(err error) {
+		lastErr = err
+		backOffCount.Add(1)
+	}
+	lastBlock, lastIndex := from.BlockNumber, from.LogIndex
+	// Contracts that are ahead of the cursor; see Cursor.
+	delivered := maps.Clone(from.Contracts)
+	journal := newBlockJournal(from)
+
+	var subscriber headSubscriber
+	if l.subscribeHeads {
+		if s, ok := client.(headSubscriber); ok {
+			subscriber = s
+		} else {
+			listenerLogger.Warnw("client does not support head subscriptions, polling only", "subID", subID)
+		}
+	}
+	heads := newHeadWatcher(subID, subscriber, l.pollInterval)
+	defer heads.stop()
+	confirmations := newConfirmer(subID, l.confirmationMode, l.confirmationBlocks)
 
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
+	listenerLogger.Infow("starting confirmed-block listener", "subID", subID, "confirmationMode", confirmations.mode,
+		"confirmationBlocks", l.confirmationBlocks, "pollInterval", l.pollInterval, "subscribeHeads", subscriber != nil)
 
 	for {
-		select {
-		case <-ctx.Done():
+		header, ok := heads.next(ctx)
+		if !ok {
 			listenerLogger.Infow("context cancelled, stopping listener", "subID", subID)
-			return
-		case <-ticker.C:
+			return nil
 		}
 
 		if !waitForBackOffTimeout(ctx, int(backOffCount.Load()), "confirmed-block poll") {
-			return
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("%s: %w: %w", subID, ErrBackOffExceeded, lastErr)
 		}
 
-		headerCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
-		var header *types.Header
-		err := debounce.Debounce(headerCtx, listenerLogger, func(ctx context.Context) error {
+		if header == nil {
 			var err error
-			header, err = client.HeaderByNumber(ctx, nil)
-			return err
-		})
+			header, err = headerByNumber(ctx, client, nil)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				listenerLogger.Errorw("failed to get latest block", "error", err, "subID", subID)
+				fail(fmt.Errorf("failed to get latest block: %w", err))
+				continue
+			}
+		}
+
+		reorgCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+		reorg, err := journal.checkReorg(reorgCtx, client)
 		cancel()
 		if err != nil {
 			if ctx.Err() != nil {
-				return
+				return nil
 			}
-			listenerLogger.Errorw("failed to get latest block", "error", err, "subID", subID)
-			backOffCount.Add(1)
+			listenerLogger.Errorw("failed to verify delivered blocks are canonical", "error", err, "subID", subID)
+			fail(fmt.Errorf("failed to verify delivered blocks are canonical: %w", err))
 			continue
 		}
+		if reorg != nil {
+			listenerLogger.Warnw("chain reorganization detected", "subID", subID,
+				"fromBlock", reorg.FromBlock, "toBlock", reorg.ToBlock,
+				"ancestorBlock", reorg.Ancestor.Number, "orphanedEvents", len(reorg.Events))
+			if reorgs != nil {
+				select {
+				case reorgs <- reorg:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			// The ancestor block itself was fully delivered and is still canonical.
+			lastBlock, lastIndex = reorg.Ancestor.Number, math.MaxUint32
+			maps.DeleteFunc(delivered, func(_ common.Address, pos Position) bool {
+				return pos.BlockNumber > reorg.Ancestor.Number
+			})
+		}
 
-		latestBlock := header.Number.Uint64()
-		if latestBlock < confirmationBlocks {
+		safeBlock, ok, err := confirmations.confirmedBlock(ctx, client, header)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			listenerLogger.Errorw("failed to determine confirmed block", "error", err, "subID", subID, "confirmationMode", confirmations.mode)
+			fail(fmt.Errorf("failed to determine confirmed block: %w", err))
 			continue
 		}
-		safeBlock := latestBlock - confirmationBlocks
+		if !ok {
+			continue
+		}
+		confirmedBlockGauge.WithLabelValues(subID).Set(float64(safeBlock))
 
 		if safeBlock <= lastBlock && lastBlock != 0 {
 			continue
 		}
 
+		// Fetch the confirmed block's hash before its logs so that a reorg
+		// racing with this cycle is caught by the next canonical check.
+		safeHeader, err := headerByNumber(ctx, client, new(big.Int).SetUint64(safeBlock))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			listenerLogger.Errorw("failed to get confirmed block", "error", err, "subID", subID, "block", safeBlock)
+			fail(fmt.Errorf("failed to get confirmed block %d: %w", safeBlock, err))
+			continue
+		}
+
+		rangeCtx, cancelRange := context.WithCancel(ctx)
 		logsCh := make(chan types.Log, 1)
-		go reconcileBlockRange(ctx, client, subID, contractAddress, safeBlock, lastBlock, lastIndex, topics, logsCh)
+		var rangeErr error
+		go func() {
+			if l.backfillWorkers > 1 && safeBlock-lastBlock > blockStep {
+				rangeErr = backfillRange(rangeCtx, client, subID, addresses, safeBlock, lastBlock, lastIndex, topics, l.backfillWorkers, logsCh)
+			} else {
+				rangeErr = reconcileBlockRange(rangeCtx, client, subID, addresses, safeBlock, lastBlock, lastIndex, topics, logsCh)
+			}
+			close(logsCh)
+		}()
 
+		var deliverErr error
 		for ethLog := range logsCh {
-			handler(ethLog)
+			if pos, ok := delivered[ethLog.Address]; ok && pos.covers(ethLog.BlockNumber, ethLog.Index) {
+				lastBlock = ethLog.BlockNumber
+				lastIndex = uint32(ethLog.Index)
+				continue
+			}
+			if deliverErr = deliver(ctx, subID, ethLog, handler, journal); deliverErr != nil {
+				// Stop the range reader and wait for it to finish.
+				cancelRange()
+				for range logsCh {
+				}
+				break
+			}
 			lastBlock = ethLog.BlockNumber
 			lastIndex = uint32(ethLog.Index)
 		}
+		cancelRange()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(deliverErr, ErrRedeliveryExceeded) {
+			listenerLogger.Errorw("consumer keeps failing to process event, stopping", "error", deliverErr, "subID", subID)
+			return fmt.Errorf("%s: %w", subID, deliverErr)
+		}
+		if deliverErr != nil {
+			listenerLogger.Errorw("failed to deliver event", "error", deliverErr, "subID", subID)
+			fail(deliverErr)
+			continue
+		}
+		if rangeErr != nil {
+			// Logs up to lastBlock/lastIndex were delivered; the rest of the
+			// range is fetched again by the next cycle.
+			fail(rangeErr)
+			continue
+		}
 
 		// Advance the cursor to safeBlock only if no logs were emitted
 		// in that block (otherwise lastBlock/lastIndex already point at
@@ -240,34 +532,101 @@ func listenEvents(
 			lastBlock = safeBlock
 			lastIndex = 0
 		}
+		journal.record(BlockRef{Number: safeBlock, Hash: safeHeader.Hash()}, nil)
 		backOffCount.Store(0)
 	}
 }
 
+// maxRedeliveries is how many times an event whose consumer reported a
+// processing failure is redelivered before the stream fails. It keeps a
+// consumer that cannot make progress from stalling the stream unnoticed.
+const maxRedeliveries = 5
+
+// redeliveryBackOff is the delay before the first redelivery of an event; it
+// doubles with every further one.
+var redeliveryBackOff = 1 * time.Second
+
+// deliver hands a log to the handler and, if it produced an event, waits for
+// the event to be acknowledged, redelivering it until the consumer succeeds.
+// It returns nil once the log is committed, the handler's error if the log
+// could not be delivered, an error wrapping ErrRedeliveryExceeded if the
+// consumer kept failing, or ctx's error if ctx was cancelled.
+func deliver(ctx context.Context, subID string, ethLog types.Log, handler logHandler, journal *blockJournal) error {
+	for attempt := 0; ; attempt++ {
+		ev, err := handler(ethLog)
+		if err != nil {
+			return err
+		}
+		if ev == nil {
+			return ctx.Err()
+		}
+
+		err = ev.awaitAck(ctx)
+		if err == nil {
+			journal.record(ev.Block(), ev)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= maxRedeliveries {
+			return fmt.Errorf("%w: event at block %d log %d: %w", ErrRedeliveryExceeded, ethLog.BlockNumber, ethLog.Index, err)
+		}
+
+		redeliveriesCounter.WithLabelValues(subID).Inc()
+		delay := redeliveryBackOff << attempt
+		listenerLogger.Warnw("consumer failed to process event, redelivering", "subID", subID,
+			"blockNumber", ethLog.BlockNumber, "logIndex", ethLog.Index, "attempt", attempt+1, "retryIn", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// headerByNumber fetches a block header (the latest one if number is nil),
+// retrying transient failures for up to a minute.
+func headerByNumber(ctx context.Context, client bind.ContractBackend, number *big.Int) (*types.Header, error) {
+	headerCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	var header *types.Header
+	err := debounce.Debounce(headerCtx, listenerLogger, func(ctx context.Context) error {
+		var err error
+		header, err = client.HeaderByNumber(ctx, number)
+		return err
+	})
+	return header, err
+}
+
 func reconcileBlockRange(
 	ctx context.Context,
 	client bind.ContractBackend,
 	subID string,
-	contractAddress common.Address,
+	addresses []common.Address,
 	currentBlock uint64,
 	lastBlock uint64,
 	lastIndex uint32,
 	topics [][]common.Hash,
 	historicalCh chan types.Log,
-) {
-	defer close(historicalCh)
-
-	var backOffCount atomic.Uint64
-	const blockStep = 10000
+) error {
+	var (
+		backOffCount atomic.Uint64
+		lastErr      error
+	)
 	startBlock := lastBlock
 	endBlock := startBlock + blockStep
 
 	for currentBlock > startBlock {
 		if ctx.Err() != nil {
-			return
+			return nil
 		}
 		if !waitForBackOffTimeout(ctx, int(backOffCount.Load()), "reconcile block range") {
-			return
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to fetch logs from block %d: %w: %w", startBlock, ErrBackOffExceeded, lastErr)
 		}
 
 		if endBlock > currentBlock {
@@ -275,7 +634,7 @@ func reconcileBlockRange(
 		}
 
 		fetchFQ := ethereum.FilterQuery{
-			Addresses: []common.Address{contractAddress},
+			Addresses: addresses,
 			FromBlock: new(big.Int).SetUint64(startBlock),
 			ToBlock:   new(big.Int).SetUint64(endBlock),
 			Topics:    topics,
@@ -300,6 +659,7 @@ func reconcileBlockRange(
 			newStartBlock, newEndBlock, extractErr := extractAdvisedBlockRange(err.Error())
 			if extractErr != nil {
 				listenerLogger.Errorw("failed to filter logs", "error", err, "extractErr", extractErr, "subID", subID, "startBlock", startBlock, "endBlock", endBlock)
+				lastErr = err
 				backOffCount.Add(1)
 				continue
 			}
@@ -321,6 +681,7 @@ func reconcileBlockRange(
 		startBlock = endBlock + 1
 		endBlock += blockStep
 	}
+	return nil
 }
 
 func extractAdvisedBlockRange(msg string) (startBlock, endBlock uint64, err error) {
@@ -0,0 +1,386 @@
+package custody
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testContract = common.HexToAddress("0xc0")
+	testUser     = common.HexToAddress("0x01")
+	testToken    = common.HexToAddress("0x02")
+)
+
+// fakeChain serves a chain of blocks and their logs. Headers link to their
+// parent, so rewriting a block changes the hashes of all blocks after it, and
+// FilterLogs honours the block range, addresses and topics of the query.
+// Methods it does not override panic on the nil embedded EthBackend.
+type fakeChain struct {
+	EthBackend
+
+	mu      sync.Mutex
+	headers []*types.Header
+	logs    [][]types.Log
+	queries []ethereum.FilterQuery
+	// filterErr, if set, may fail or delay a FilterLogs query before it is
+	// served. It is called without holding mu.
+	filterErr func(q ethereum.FilterQuery) error
+}
+
+// newFakeChain creates a chain of n empty blocks on top of the genesis block.
+func newFakeChain(n int) *fakeChain {
+	c := &fakeChain{}
+	for range n + 1 {
+		c.appendBlock(0, nil)
+	}
+	return c
+}
+
+// mine appends a block holding logs and returns its number.
+func (c *fakeChain) mine(logs ...types.Log) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.appendBlock(0, logs)
+}
+
+// reorg replaces the blocks from number from on with blocks of another fork,
+// one per entry of blocks.
+func (c *fakeChain) reorg(from uint64, fork byte, blocks ...[]types.Log) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers, c.logs = c.headers[:from], c.logs[:from]
+	for _, logs := range blocks {
+		c.appendBlock(fork, logs)
+	}
+}
+
+func (c *fakeChain) appendBlock(fork byte, logs []types.Log) uint64 {
+	n := uint64(len(c.headers))
+	header := &types.Header{Number: new(big.Int).SetUint64(n), Time: 1_700_000_000 + 12*n, Extra: []byte{fork}}
+	if n > 0 {
+		header.ParentHash = c.headers[n-1].Hash()
+	}
+	logs = slices.Clone(logs)
+	for i := range logs {
+		logs[i].BlockNumber = n
+		logs[i].BlockHash = header.Hash()
+		logs[i].BlockTimestamp = header.Time
+		logs[i].TxHash = common.BigToHash(new(big.Int).SetUint64(n<<16 | uint64(i)))
+		logs[i].Index = uint(i)
+	}
+	c.headers = append(c.headers, header)
+	c.logs = append(c.logs, logs)
+	return n
+}
+
+func (c *fakeChain) hash(n uint64) common.Hash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.headers[n].Hash()
+}
+
+func (c *fakeChain) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.headers) - 1
+	if number != nil {
+		if number.Sign() < 0 {
+			return nil, errors.New("invalid block tag")
+		}
+		if !number.IsInt64() || number.Int64() > int64(n) {
+			return nil, ethereum.NotFound
+		}
+		n = int(number.Int64())
+	}
+	return types.CopyHeader(c.headers[n]), nil
+}
+
+func (c *fakeChain) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.mu.Lock()
+	c.queries = append(c.queries, q)
+	filterErr := c.filterErr
+	c.mu.Unlock()
+	if filterErr != nil {
+		if err := filterErr(q); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var logs []types.Log
+	to := min(q.ToBlock.Uint64(), uint64(len(c.logs)-1))
+	for n := q.FromBlock.Uint64(); n <= to; n++ {
+		for _, log := range c.logs[n] {
+			if len(q.Addresses) > 0 && !slices.Contains(q.Addresses, log.Address) {
+				continue
+			}
+			if len(q.Topics) > 0 && len(q.Topics[0]) > 0 && !slices.Contains(q.Topics[0], log.Topics[0]) {
+				continue
+			}
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// eventLog ABI-encodes a log of the named event emitted by testContract, with
+// args in the order of the event's inputs.
+func eventLog(t *testing.T, meta *bind.MetaData, name string, args ...any) types.Log {
+	t.Helper()
+	parsed, err := meta.GetAbi()
+	require.NoError(t, err)
+	event, ok := parsed.Events[name]
+	require.True(t, ok, "event %s not in ABI", name)
+	require.Len(t, args, len(event.Inputs))
+
+	log := types.Log{Address: testContract, Topics: []common.Hash{event.ID}}
+	var data []any
+	for i, input := range event.Inputs {
+		if !input.Indexed {
+			data = append(data, args[i])
+			continue
+		}
+		topics, err := abi.MakeTopics([]any{args[i]})
+		require.NoError(t, err)
+		log.Topics = append(log.Topics, topics[0][0])
+	}
+	log.Data, err = event.Inputs.NonIndexed().Pack(data...)
+	require.NoError(t, err)
+	return log
+}
+
+func withdrawStartedLog(t *testing.T, id byte) types.Log {
+	return eventLog(t, IWithdrawMetaData, "WithdrawStarted",
+		[32]byte{id}, testUser, testToken, big.NewInt(int64(id)*100), big.NewInt(int64(id)))
+}
+
+// newTestListener creates a Listener of testContract on chain that treats
+// blocks with one block on top as confirmed and polls every 20ms.
+func newTestListener(t *testing.T, chain *fakeChain, opts ...ListenerOption) *Listener {
+	t.Helper()
+	withdraw, err := NewIWithdraw(testContract, chain)
+	require.NoError(t, err)
+	deposit, err := NewIDeposit(testContract, chain)
+	require.NoError(t, err)
+	return NewListener(chain, testContract, 1, 20*time.Millisecond, withdraw, deposit, opts...)
+}
+
+// shortenRedeliveryBackOff makes rejected events redeliver within
+// milliseconds for the duration of the test.
+func shortenRedeliveryBackOff(t *testing.T) {
+	backOff := redeliveryBackOff
+	redeliveryBackOff = time.Millisecond
+	t.Cleanup(func() { redeliveryBackOff = backOff })
+}
+
+func TestListener_FailsAfterRedeliveryLimit(t *testing.T) {
+	shortenRedeliveryBackOff(t)
+
+	chain := newFakeChain(2)
+	chain.mine(withdrawStartedLog(t, 1))
+	chain.mine()
+	l := newTestListener(t, chain, WithAcknowledgements())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	sink := make(chan *WithdrawStartedEvent)
+	done := make(chan error, 1)
+	go func() { done <- l.WatchWithdrawStarted(ctx, sink, nil, Cursor{}) }()
+
+	deliveries := 0
+	for ev := range sink {
+		require.Equal(t, [32]byte{1}, ev.WithdrawalID)
+		deliveries++
+		ev.Ack(errors.New("database unavailable"))
+	}
+	err := <-done
+	require.ErrorIs(t, err, ErrRedeliveryExceeded)
+	require.ErrorContains(t, err, "database unavailable")
+	require.Equal(t, maxRedeliveries+1, deliveries)
+}
+
+// watchWithdrawStarted runs a WithdrawStarted stream from cursor until the
+// test ends and returns its sink, its reorg channel and its result.
+func watchWithdrawStarted(t *testing.T, l *Listener, from Cursor) (<-chan *WithdrawStartedEvent, <-chan *ReorgEvent, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	sink := make(chan *WithdrawStartedEvent)
+	reorgs := make(chan *ReorgEvent, 1)
+	done := make(chan error, 1)
+	go func() { done <- l.WatchWithdrawStarted(ctx, sink, reorgs, from) }()
+	t.Cleanup(func() {
+		cancel()
+		for range sink {
+		}
+		<-done
+	})
+	return sink, reorgs, done
+}
+
+// receive returns the next event from sink, failing the test if none arrives
+// in time.
+func receive[E any](t *testing.T, sink <-chan E) E {
+	t.Helper()
+	select {
+	case ev, ok := <-sink:
+		require.True(t, ok, "stream ended")
+		return ev
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "no event received")
+	}
+	var zero E
+	return zero
+}
+
+func TestListener_RedeliversAfterReorg(t *testing.T) {
+	chain := newFakeChain(2)
+	chain.mine(withdrawStartedLog(t, 1))
+	chain.mine(withdrawStartedLog(t, 2))
+	chain.mine()
+	l := newTestListener(t, chain)
+	sink, reorgs, _ := watchWithdrawStarted(t, l, Cursor{})
+
+	require.Equal(t, [32]byte{1}, receive(t, sink).WithdrawalID)
+	orphaned := receive(t, sink)
+	require.Equal(t, [32]byte{2}, orphaned.WithdrawalID)
+
+	// Block 4 is replaced by one carrying another withdrawal.
+	chain.reorg(4, 1, []types.Log{withdrawStartedLog(t, 3)}, nil, nil)
+
+	reorg := receive(t, reorgs)
+	require.Equal(t, BlockRef{Number: 3, Hash: chain.hash(3)}, reorg.Ancestor)
+	require.Equal(t, uint64(4), reorg.FromBlock)
+	require.Equal(t, uint64(4), reorg.ToBlock)
+	require.Equal(t, []Event{orphaned}, reorg.Events)
+
+	// Delivery resumes after the ancestor, which is not delivered again.
+	ev := receive(t, sink)
+	require.Equal(t, [32]byte{3}, ev.WithdrawalID)
+	require.Equal(t, BlockRef{Number: 4, Hash: chain.hash(4)}, ev.Block())
+}
+
+func TestListener_AtLeastOnceDelivery(t *testing.T) {
+	shortenRedeliveryBackOff(t)
+
+	chain := newFakeChain(2)
+	chain.mine(withdrawStartedLog(t, 1))
+	chain.mine(withdrawStartedLog(t, 2))
+	chain.mine()
+	l := newTestListener(t, chain, WithAcknowledgements())
+	sink, _, _ := watchWithdrawStarted(t, l, Cursor{})
+
+	first := receive(t, sink)
+	require.Equal(t, [32]byte{1}, first.WithdrawalID)
+	select {
+	case ev := <-sink:
+		require.FailNow(t, "event delivered before the previous one was acknowledged", "withdrawal %x", ev.WithdrawalID)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	first.Ack(errors.New("database unavailable"))
+	again := receive(t, sink)
+	require.Equal(t, first.WithdrawalID, again.WithdrawalID)
+	require.Equal(t, first.Block(), again.Block())
+	require.Equal(t, first.LogIndex, again.LogIndex)
+
+	again.Ack(nil)
+	second := receive(t, sink)
+	require.Equal(t, [32]byte{2}, second.WithdrawalID)
+	second.Ack(nil)
+}
+
+func TestDeliver_CommitsOnlyAfterAck(t *testing.T) {
+	shortenRedeliveryBackOff(t)
+
+	chain := newFakeChain(2)
+	chain.mine(withdrawStartedLog(t, 1))
+	log := chain.logs[3][0]
+	l := &Listener{acknowledgements: true}
+	journal := newBlockJournal(Cursor{})
+
+	events := make(chan Event, 1)
+	handler := func(log types.Log) (Event, error) {
+		ev := &WithdrawStartedEvent{BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, LogIndex: log.Index, ackHandle: l.newAckHandle()}
+		events <- ev
+		return ev, nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- deliver(context.Background(), "test", log, handler, journal) }()
+
+	failed := <-events
+	failed.Ack(errors.New("database unavailable"))
+	committed := <-events
+	require.NotSame(t, failed, committed)
+	require.Empty(t, journal.entries, "failed event was committed")
+
+	committed.Ack(nil)
+	require.NoError(t, <-done)
+	require.Equal(t, []journalEntry{{ref: committed.Block(), events: []Event{committed}}}, journal.entries)
+}
+
+func TestListener_WatchAllInChainOrder(t *testing.T) {
+	chain := newFakeChain(2)
+	chain.mine(
+		withdrawStartedLog(t, 1),
+		eventLog(t, IDepositMetaData, "Deposited", testUser, testToken, big.NewInt(500)),
+		eventLog(t, ThresholdCustodyMetaData, "WithdrawalApproved", [32]byte{1}, testUser, big.NewInt(1)),
+	)
+	other := withdrawStartedLog(t, 2)
+	other.Address = common.HexToAddress("0xc1")
+	chain.mine(
+		eventLog(t, ThresholdCustodyMetaData, "RateLimitUpdated", big.NewInt(1000), big.NewInt(3600)),
+		other,
+		eventLog(t, IWithdrawMetaData, "WithdrawFinalized", [32]byte{1}, true),
+	)
+	chain.mine(eventLog(t, QuorumCustodyMetaData, "QuorumChanged", uint64(1), uint64(2)))
+	chain.mine()
+	l := newTestListener(t, chain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	sink := make(chan Event)
+	done := make(chan error, 1)
+	go func() { done <- l.WatchAll(ctx, sink, nil, Cursor{}) }()
+	t.Cleanup(func() {
+		cancel()
+		for range sink {
+		}
+		<-done
+	})
+
+	var got []Event
+	for range 6 {
+		got = append(got, receive(t, sink))
+	}
+	require.IsType(t, &WithdrawStartedEvent{}, got[0])
+	require.IsType(t, &DepositedEvent{}, got[1])
+	require.IsType(t, &WithdrawalApprovedEvent{}, got[2])
+	require.IsType(t, &RateLimitUpdatedEvent{}, got[3])
+	require.IsType(t, &WithdrawFinalizedEvent{}, got[4])
+	require.IsType(t, &QuorumChangedEvent{}, got[5])
+	for i, n := range []uint64{3, 3, 3, 4, 4, 5} {
+		require.Equal(t, BlockRef{Number: n, Hash: chain.hash(n)}, got[i].Block(), "event %d", i)
+	}
+	require.Equal(t, uint(2), got[4].(*WithdrawFinalizedEvent).LogIndex, "log of another contract delivered")
+
+	// Every range is fetched with one query for all events.
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	for _, q := range chain.queries {
+		require.Equal(t, []common.Address{testContract}, q.Addresses)
+		require.Len(t, q.Topics, 1)
+		require.Len(t, q.Topics[0], len(eventSpecs))
+	}
+}
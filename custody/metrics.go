@@ -0,0 +1,36 @@
+package custody
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	confirmationModeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "nitewatch",
+		Subsystem: "listener",
+		Name:      "confirmation_mode",
+		Help:      "Confirmation mode in effect per stream (1 for the active mode).",
+	}, []string{"stream", "mode"})
+
+	confirmedBlockGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "nitewatch",
+		Subsystem: "listener",
+		Name:      "confirmed_block",
+		Help:      "Newest confirmed block seen per stream.",
+	}, []string{"stream"})
+
+	deadLettersCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nitewatch",
+		Subsystem: "listener",
+		Name:      "dead_letters_total",
+		Help:      "Number of logs per stream that could not be decoded.",
+	}, []string{"stream"})
+
+	redeliveriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nitewatch",
+		Subsystem: "listener",
+		Name:      "redeliveries_total",
+		Help:      "Number of events per stream redelivered after the consumer failed to process them.",
+	}, []string{"stream"})
+)
@@ -0,0 +1,488 @@
+package custody
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var backendLogger = logging.Logger("custody-backend")
+
+// ErrNoQuorum is returned when fewer RPC endpoints than the configured quorum
+// returned the same chain data.
+var ErrNoQuorum = errors.New("rpc endpoints did not reach quorum")
+
+// errNotConnected is returned for calls to an endpoint that could not be
+// dialed and is not due to be dialed again yet.
+var errNotConnected = errors.New("rpc endpoint not connected")
+
+// redialInterval is how long an endpoint that could not be dialed is skipped
+// before it is dialed again.
+const redialInterval = 10 * time.Second
+
+// Dialer connects to an RPC endpoint.
+type Dialer func(ctx context.Context) (EthBackend, error)
+
+// MultiBackend is an EthBackend that spreads calls over several RPC endpoints.
+//
+// Every call goes to the current primary endpoint first and fails over to the
+// others in order if it errors; an endpoint that answers in place of a failing
+// primary becomes the new primary.
+//
+// With a quorum greater than one, the chain data the Listener acts upon is
+// cross-checked instead: HeaderByNumber for a specific block and FilterLogs
+// only succeed if at least quorum endpoints return identical results, and the
+// latest header is the highest block that at least quorum endpoints reached.
+// A single lagging or compromised endpoint can then neither inject nor hide
+// logs, nor push the confirmed head past what the others have seen. Headers
+// for a block tag (safe, finalized) are resolved to the highest block number
+// at least quorum endpoints tag, and then cross-checked by that number, so
+// endpoints a block apart still agree.
+type MultiBackend struct {
+	endpoints []*endpoint
+	names     []string
+	quorum    int
+	primary   atomic.Int32
+}
+
+// endpoint is one RPC endpoint of a MultiBackend. An endpoint without a
+// backend could not be dialed yet and is dialed again when a call needs it.
+type endpoint struct {
+	mu       sync.Mutex
+	backend  EthBackend
+	dial     Dialer
+	dialedAt time.Time
+}
+
+// get returns the backend of the endpoint, dialing it if it is not connected
+// and was not dialed within redialInterval.
+func (e *endpoint) get(ctx context.Context) (EthBackend, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.backend != nil {
+		return e.backend, nil
+	}
+	if e.dial == nil || time.Since(e.dialedAt) < redialInterval {
+		return nil, errNotConnected
+	}
+	e.dialedAt = time.Now()
+	b, err := e.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errNotConnected, err)
+	}
+	e.backend = b
+	return b, nil
+}
+
+// Compile-time check that MultiBackend implements EthBackend.
+var _ EthBackend = (*MultiBackend)(nil)
+
+// NewMultiBackend creates a MultiBackend over backends, which are named in logs
+// by the matching entry of names. A quorum <= 1 disables cross-checking.
+func NewMultiBackend(backends []EthBackend, names []string, quorum int) (*MultiBackend, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("at least one backend is required")
+	}
+	if len(names) != len(backends) {
+		return nil, fmt.Errorf("got %d names for %d backends", len(names), len(backends))
+	}
+	if quorum > len(backends) {
+		return nil, fmt.Errorf("quorum %d exceeds the number of backends (%d)", quorum, len(backends))
+	}
+	if quorum < 1 {
+		quorum = 1
+	}
+	endpoints := make([]*endpoint, len(backends))
+	for i, b := range backends {
+		endpoints[i] = &endpoint{backend: b}
+	}
+	return &MultiBackend{endpoints: endpoints, names: names, quorum: quorum}, nil
+}
+
+// DialMultiBackend dials every endpoint and creates a MultiBackend over them,
+// as NewMultiBackend does. Endpoints that cannot be dialed are dialed again
+// when a call needs them, but at least quorum endpoints (one without a
+// quorum) must be reachable up front.
+func DialMultiBackend(ctx context.Context, dialers []Dialer, names []string, quorum int) (*MultiBackend, error) {
+	backends := make([]EthBackend, len(dialers))
+	m, err := NewMultiBackend(backends, names, quorum)
+	if err != nil {
+		return nil, err
+	}
+
+	connected := 0
+	for i, dial := range dialers {
+		m.endpoints[i].dial = dial
+		if _, err := m.endpoints[i].get(ctx); err != nil {
+			backendLogger.Warnw("failed to dial rpc endpoint, will retry", "endpoint", names[i], "error", err)
+			continue
+		}
+		connected++
+	}
+	if connected < m.quorum {
+		m.Close()
+		return nil, fmt.Errorf("%d of %d rpc endpoints reachable, quorum %d", connected, len(dialers), m.quorum)
+	}
+	return m, nil
+}
+
+// failover runs call against the primary backend and then against every other
+// backend in turn until one succeeds.
+func failover[T any](ctx context.Context, m *MultiBackend, method string, call func(EthBackend) (T, error)) (T, error) {
+	primary := int(m.primary.Load())
+
+	var (
+		zero    T
+		lastErr error
+	)
+	for i := range m.endpoints {
+		idx := (primary + i) % len(m.endpoints)
+		res, err := callEndpoint(ctx, m.endpoints[idx], call)
+		if err == nil {
+			if idx != primary && m.primary.CompareAndSwap(int32(primary), int32(idx)) {
+				backendLogger.Warnw("rpc endpoint failed, switched primary", "method", method,
+					"from", m.names[primary], "to", m.names[idx], "error", lastErr)
+			}
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			return zero, err
+		}
+		backendLogger.Debugw("rpc call failed", "method", method, "endpoint", m.names[idx], "error", err)
+		lastErr = err
+	}
+	return zero, lastErr
+}
+
+type backendResult[T any] struct {
+	value T
+	err   error
+}
+
+// callEndpoint runs call against the backend of e.
+func callEndpoint[T any](ctx context.Context, e *endpoint, call func(EthBackend) (T, error)) (T, error) {
+	b, err := e.get(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return call(b)
+}
+
+// queryAll runs call against every backend concurrently.
+func queryAll[T any](ctx context.Context, m *MultiBackend, call func(EthBackend) (T, error)) []backendResult[T] {
+	results := make([]backendResult[T], len(m.endpoints))
+	var wg sync.WaitGroup
+	for i, e := range m.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].value, results[i].err = callEndpoint(ctx, e, call)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// agree runs call against every backend and returns the result that at least
+// quorum of them returned, comparing results by key.
+func agree[T any](ctx context.Context, m *MultiBackend, method string, call func(EthBackend) (T, error), key func(T) common.Hash) (T, error) {
+	if m.quorum <= 1 {
+		return failover(ctx, m, method, call)
+	}
+
+	var zero T
+	results := queryAll(ctx, m, call)
+
+	keys := make([]common.Hash, len(results))
+	votes := make(map[common.Hash]int)
+	var errs []error
+	for i, r := range results {
+		if r.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.names[i], r.err))
+			continue
+		}
+		keys[i] = key(r.value)
+		votes[keys[i]]++
+	}
+
+	for i, r := range results {
+		if r.err != nil || votes[keys[i]] < m.quorum {
+			continue
+		}
+		for j := range results {
+			if results[j].err == nil && keys[j] != keys[i] {
+				backendLogger.Warnw("rpc endpoint disagrees with quorum", "method", method, "endpoint", m.names[j])
+			}
+		}
+		return r.value, nil
+	}
+	if ctx.Err() != nil {
+		return zero, ctx.Err()
+	}
+
+	// Callers treat not-found as "not yet there"; keep that meaning if every
+	// endpoint agrees on it.
+	if allNotFound(results) {
+		return zero, ethereum.NotFound
+	}
+	err := fmt.Errorf("%w for %s: %d of %d required, distinct results: %d",
+		ErrNoQuorum, method, maxVotes(votes), m.quorum, len(votes))
+	if len(errs) > 0 {
+		err = fmt.Errorf("%w, errors: %w", err, errors.Join(errs...))
+	}
+	return zero, err
+}
+
+func allNotFound[T any](results []backendResult[T]) bool {
+	for _, r := range results {
+		if !errors.Is(r.err, ethereum.NotFound) {
+			return false
+		}
+	}
+	return true
+}
+
+func maxVotes(votes map[common.Hash]int) int {
+	best := 0
+	for _, v := range votes {
+		best = max(best, v)
+	}
+	return best
+}
+
+func headerKey(h *types.Header) common.Hash {
+	return h.Hash()
+}
+
+// logsKey digests everything that identifies a log and its contents.
+func logsKey(logs []types.Log) common.Hash {
+	var buf []byte
+	for _, l := range logs {
+		buf = append(buf, l.Address.Bytes()...)
+		for _, t := range l.Topics {
+			buf = append(buf, t.Bytes()...)
+		}
+		buf = binary.BigEndian.AppendUint64(buf, uint64(len(l.Data)))
+		buf = append(buf, l.Data...)
+		buf = binary.BigEndian.AppendUint64(buf, l.BlockNumber)
+		buf = append(buf, l.BlockHash.Bytes()...)
+		buf = append(buf, l.TxHash.Bytes()...)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(l.Index))
+	}
+	return crypto.Keccak256Hash(buf)
+}
+
+// HeaderByNumber returns the header of the given block. For the latest header
+// (nil number) with a quorum, it returns the highest block that at least
+// quorum endpoints have reached. For another block tag, it returns the header
+// of the highest block that at least quorum endpoints tag, provided quorum
+// endpoints agree on it.
+func (m *MultiBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	call := func(b EthBackend) (*types.Header, error) { return b.HeaderByNumber(ctx, number) }
+	if m.quorum <= 1 || (number != nil && number.Sign() >= 0) {
+		return agree(ctx, m, "HeaderByNumber", call, headerKey)
+	}
+
+	tag := "latest"
+	if number != nil {
+		tag = rpc.BlockNumber(number.Int64()).String()
+	}
+	var heads []*types.Header
+	var errs []error
+	for i, r := range queryAll(ctx, m, call) {
+		if r.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.names[i], r.err))
+			continue
+		}
+		heads = append(heads, r.value)
+	}
+	if len(heads) < m.quorum {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w for %s header: %d of %d endpoints answered: %w",
+			ErrNoQuorum, tag, len(heads), m.quorum, errors.Join(errs...))
+	}
+	slices.SortFunc(heads, func(a, b *types.Header) int { return b.Number.Cmp(a.Number) })
+	head := heads[m.quorum-1]
+	if number == nil || number.Int64() == int64(rpc.LatestBlockNumber) {
+		return head, nil
+	}
+	// Endpoints may tag different blocks; cross-check the one the quorum
+	// reached by its number.
+	return agree(ctx, m, "HeaderByNumber", func(b EthBackend) (*types.Header, error) {
+		return b.HeaderByNumber(ctx, head.Number)
+	}, headerKey)
+}
+
+// FilterLogs returns the logs matching q, cross-checked against the quorum.
+func (m *MultiBackend) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return agree(ctx, m, "FilterLogs", func(b EthBackend) ([]types.Log, error) {
+		return b.FilterLogs(ctx, q)
+	}, logsKey)
+}
+
+func (m *MultiBackend) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return failover(ctx, m, "SubscribeFilterLogs", func(b EthBackend) (ethereum.Subscription, error) {
+		return b.SubscribeFilterLogs(ctx, q, ch)
+	})
+}
+
+// SubscribeNewHead subscribes to new heads on the primary endpoint, failing
+// over to the others. With a quorum, every pushed head only serves as a
+// trigger: the header delivered is the latest one the quorum reached.
+func (m *MultiBackend) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	subscribe := func(b EthBackend, heads chan<- *types.Header) (ethereum.Subscription, error) {
+		s, ok := b.(headSubscriber)
+		if !ok {
+			return nil, rpc.ErrNotificationsUnsupported
+		}
+		return s.SubscribeNewHead(ctx, heads)
+	}
+	if m.quorum <= 1 {
+		return failover(ctx, m, "SubscribeNewHead", func(b EthBackend) (ethereum.Subscription, error) {
+			return subscribe(b, ch)
+		})
+	}
+
+	pushed := make(chan *types.Header)
+	inner, err := failover(ctx, m, "SubscribeNewHead", func(b EthBackend) (ethereum.Subscription, error) {
+		return subscribe(b, pushed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer inner.Unsubscribe()
+		for {
+			select {
+			case <-quit:
+				return nil
+			case err := <-inner.Err():
+				return err
+			case <-pushed:
+				head, err := m.HeaderByNumber(ctx, nil)
+				if err != nil {
+					backendLogger.Warnw("failed to confirm pushed head with quorum", "error", err)
+					continue
+				}
+				select {
+				case ch <- head:
+				case <-quit:
+					return nil
+				}
+			}
+		}
+	}), nil
+}
+
+func (m *MultiBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return failover(ctx, m, "CodeAt", func(b EthBackend) ([]byte, error) {
+		return b.CodeAt(ctx, contract, blockNumber)
+	})
+}
+
+func (m *MultiBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return failover(ctx, m, "CallContract", func(b EthBackend) ([]byte, error) {
+		return b.CallContract(ctx, call, blockNumber)
+	})
+}
+
+func (m *MultiBackend) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return failover(ctx, m, "BalanceAt", func(b EthBackend) (*big.Int, error) {
+		return b.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+func (m *MultiBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return failover(ctx, m, "PendingCodeAt", func(b EthBackend) ([]byte, error) {
+		return b.PendingCodeAt(ctx, account)
+	})
+}
+
+func (m *MultiBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return failover(ctx, m, "PendingNonceAt", func(b EthBackend) (uint64, error) {
+		return b.PendingNonceAt(ctx, account)
+	})
+}
+
+func (m *MultiBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return failover(ctx, m, "SuggestGasPrice", func(b EthBackend) (*big.Int, error) {
+		return b.SuggestGasPrice(ctx)
+	})
+}
+
+func (m *MultiBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return failover(ctx, m, "SuggestGasTipCap", func(b EthBackend) (*big.Int, error) {
+		return b.SuggestGasTipCap(ctx)
+	})
+}
+
+func (m *MultiBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return failover(ctx, m, "EstimateGas", func(b EthBackend) (uint64, error) {
+		return b.EstimateGas(ctx, call)
+	})
+}
+
+// SendTransaction submits tx through the primary endpoint, failing over to the
+// others. Re-sending the same signed transaction elsewhere is harmless.
+func (m *MultiBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	_, err := failover(ctx, m, "SendTransaction", func(b EthBackend) (struct{}, error) {
+		return struct{}{}, b.SendTransaction(ctx, tx)
+	})
+	return err
+}
+
+func (m *MultiBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return failover(ctx, m, "TransactionReceipt", func(b EthBackend) (*types.Receipt, error) {
+		return b.TransactionReceipt(ctx, txHash)
+	})
+}
+
+// ChainID returns the chain ID, which every endpoint must agree on.
+func (m *MultiBackend) ChainID(ctx context.Context) (*big.Int, error) {
+	var chainID *big.Int
+	for i, r := range queryAll(ctx, m, func(b EthBackend) (*big.Int, error) { return b.ChainID(ctx) }) {
+		if r.err != nil {
+			backendLogger.Warnw("failed to get chain ID", "endpoint", m.names[i], "error", r.err)
+			continue
+		}
+		if chainID == nil {
+			chainID = r.value
+		} else if chainID.Cmp(r.value) != 0 {
+			return nil, fmt.Errorf("rpc endpoints disagree on chain ID: %s vs %s (%s)", chainID, r.value, m.names[i])
+		}
+	}
+	if chainID == nil {
+		return nil, errors.New("no rpc endpoint returned a chain ID")
+	}
+	return chainID, nil
+}
+
+// Close closes every connected backend.
+func (m *MultiBackend) Close() {
+	for _, e := range m.endpoints {
+		e.mu.Lock()
+		if e.backend != nil {
+			e.backend.Close()
+			e.backend = nil
+		}
+		e.dial = nil
+		e.mu.Unlock()
+	}
+}
@@ -0,0 +1,251 @@
+package custody
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend serves a chain of headers up to latest. Headers of a fork differ
+// from those of other forks at every height. Methods it does not override
+// panic on the nil embedded EthBackend.
+type fakeBackend struct {
+	EthBackend
+
+	mu     sync.Mutex
+	latest int64
+	tags   map[rpc.BlockNumber]int64
+	fork   byte
+	logs   []types.Log
+	err    error
+	calls  int
+	heads  chan<- *types.Header
+	closed bool
+}
+
+func newFakeBackend(latest int64, fork byte) *fakeBackend {
+	return &fakeBackend{latest: latest, fork: fork, tags: make(map[rpc.BlockNumber]int64)}
+}
+
+func (f *fakeBackend) header(n int64) *types.Header {
+	return &types.Header{Number: big.NewInt(n), Extra: []byte{f.fork}}
+}
+
+func (f *fakeBackend) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	n := f.latest
+	if number != nil && number.Sign() >= 0 {
+		n = number.Int64()
+	} else if number != nil {
+		tagged, ok := f.tags[rpc.BlockNumber(number.Int64())]
+		if !ok {
+			return nil, errors.New("invalid block tag")
+		}
+		n = tagged
+	}
+	if n > f.latest {
+		return nil, ethereum.NotFound
+	}
+	return f.header(n), nil
+}
+
+func (f *fakeBackend) FilterLogs(context.Context, ethereum.FilterQuery) ([]types.Log, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.logs, f.err
+}
+
+func (f *fakeBackend) SubscribeNewHead(_ context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.heads = ch
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	}), nil
+}
+
+func (f *fakeBackend) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
+
+func (f *fakeBackend) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func newTestMultiBackend(t *testing.T, quorum int, backends ...*fakeBackend) *MultiBackend {
+	t.Helper()
+	ethBackends := make([]EthBackend, len(backends))
+	names := make([]string, len(backends))
+	for i, b := range backends {
+		ethBackends[i] = b
+		names[i] = string(rune('a' + i))
+	}
+	m, err := NewMultiBackend(ethBackends, names, quorum)
+	require.NoError(t, err)
+	return m
+}
+
+func TestMultiBackend_HeaderQuorum(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := newFakeBackend(10, 0), newFakeBackend(10, 0), newFakeBackend(10, 1)
+	m := newTestMultiBackend(t, 2, a, b, c)
+
+	h, err := m.HeaderByNumber(ctx, big.NewInt(5))
+	require.NoError(t, err)
+	require.Equal(t, a.header(5).Hash(), h.Hash(), "the forked endpoint is outvoted")
+
+	b.fork = 2
+	_, err = m.HeaderByNumber(ctx, big.NewInt(5))
+	require.ErrorIs(t, err, ErrNoQuorum)
+
+	_, err = m.HeaderByNumber(ctx, big.NewInt(11))
+	require.ErrorIs(t, err, ethereum.NotFound, "not-found on every endpoint stays not-found")
+}
+
+func TestMultiBackend_LatestHeader(t *testing.T) {
+	m := newTestMultiBackend(t, 2, newFakeBackend(10, 0), newFakeBackend(12, 0), newFakeBackend(11, 0))
+
+	h, err := m.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), h.Number.Int64(), "the highest block two endpoints reached")
+}
+
+func TestMultiBackend_TaggedHeader(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := newFakeBackend(12, 0), newFakeBackend(12, 0), newFakeBackend(12, 0)
+	a.tags[rpc.FinalizedBlockNumber] = 8
+	b.tags[rpc.FinalizedBlockNumber] = 9
+	c.tags[rpc.FinalizedBlockNumber] = 10
+	m := newTestMultiBackend(t, 2, a, b, c)
+
+	// The endpoints finalized different blocks; two of them finalized block 9.
+	h, err := m.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+	require.NoError(t, err)
+	require.Equal(t, a.header(9).Hash(), h.Hash())
+
+	// Block 9 itself is still cross-checked.
+	b.fork, c.fork = 1, 2
+	_, err = m.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+	require.ErrorIs(t, err, ErrNoQuorum)
+
+	_, err = m.HeaderByNumber(ctx, big.NewInt(int64(rpc.SafeBlockNumber)))
+	require.ErrorIs(t, err, ErrNoQuorum)
+	require.ErrorContains(t, err, "invalid block tag")
+}
+
+func TestMultiBackend_Failover(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := newFakeBackend(10, 0), newFakeBackend(10, 0), newFakeBackend(10, 0)
+	m := newTestMultiBackend(t, 1, a, b, c)
+
+	a.err = errors.New("down")
+	b.err = errors.New("down")
+	h, err := m.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), h.Number.Int64())
+	require.Equal(t, int32(2), m.primary.Load(), "the answering endpoint becomes the primary")
+
+	_, err = m.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, a.callCount(), "the new primary is tried first")
+	require.Equal(t, 2, c.callCount())
+
+	c.err = errors.New("down")
+	_, err = m.HeaderByNumber(ctx, nil)
+	require.ErrorContains(t, err, "down")
+}
+
+func TestMultiBackend_FilterLogs(t *testing.T) {
+	ctx := context.Background()
+	logs := []types.Log{{Address: common.Address{1}, BlockNumber: 5, Index: 0}, {Address: common.Address{1}, BlockNumber: 5, Index: 1}}
+	a, b, c := newFakeBackend(10, 0), newFakeBackend(10, 0), newFakeBackend(10, 0)
+	a.logs, b.logs, c.logs = logs, logs, logs[:1]
+	m := newTestMultiBackend(t, 2, a, b, c)
+
+	got, err := m.FilterLogs(ctx, ethereum.FilterQuery{})
+	require.NoError(t, err)
+	require.Equal(t, logs, got, "an endpoint hiding a log is outvoted")
+
+	b.logs = append([]types.Log{}, logs...)
+	b.logs[1].Data = []byte{1}
+	_, err = m.FilterLogs(ctx, ethereum.FilterQuery{})
+	require.ErrorIs(t, err, ErrNoQuorum)
+}
+
+func TestMultiBackend_SubscribeNewHead(t *testing.T) {
+	a, b, c := newFakeBackend(10, 0), newFakeBackend(11, 0), newFakeBackend(12, 0)
+	m := newTestMultiBackend(t, 2, a, b, c)
+
+	ch := make(chan *types.Header)
+	sub, err := m.SubscribeNewHead(context.Background(), ch)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// The primary pushes its own head, but the quorum only reached block 11.
+	go func() { a.heads <- c.header(12) }()
+	select {
+	case h := <-ch:
+		require.Equal(t, int64(11), h.Number.Int64())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no head delivered")
+	}
+}
+
+func TestDialMultiBackend(t *testing.T) {
+	ctx := context.Background()
+	a, b := newFakeBackend(10, 0), newFakeBackend(10, 0)
+	var reachable bool
+	dialers := []Dialer{
+		func(context.Context) (EthBackend, error) { return a, nil },
+		func(context.Context) (EthBackend, error) {
+			if !reachable {
+				return nil, errors.New("connection refused")
+			}
+			return b, nil
+		},
+	}
+
+	_, err := DialMultiBackend(ctx, dialers, []string{"a", "b"}, 2)
+	require.ErrorContains(t, err, "1 of 2 rpc endpoints reachable")
+	require.True(t, a.closed)
+
+	m, err := DialMultiBackend(ctx, dialers, []string{"a", "b"}, 1)
+	require.NoError(t, err)
+	m.primary.Store(1)
+	a.err = errors.New("down")
+
+	// The unreachable endpoint is not dialed again before redialInterval.
+	reachable = true
+	_, err = m.HeaderByNumber(ctx, nil)
+	require.ErrorContains(t, err, "down")
+	require.Zero(t, b.callCount())
+
+	m.endpoints[1].dialedAt = time.Time{}
+	_, err = m.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, b.callCount())
+}
@@ -0,0 +1,118 @@
+package custody
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// reorgHistoryBlocks bounds how far back a stream remembers delivered blocks.
+// A reorg deeper than this cannot be traced to its fork point and is reported
+// from the oldest remembered block instead.
+const reorgHistoryBlocks = 512
+
+type journalEntry struct {
+	ref    BlockRef
+	events []Event
+}
+
+// blockJournal records the hashes of the blocks a stream delivered events from
+// and of every confirmed block it advanced its cursor to, oldest first.
+// Because block hashes commit to their parent, the newest entry being
+// canonical implies that every older entry is canonical as well.
+type blockJournal struct {
+	entries []journalEntry
+}
+
+func newBlockJournal(from Cursor) *blockJournal {
+	j := &blockJournal{}
+	for _, ref := range from.History {
+		j.record(ref, nil)
+	}
+	if from.BlockHash != (common.Hash{}) {
+		j.record(BlockRef{Number: from.BlockNumber, Hash: from.BlockHash}, nil)
+	}
+	return j
+}
+
+// record appends ref to the journal, attaching ev to it if non-nil.
+// Refs older than the newest entry are ignored. If a ref at the newest height
+// carries a different hash, the first one seen is kept: whichever is stale
+// will fail the next canonical check and surface as a reorg.
+func (j *blockJournal) record(ref BlockRef, ev Event) {
+	if n := len(j.entries); n > 0 {
+		last := &j.entries[n-1]
+		if ref.Number < last.ref.Number {
+			return
+		}
+		if ref.Number == last.ref.Number {
+			if ev != nil {
+				last.events = append(last.events, ev)
+			}
+			return
+		}
+	}
+
+	entry := journalEntry{ref: ref}
+	if ev != nil {
+		entry.events = []Event{ev}
+	}
+	j.entries = append(j.entries, entry)
+
+	keep := 0
+	for keep < len(j.entries) && j.entries[keep].ref.Number+reorgHistoryBlocks < ref.Number {
+		keep++
+	}
+	j.entries = j.entries[keep:]
+}
+
+// checkReorg verifies that the newest journal entry is still canonical. If it
+// is not, the journal is walked back to the most recent entry that is, the
+// orphaned entries are dropped and a ReorgEvent describing them is returned.
+func (j *blockJournal) checkReorg(ctx context.Context, client bind.ContractBackend) (*ReorgEvent, error) {
+	split := len(j.entries)
+	for split > 0 {
+		canonical, err := isCanonical(ctx, client, j.entries[split-1].ref)
+		if err != nil {
+			return nil, err
+		}
+		if canonical {
+			break
+		}
+		split--
+	}
+	if split == len(j.entries) {
+		return nil, nil
+	}
+
+	reorg := &ReorgEvent{ToBlock: j.entries[len(j.entries)-1].ref.Number}
+	if split > 0 {
+		reorg.Ancestor = j.entries[split-1].ref
+		reorg.FromBlock = reorg.Ancestor.Number + 1
+	} else {
+		reorg.FromBlock = j.entries[0].ref.Number
+		if reorg.FromBlock > 0 {
+			reorg.Ancestor = BlockRef{Number: reorg.FromBlock - 1}
+		}
+	}
+	for _, e := range j.entries[split:] {
+		reorg.Events = append(reorg.Events, e.events...)
+	}
+	j.entries = j.entries[:split]
+	return reorg, nil
+}
+
+func isCanonical(ctx context.Context, client bind.ContractBackend, ref BlockRef) (bool, error) {
+	header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(ref.Number))
+	if err != nil {
+		if errors.Is(err, ethereum.NotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return header.Hash() == ref.Hash, nil
+}
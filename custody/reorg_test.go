@@ -0,0 +1,45 @@
+package custody
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockJournal_CheckReorg(t *testing.T) {
+	chain := newFakeChain(6)
+	ref := func(n uint64) BlockRef { return BlockRef{Number: n, Hash: chain.hash(n)} }
+	ev := &WithdrawStartedEvent{WithdrawalID: [32]byte{5}, BlockNumber: 5, BlockHash: chain.hash(5)}
+
+	journal := newBlockJournal(Cursor{History: []BlockRef{ref(2), ref(4)}})
+	journal.record(ref(5), ev)
+	journal.record(ref(6), nil)
+
+	reorg, err := journal.checkReorg(context.Background(), chain)
+	require.NoError(t, err)
+	require.Nil(t, reorg, "canonical journal reported a reorg")
+
+	// Blocks from 5 on are replaced; 4 is the most recent common block.
+	chain.reorg(5, 1, nil, nil, nil)
+	reorg, err = journal.checkReorg(context.Background(), chain)
+	require.NoError(t, err)
+	require.Equal(t, &ReorgEvent{Ancestor: ref(4), FromBlock: 5, ToBlock: 6, Events: []Event{ev}}, reorg)
+
+	// The orphaned blocks were dropped, so the journal is canonical again.
+	reorg, err = journal.checkReorg(context.Background(), chain)
+	require.NoError(t, err)
+	require.Nil(t, reorg)
+}
+
+func TestBlockJournal_ReorgDeeperThanJournal(t *testing.T) {
+	chain := newFakeChain(6)
+	journal := newBlockJournal(Cursor{BlockNumber: 5, BlockHash: chain.hash(5)})
+
+	chain.reorg(3, 1, nil, nil, nil, nil)
+	reorg, err := journal.checkReorg(context.Background(), chain)
+	require.NoError(t, err)
+	// The fork point is unknown, so everything from the oldest remembered
+	// block is reported with an ancestor without hash.
+	require.Equal(t, &ReorgEvent{Ancestor: BlockRef{Number: 4}, FromBlock: 5, ToBlock: 5}, reorg)
+}
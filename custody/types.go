@@ -17,18 +17,28 @@ type WithdrawStartedEvent struct {
 	Token        common.Address
 	Amount       *big.Int
 	Nonce        *big.Int
+	Contract     common.Address
 	BlockNumber  uint64
+	BlockHash    common.Hash
+	BlockTime    time.Time
 	TxHash       common.Hash
 	LogIndex     uint
+
+	*ackHandle
 }
 
 // WithdrawFinalizedEvent represents a confirmed WithdrawFinalized event from the custody contract.
 type WithdrawFinalizedEvent struct {
 	WithdrawalID [32]byte
 	Success      bool
+	Contract     common.Address
 	BlockNumber  uint64
+	BlockHash    common.Hash
+	BlockTime    time.Time
 	TxHash       common.Hash
 	LogIndex     uint
+
+	*ackHandle
 }
 
 // DepositedEvent represents a confirmed Deposited event from the custody contract.
@@ -36,9 +46,192 @@ type DepositedEvent struct {
 	User        common.Address
 	Token       common.Address
 	Amount      *big.Int
+	Contract    common.Address
+	BlockNumber uint64
+	BlockHash   common.Hash
+	BlockTime   time.Time
+	TxHash      common.Hash
+	LogIndex    uint
+
+	*ackHandle
+}
+
+// WithdrawalApprovedEvent represents a confirmed WithdrawalApproved event, emitted by
+// ThresholdCustody and QuorumCustody each time a signer approves a withdrawal.
+type WithdrawalApprovedEvent struct {
+	WithdrawalID     [32]byte
+	Signer           common.Address
+	CurrentApprovals *big.Int
+	Contract         common.Address
+	BlockNumber      uint64
+	BlockHash        common.Hash
+	BlockTime        time.Time
+	TxHash           common.Hash
+	LogIndex         uint
+
+	*ackHandle
+}
+
+// SignerAddedEvent represents a confirmed signer addition. QuorumCustody emits
+// SignerAdded with the signer address and the resulting quorum.
+// ThresholdCustody emits ERC7913SignerAdded for signers identified by bytes,
+// of which the log only carries the keccak256 hash: Signer and NewQuorum are
+// then zero and SignerHash is set.
+type SignerAddedEvent struct {
+	Signer      common.Address
+	SignerHash  common.Hash
+	NewQuorum   uint64
+	Contract    common.Address
+	BlockNumber uint64
+	BlockHash   common.Hash
+	BlockTime   time.Time
+	TxHash      common.Hash
+	LogIndex    uint
+
+	*ackHandle
+}
+
+// SignerRemovedEvent represents a confirmed signer removal. Fields are set as
+// for SignerAddedEvent, from SignerRemoved or ERC7913SignerRemoved.
+type SignerRemovedEvent struct {
+	Signer      common.Address
+	SignerHash  common.Hash
+	NewQuorum   uint64
+	Contract    common.Address
+	BlockNumber uint64
+	BlockHash   common.Hash
+	BlockTime   time.Time
+	TxHash      common.Hash
+	LogIndex    uint
+
+	*ackHandle
+}
+
+// QuorumChangedEvent represents a confirmed change of the number of approvals a
+// withdrawal needs: QuorumChanged from QuorumCustody, or ERC7913ThresholdSet
+// from ThresholdCustody, which does not report the previous value (OldQuorum
+// is then zero).
+type QuorumChangedEvent struct {
+	OldQuorum   uint64
+	NewQuorum   uint64
+	Contract    common.Address
 	BlockNumber uint64
+	BlockHash   common.Hash
+	BlockTime   time.Time
 	TxHash      common.Hash
 	LogIndex    uint
+
+	*ackHandle
+}
+
+// RateLimitUpdatedEvent represents a confirmed RateLimitUpdated event from
+// ThresholdCustody: the token bucket capacity and its refill interval in seconds.
+type RateLimitUpdatedEvent struct {
+	NewCapacity       *big.Int
+	NewRefillInterval *big.Int
+	Contract          common.Address
+	BlockNumber       uint64
+	BlockHash         common.Hash
+	BlockTime         time.Time
+	TxHash            common.Hash
+	LogIndex          uint
+
+	*ackHandle
+}
+
+// Event is implemented by every confirmed custody event delivered by the Listener.
+type Event interface {
+	// Block returns the block the event was emitted in.
+	Block() BlockRef
+	// Ack acknowledges the event; see EventListener for the delivery contract.
+	Ack(err error)
+
+	awaitAck(ctx context.Context) error
+}
+
+func (e *WithdrawStartedEvent) Block() BlockRef {
+	return BlockRef{Number: e.BlockNumber, Hash: e.BlockHash}
+}
+
+func (e *WithdrawFinalizedEvent) Block() BlockRef {
+	return BlockRef{Number: e.BlockNumber, Hash: e.BlockHash}
+}
+
+func (e *DepositedEvent) Block() BlockRef {
+	return BlockRef{Number: e.BlockNumber, Hash: e.BlockHash}
+}
+
+func (e *WithdrawalApprovedEvent) Block() BlockRef {
+	return BlockRef{Number: e.BlockNumber, Hash: e.BlockHash}
+}
+
+func (e *SignerAddedEvent) Block() BlockRef {
+	return BlockRef{Number: e.BlockNumber, Hash: e.BlockHash}
+}
+
+func (e *SignerRemovedEvent) Block() BlockRef {
+	return BlockRef{Number: e.BlockNumber, Hash: e.BlockHash}
+}
+
+func (e *QuorumChangedEvent) Block() BlockRef {
+	return BlockRef{Number: e.BlockNumber, Hash: e.BlockHash}
+}
+
+func (e *RateLimitUpdatedEvent) Block() BlockRef {
+	return BlockRef{Number: e.BlockNumber, Hash: e.BlockHash}
+}
+
+// BlockRef identifies a block by number and hash.
+type BlockRef struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+// Cursor is the position a stream resumes from: events at BlockNumber with a
+// log index <= LogIndex are considered delivered.
+// When BlockHash is set, the Listener verifies that the cursor block is still
+// canonical before resuming, so a reorg that happened while it was not running
+// is detected. History optionally lists earlier delivered blocks (oldest
+// first), which lets the Listener locate the fork point of such a reorg.
+//
+// A Listener watching several contracts resumes from a single cursor, which
+// has to be the position of the contract that is furthest behind. Contracts
+// optionally lists the positions of the others: their logs at or before
+// their own position are skipped instead of being delivered again.
+type Cursor struct {
+	BlockNumber uint64
+	LogIndex    uint32
+	BlockHash   common.Hash
+	BlockTime   time.Time
+	History     []BlockRef
+	Contracts   map[common.Address]Position
+}
+
+// Position identifies the last delivered log of a stream: events at
+// BlockNumber with a log index <= LogIndex are considered delivered.
+type Position struct {
+	BlockNumber uint64
+	LogIndex    uint32
+}
+
+// covers reports whether the log at the given position was delivered.
+func (p Position) covers(blockNumber uint64, logIndex uint) bool {
+	return blockNumber < p.BlockNumber || (blockNumber == p.BlockNumber && logIndex <= uint(p.LogIndex))
+}
+
+// ReorgEvent notifies a consumer that events it already received were emitted
+// in blocks that are no longer part of the canonical chain. After sending it,
+// the Listener resumes from Ancestor and re-delivers whatever events the new
+// canonical chain contains after it.
+type ReorgEvent struct {
+	// Ancestor is the most recent delivered block that is still canonical.
+	// Its Hash is zero when the reorg is deeper than the Listener remembers.
+	Ancestor BlockRef
+	// FromBlock and ToBlock bound the orphaned range (inclusive).
+	FromBlock uint64
+	ToBlock   uint64
+	// Events lists the orphaned events in the order they were delivered.
+	Events []Event
 }
 
 // Withdrawal represents a recorded withdrawal for limit tracking.
@@ -49,7 +242,9 @@ type Withdrawal struct {
 	Amount       *big.Int
 	BlockNumber  uint64
 	TxHash       common.Hash
-	Timestamp    time.Time
+	// Timestamp is the chain time the withdrawal is accounted at: the
+	// timestamp of the block that emitted its WithdrawStarted event.
+	Timestamp time.Time
 }
 
 // Custody defines the write operations for the IWithdraw smart contract.
@@ -60,19 +255,60 @@ type Custody interface {
 }
 
 // EventListener defines the ability to subscribe to custody contract events.
-// Each method blocks until the context is cancelled; callers should run them in goroutines.
-// The sink channel is closed when the method returns.
+// Each method blocks until the context is cancelled, in which case it returns
+// nil, or until the stream fails for good, in which case it returns the error
+// (wrapping ErrBackOffExceeded if the node kept failing, or
+// ErrRedeliveryExceeded if the consumer did). Callers should run
+// them in goroutines and restart the stream from their cursor on failure.
+// Chain reorganizations that orphan delivered events are reported on the
+// reorgs channel (which may be nil if the caller does not care).
+// The sink and reorgs channels are closed when the method returns.
+//
+// Delivery is at-least-once. Without acknowledgements, a stream considers an
+// event delivered as soon as the sink accepts it. With acknowledgements
+// enabled (see WithAcknowledgements), every event must be acknowledged with
+// Ack: the stream delivers nothing else until then, only advances its cursor
+// after a nil acknowledgement, and redelivers the event after a non-nil one,
+// a limited number of times.
+// A consumer gets exactly-once effects by making its processing idempotent
+// per event and persisting its own cursor atomically with the outcome before
+// acknowledging; after a restart it resumes from that cursor, so the only
+// event it can see twice is one whose outcome was not yet committed.
 type EventListener interface {
-	WatchWithdrawStarted(ctx context.Context, sink chan<- *WithdrawStartedEvent, fromBlock uint64, fromLogIndex uint32)
-	WatchWithdrawFinalized(ctx context.Context, sink chan<- *WithdrawFinalizedEvent, fromBlock uint64, fromLogIndex uint32)
-	WatchDeposited(ctx context.Context, sink chan<- *DepositedEvent, fromBlock uint64, fromLogIndex uint32)
+	WatchWithdrawStarted(ctx context.Context, sink chan<- *WithdrawStartedEvent, reorgs chan<- *ReorgEvent, from Cursor) error
+	WatchWithdrawFinalized(ctx context.Context, sink chan<- *WithdrawFinalizedEvent, reorgs chan<- *ReorgEvent, from Cursor) error
+	WatchDeposited(ctx context.Context, sink chan<- *DepositedEvent, reorgs chan<- *ReorgEvent, from Cursor) error
+	WatchWithdrawalApproved(ctx context.Context, sink chan<- *WithdrawalApprovedEvent, reorgs chan<- *ReorgEvent, from Cursor) error
+	WatchSignerAdded(ctx context.Context, sink chan<- *SignerAddedEvent, reorgs chan<- *ReorgEvent, from Cursor) error
+	WatchSignerRemoved(ctx context.Context, sink chan<- *SignerRemovedEvent, reorgs chan<- *ReorgEvent, from Cursor) error
+	WatchQuorumChanged(ctx context.Context, sink chan<- *QuorumChangedEvent, reorgs chan<- *ReorgEvent, from Cursor) error
+	WatchRateLimitUpdated(ctx context.Context, sink chan<- *RateLimitUpdatedEvent, reorgs chan<- *ReorgEvent, from Cursor) error
+	// WatchAll delivers every event type in a single stream, strictly ordered
+	// by block number and log index.
+	WatchAll(ctx context.Context, sink chan<- Event, reorgs chan<- *ReorgEvent, from Cursor) error
 }
 
 // WithdrawalStore defines the storage operations for tracking withdrawals.
 type WithdrawalStore interface {
 	Save(w *Withdrawal) error
-	GetTotalWithdrawn(token common.Address, since time.Time) (*big.Int, error)
-	GetTotalWithdrawnByUser(user common.Address, token common.Address, since time.Time) (*big.Int, error)
+	// GetTotalWithdrawn, GetTotalWithdrawnByUser, CountWithdrawals and
+	// CountWithdrawalsByUser aggregate the withdrawals recorded from since up
+	// to and including until, so that a window ending at a past time gives
+	// the same result whatever was recorded after it.
+	GetTotalWithdrawn(token common.Address, since, until time.Time) (*big.Int, error)
+	GetTotalWithdrawnByUser(user common.Address, token common.Address, since, until time.Time) (*big.Int, error)
+	CountWithdrawals(token common.Address, since, until time.Time) (uint64, error)
+	CountWithdrawalsByUser(user common.Address, token common.Address, since, until time.Time) (uint64, error)
+	// GetWithdrawalsByUser returns the withdrawals of all tokens by user
+	// since the given time, oldest first.
+	GetWithdrawalsByUser(user common.Address, since time.Time) ([]*Withdrawal, error)
+}
+
+// DeadLetterStore persists logs that a Listener fetched for a stream but could
+// not decode, e.g. after a contract upgrade changed an event ABI, so that they
+// can be inspected and replayed instead of being dropped.
+type DeadLetterStore interface {
+	SaveDeadLetter(stream string, log types.Log, reason string) error
 }
 
 // EthBackend is the Ethereum client interface required by the service.
@@ -81,5 +317,6 @@ type EthBackend interface {
 	bind.ContractBackend
 	bind.DeployBackend
 	ChainID(ctx context.Context) (*big.Int, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
 	Close()
 }
@@ -0,0 +1,132 @@
+// Package addrlist loads sets of addresses, such as sanctions lists, from
+// files that operators can update while the worker runs. A file lists one
+// hex address per line; blank lines and text after '#' are ignored.
+package addrlist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// List is a set of addresses loaded from a file. It is safe for concurrent
+// use; Reload swaps in the new contents atomically.
+type List struct {
+	path string
+
+	mu      sync.RWMutex
+	addrs   map[common.Address]struct{}
+	modTime time.Time
+	size    int64
+}
+
+// Load reads the list at path. It fails if the file is missing or contains
+// anything but addresses, so that a typo cannot silently disable the list.
+func Load(path string) (*List, error) {
+	l := &List{path: path}
+	if _, err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Path returns the file the list is loaded from.
+func (l *List) Path() string {
+	return l.path
+}
+
+// Contains reports whether addr is on the list.
+func (l *List) Contains(addr common.Address) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.addrs[addr]
+	return ok
+}
+
+// Len returns the number of addresses on the list.
+func (l *List) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.addrs)
+}
+
+// Reload re-reads the file if its modification time or size changed since it
+// was last loaded, and reports whether it did. On error the previous contents
+// are kept.
+func (l *List) Reload() (bool, error) {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false, err
+	}
+	l.mu.RLock()
+	unchanged := l.addrs != nil && info.ModTime().Equal(l.modTime) && info.Size() == l.size
+	l.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return false, err
+	}
+	addrs, err := parse(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", l.path, err)
+	}
+
+	l.mu.Lock()
+	l.addrs, l.modTime, l.size = addrs, info.ModTime(), info.Size()
+	l.mu.Unlock()
+	return true, nil
+}
+
+func parse(data []byte) (map[common.Address]struct{}, error) {
+	addrs := make(map[common.Address]struct{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if !common.IsHexAddress(text) {
+			return nil, fmt.Errorf("line %d: invalid address %q", line, text)
+		}
+		addrs[common.HexToAddress(text)] = struct{}{}
+	}
+	return addrs, scanner.Err()
+}
+
+// Watch reloads the lists whenever their files change, checking every
+// interval, until ctx is cancelled. A list that fails to reload keeps its
+// previous contents and the error is logged.
+func Watch(ctx context.Context, interval time.Duration, logger *slog.Logger, lists ...*List) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, l := range lists {
+				reloaded, err := l.Reload()
+				if err != nil {
+					logger.Error("Failed to reload address list, keeping previous contents", "path", l.path, "error", err)
+					continue
+				}
+				if reloaded {
+					logger.Info("Reloaded address list", "path", l.path, "addresses", l.Len())
+				}
+			}
+		}
+	}
+}
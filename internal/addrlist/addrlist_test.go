@@ -0,0 +1,66 @@
+package addrlist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	addrA = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	addrB = common.HexToAddress("0x2222222222222222222222222222222222222222")
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(path, []byte(`# OFAC SDN, 2025-01-01
+0x1111111111111111111111111111111111111111
+
+0x2222222222222222222222222222222222222222  # mixer
+`), 0o600))
+
+	l, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, l.Len())
+	require.True(t, l.Contains(addrA))
+	require.True(t, l.Contains(addrB))
+	require.False(t, l.Contains(common.Address{}))
+}
+
+func TestLoad_InvalidAddress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("0x1111111111111111111111111111111111111111\nnot-an-address\n"), 0o600))
+
+	_, err := Load(path)
+	require.ErrorContains(t, err, "line 2")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.txt"))
+	require.Error(t, err)
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(path, []byte(addrA.Hex()+"\n"), 0o600))
+	l, err := Load(path)
+	require.NoError(t, err)
+
+	reloaded, err := l.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded, "unchanged file should not be reloaded")
+
+	require.NoError(t, os.WriteFile(path, []byte(addrA.Hex()+"\n"+addrB.Hex()+"\n"), 0o600))
+	reloaded, err = l.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.True(t, l.Contains(addrB))
+
+	// A broken update keeps the previous contents.
+	require.NoError(t, os.WriteFile(path, []byte("oops\n"), 0o600))
+	_, err = l.Reload()
+	require.Error(t, err)
+	require.True(t, l.Contains(addrA))
+	require.True(t, l.Contains(addrB))
+}
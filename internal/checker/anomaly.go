@@ -0,0 +1,148 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrAnomalousAmount    = errors.New("withdrawal amount is unusual for user")
+	ErrAnomalousFrequency = errors.New("withdrawal frequency is unusual for user")
+	ErrNewToken           = errors.New("first withdrawal of token by user")
+)
+
+// AnomalyDetection flags withdrawals that are unusual compared to the history
+// of the user: their withdrawals within Lookback before the one checked.
+type AnomalyDetection struct {
+	Lookback time.Duration
+	// MinHistory is the number of past withdrawals of a token a user needs
+	// before amounts and frequency are judged against them.
+	MinHistory int
+	// AmountFactor flags withdrawals of more than AmountFactor times the
+	// median amount of the token the user withdrew. Zero disables the check.
+	AmountFactor float64
+	// FrequencyFactor flags a withdrawal if the user's withdrawals of the
+	// token within FrequencyWindow, counting it, exceed FrequencyFactor times
+	// their average number per FrequencyWindow. Zero disables the check.
+	FrequencyFactor float64
+	FrequencyWindow time.Duration
+	// NewToken flags the first withdrawal of a token by a user who withdrew
+	// other tokens before. Users without any history are not flagged.
+	NewToken bool
+}
+
+// AnomalyStats is the history a withdrawal was compared to. Amounts are
+// those of the token of the withdrawal and nil without history.
+type AnomalyStats struct {
+	// History is the number of withdrawals of the token by the user within
+	// the lookback, and Tokens the number of distinct tokens withdrawn.
+	History int
+	Tokens  int
+	Mean    *big.Int
+	Median  *big.Int
+	P90     *big.Int
+	Max     *big.Int
+	// Recent is the number of withdrawals of the token within the frequency
+	// window before the one checked, and Expected their average number per
+	// window over the history.
+	Recent   int
+	Expected float64
+}
+
+// WithAnomalyDetection makes the Checker flag withdrawals that are unusual
+// for the user.
+func WithAnomalyDetection(conf AnomalyDetection) Option {
+	return func(c *Checker) {
+		c.anomaly = &conf
+	}
+}
+
+// checkAnomaly compares a withdrawal to the history of the user. It returns
+// the statistics it computed, also when the withdrawal is flagged.
+func (c *Checker) checkAnomaly(user, token common.Address, amount *big.Int, now time.Time) (*AnomalyStats, error) {
+	conf := c.anomaly
+	if conf == nil {
+		return nil, nil
+	}
+	history, err := c.store.GetWithdrawalsByUser(user, now.Add(-conf.Lookback))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get withdrawal history: %w", err)
+	}
+
+	stats := &AnomalyStats{}
+	tokens := make(map[common.Address]bool)
+	var amounts []*big.Int
+	var first time.Time
+	for _, w := range history {
+		if w.Timestamp.After(now) {
+			continue
+		}
+		tokens[w.Token] = true
+		if w.Token != token {
+			continue
+		}
+		if len(amounts) == 0 {
+			first = w.Timestamp
+		}
+		amounts = append(amounts, w.Amount)
+		if conf.FrequencyWindow > 0 && now.Sub(w.Timestamp) < conf.FrequencyWindow {
+			stats.Recent++
+		}
+	}
+	stats.History = len(amounts)
+	stats.Tokens = len(tokens)
+
+	if len(amounts) == 0 {
+		if conf.NewToken && len(tokens) > 0 {
+			return stats, fmt.Errorf("%w %s: %s, previously withdrew %d other tokens", ErrNewToken, user.Hex(), token.Hex(), len(tokens))
+		}
+		return stats, nil
+	}
+
+	slices.SortFunc(amounts, func(a, b *big.Int) int { return a.Cmp(b) })
+	sum := new(big.Int)
+	for _, a := range amounts {
+		sum.Add(sum, a)
+	}
+	stats.Mean = sum.Div(sum, big.NewInt(int64(len(amounts))))
+	stats.Median = percentile(amounts, 50)
+	stats.P90 = percentile(amounts, 90)
+	stats.Max = amounts[len(amounts)-1]
+
+	if conf.FrequencyWindow > 0 {
+		// The history of the token spans from its first withdrawal to now,
+		// but at least one window.
+		span := max(now.Sub(first), conf.FrequencyWindow)
+		stats.Expected = float64(len(amounts)) * float64(conf.FrequencyWindow) / float64(span)
+	}
+
+	if len(amounts) < conf.MinHistory {
+		return stats, nil
+	}
+	if conf.AmountFactor > 0 {
+		threshold := new(big.Rat).SetInt(stats.Median)
+		threshold.Mul(threshold, new(big.Rat).SetFloat64(conf.AmountFactor))
+		if new(big.Rat).SetInt(amount).Cmp(threshold) > 0 {
+			return stats, fmt.Errorf("%w %s: %s > %g x median %s", ErrAnomalousAmount, user.Hex(), amount, conf.AmountFactor, stats.Median)
+		}
+	}
+	if conf.FrequencyFactor > 0 && conf.FrequencyWindow > 0 {
+		n := float64(stats.Recent + 1)
+		if n > 1 && n > conf.FrequencyFactor*stats.Expected {
+			return stats, fmt.Errorf("%w %s: %d withdrawals within %s > %g x average %.2f",
+				ErrAnomalousFrequency, user.Hex(), stats.Recent+1, formatPeriod(conf.FrequencyWindow), conf.FrequencyFactor, stats.Expected)
+		}
+	}
+	return stats, nil
+}
+
+// percentile returns the nearest-rank percentile p of sorted amounts.
+func percentile(sorted []*big.Int, p int) *big.Int {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
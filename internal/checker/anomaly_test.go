@@ -0,0 +1,116 @@
+package checker
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/layer-3/nitewatch/custody"
+)
+
+var anomalyLimits = map[common.Address]Limit{
+	tokenA: {Daily: big.NewInt(1e18)},
+	tokenB: {Daily: big.NewInt(1e18)},
+}
+
+// dailyHistory returns withdrawals of token by userA, one per day before at.
+func dailyHistory(token common.Address, at time.Time, amounts ...int64) []*custody.Withdrawal {
+	var history []*custody.Withdrawal
+	for i, amount := range amounts {
+		history = append(history, &custody.Withdrawal{
+			User:      userA,
+			Token:     token,
+			Amount:    big.NewInt(amount),
+			Timestamp: at.Add(-time.Duration(len(amounts)-i) * 24 * time.Hour),
+		})
+	}
+	return history
+}
+
+func TestEvaluate_AnomalousAmount(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := &mockStore{withdrawals: dailyHistory(tokenA, at, 100, 120, 80, 100, 300)}
+	c := New(anomalyLimits, nil, store, WithAnomalyDetection(AnomalyDetection{
+		Lookback:     30 * 24 * time.Hour,
+		MinHistory:   5,
+		AmountFactor: 10,
+	}))
+
+	eval, err := c.Evaluate(userA, tokenA, big.NewInt(1000), at)
+	require.NoError(t, err)
+	require.Equal(t, 5, eval.Anomaly.History)
+	require.Equal(t, "140", eval.Anomaly.Mean.String())
+	require.Equal(t, "100", eval.Anomaly.Median.String())
+	require.Equal(t, "300", eval.Anomaly.P90.String())
+	require.Equal(t, "300", eval.Anomaly.Max.String())
+
+	eval, err = c.Evaluate(userA, tokenA, big.NewInt(1001), at)
+	require.ErrorIs(t, err, ErrAnomalousAmount)
+	require.Equal(t, ReasonAnomalousAmount, ReasonCode(err))
+	require.NotNil(t, eval.Anomaly, "statistics should be reported for flagged withdrawals")
+
+	// History older than the lookback is ignored.
+	eval, err = c.Evaluate(userA, tokenA, big.NewInt(1001), at.Add(40*24*time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, eval.Anomaly.History)
+}
+
+func TestEvaluate_AnomalyNeedsMinHistory(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := &mockStore{withdrawals: dailyHistory(tokenA, at, 100, 100)}
+	c := New(anomalyLimits, nil, store, WithAnomalyDetection(AnomalyDetection{
+		Lookback:     30 * 24 * time.Hour,
+		MinHistory:   3,
+		AmountFactor: 10,
+	}))
+
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(1e6), at))
+
+	// A user without any history is not flagged either.
+	require.NoError(t, c.CheckAt(userB, tokenA, big.NewInt(1e6), at))
+}
+
+func TestEvaluate_NewToken(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := &mockStore{withdrawals: dailyHistory(tokenA, at, 100)}
+	c := New(anomalyLimits, nil, store, WithAnomalyDetection(AnomalyDetection{
+		Lookback: 30 * 24 * time.Hour,
+		NewToken: true,
+	}))
+
+	err := c.CheckAt(userA, tokenB, big.NewInt(100), at)
+	require.ErrorIs(t, err, ErrNewToken)
+	require.Equal(t, ReasonNewToken, ReasonCode(err))
+
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(100), at))
+	require.NoError(t, c.CheckAt(userB, tokenB, big.NewInt(100), at))
+}
+
+func TestEvaluate_AnomalousFrequency(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	history := dailyHistory(tokenA, at, 100, 100, 100, 100, 100, 100)
+	store := &mockStore{withdrawals: history}
+	c := New(anomalyLimits, nil, store, WithAnomalyDetection(AnomalyDetection{
+		Lookback:        30 * 24 * time.Hour,
+		MinHistory:      5,
+		FrequencyFactor: 20,
+		FrequencyWindow: time.Hour,
+	}))
+
+	// One withdrawal a day: a single one within the hour is usual.
+	eval, err := c.Evaluate(userA, tokenA, big.NewInt(100), at)
+	require.NoError(t, err)
+	require.Zero(t, eval.Anomaly.Recent)
+	require.InDelta(t, 1.0/24, eval.Anomaly.Expected, 1e-9)
+
+	// A second one within the hour: 2 > 20 x 7/144.
+	store.withdrawals = append(store.withdrawals, &custody.Withdrawal{
+		User: userA, Token: tokenA, Amount: big.NewInt(100), Timestamp: at.Add(-10 * time.Minute),
+	})
+	eval, err = c.Evaluate(userA, tokenA, big.NewInt(100), at)
+	require.ErrorIs(t, err, ErrAnomalousFrequency)
+	require.Equal(t, 1, eval.Anomaly.Recent)
+}
@@ -9,73 +9,357 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 
 	"github.com/layer-3/nitewatch/custody"
+	"github.com/layer-3/nitewatch/policy"
 )
 
 var (
-	ErrNoLimitsConfigured      = errors.New("no limits configured for token")
-	ErrHourlyLimitExceeded     = errors.New("hourly limit exceeded")
-	ErrDailyLimitExceeded      = errors.New("daily limit exceeded")
-	ErrUserHourlyLimitExceeded = errors.New("per-user hourly limit exceeded")
-	ErrUserDailyLimitExceeded  = errors.New("per-user daily limit exceeded")
-	ErrInvalidAmount           = errors.New("amount must be positive")
-	ErrInvalidUser             = errors.New("user address must not be zero")
+	ErrNoLimitsConfigured            = errors.New("no limits configured for token")
+	ErrHourlyLimitExceeded           = errors.New("hourly limit exceeded")
+	ErrDailyLimitExceeded            = errors.New("daily limit exceeded")
+	ErrUserHourlyLimitExceeded       = errors.New("per-user hourly limit exceeded")
+	ErrUserDailyLimitExceeded        = errors.New("per-user daily limit exceeded")
+	ErrCrossChainHourlyLimitExceeded = errors.New("cross-chain hourly limit exceeded")
+	ErrCrossChainDailyLimitExceeded  = errors.New("cross-chain daily limit exceeded")
+	ErrWindowLimitExceeded           = errors.New("window limit exceeded")
+	ErrUserWindowLimitExceeded       = errors.New("per-user window limit exceeded")
+	ErrCrossChainWindowLimitExceeded = errors.New("cross-chain window limit exceeded")
+	ErrHourlyCountExceeded           = errors.New("hourly withdrawal count exceeded")
+	ErrDailyCountExceeded            = errors.New("daily withdrawal count exceeded")
+	ErrWindowCountExceeded           = errors.New("window withdrawal count exceeded")
+	ErrUserHourlyCountExceeded       = errors.New("per-user hourly withdrawal count exceeded")
+	ErrUserDailyCountExceeded        = errors.New("per-user daily withdrawal count exceeded")
+	ErrUserWindowCountExceeded       = errors.New("per-user window withdrawal count exceeded")
+	ErrInvalidAmount                 = errors.New("amount must be positive")
+	ErrInvalidUser                   = errors.New("user address must not be zero")
 )
 
+// Reason codes classify why a withdrawal was rejected, for recording and
+// reporting alongside the human-readable reason.
+const (
+	ReasonInvalidAmount         = "invalid_amount"
+	ReasonInvalidRecipient      = "invalid_recipient"
+	ReasonRecipientDenied       = "recipient_denied"
+	ReasonRecipientNotAllowed   = "recipient_not_allowed"
+	ReasonNoLimits              = "no_limits"
+	ReasonAmountLimit           = "amount_limit"
+	ReasonCountLimit            = "count_limit"
+	ReasonFiatLimit             = "fiat_limit"
+	ReasonPriceUnavailable      = "price_unavailable"
+	ReasonAnomalousAmount       = "anomalous_amount"
+	ReasonAnomalousFrequency    = "anomalous_frequency"
+	ReasonNewToken              = "new_token"
+	ReasonInsufficientLiquidity = "insufficient_liquidity"
+	ReasonReserveFloor          = "reserve_floor"
+	ReasonLiquidityUnavailable  = "liquidity_unavailable"
+	ReasonCheckFailed           = "check_failed"
+)
+
+var reasonCodes = []struct {
+	code string
+	errs []error
+}{
+	{ReasonInvalidAmount, []error{ErrInvalidAmount}},
+	{ReasonInvalidRecipient, []error{ErrInvalidUser}},
+	{ReasonRecipientDenied, []error{ErrRecipientDenied}},
+	{ReasonRecipientNotAllowed, []error{ErrRecipientNotAllowed}},
+	{ReasonNoLimits, []error{ErrNoLimitsConfigured}},
+	{ReasonAmountLimit, []error{
+		ErrHourlyLimitExceeded, ErrDailyLimitExceeded, ErrWindowLimitExceeded,
+		ErrUserHourlyLimitExceeded, ErrUserDailyLimitExceeded, ErrUserWindowLimitExceeded,
+		ErrCrossChainHourlyLimitExceeded, ErrCrossChainDailyLimitExceeded, ErrCrossChainWindowLimitExceeded,
+	}},
+	{ReasonCountLimit, []error{
+		ErrHourlyCountExceeded, ErrDailyCountExceeded, ErrWindowCountExceeded,
+		ErrUserHourlyCountExceeded, ErrUserDailyCountExceeded, ErrUserWindowCountExceeded,
+	}},
+	{ReasonFiatLimit, []error{
+		ErrFiatHourlyLimitExceeded, ErrFiatDailyLimitExceeded,
+		ErrUserFiatHourlyLimitExceeded, ErrUserFiatDailyLimitExceeded,
+	}},
+	{ReasonPriceUnavailable, []error{ErrNoPrice, ErrPricesUnavailable}},
+	{ReasonAnomalousAmount, []error{ErrAnomalousAmount}},
+	{ReasonAnomalousFrequency, []error{ErrAnomalousFrequency}},
+	{ReasonNewToken, []error{ErrNewToken}},
+	{ReasonInsufficientLiquidity, []error{ErrInsufficientLiquidity}},
+	{ReasonReserveFloor, []error{ErrReserveFloor}},
+	{ReasonLiquidityUnavailable, []error{ErrLiquidityUnavailable}},
+}
+
+// ReasonCode returns the reason code of an error returned by CheckAt, or
+// ReasonCheckFailed if the check could not be completed, e.g. because the
+// store failed.
+func ReasonCode(err error) string {
+	for _, rc := range reasonCodes {
+		for _, e := range rc.errs {
+			if errors.Is(err, e) {
+				return rc.code
+			}
+		}
+	}
+	return ReasonCheckFailed
+}
+
 type Limit struct {
 	Hourly *big.Int
 	Daily  *big.Int
+	// Windows caps withdrawals over further periods.
+	Windows []WindowLimit
+	// Count caps the number of withdrawals in the scope of the limit: of the
+	// token in global limits, of the user in user overrides.
+	Count CountLimit
+	// UserCount caps the number of withdrawals of the token by each user. It
+	// only applies to global limits.
+	UserCount CountLimit
+	Window    Window
+}
+
+// CountLimit caps the number of withdrawals per window. Zero means no cap.
+type CountLimit struct {
+	Hourly  uint64
+	Daily   uint64
+	Windows []WindowCount
+}
+
+// WindowCount caps the number of withdrawals within Period.
+type WindowCount struct {
+	Period time.Duration
+	Max    uint64
+}
+
+// WindowLimit caps the amount withdrawn within Period.
+type WindowLimit struct {
+	Period time.Duration
+	Max    *big.Int
+}
+
+// String formats the period the way it is configured, e.g. "7d" or "10m".
+func (w WindowLimit) String() string {
+	return formatPeriod(w.Period)
+}
+
+func formatPeriod(d time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case d%(7*day) == 0:
+		return fmt.Sprintf("%dw", d/(7*day))
+	case d%day == 0:
+		return fmt.Sprintf("%dd", d/day)
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
+
+// Window selects which withdrawals count towards the caps of a Limit.
+type Window int
+
+const (
+	// WindowCalendar counts withdrawals since the start of the current UTC
+	// hour or day, or for other periods since the last multiple of the period
+	// after 0001-01-01 UTC. A burst straddling a boundary can reach twice the
+	// limit.
+	WindowCalendar Window = iota
+	// WindowRolling counts withdrawals in the period up to the withdrawal
+	// being checked.
+	WindowRolling
+)
+
+// since returns the start of the window of the given length that contains now.
+func (l Limit) since(now time.Time, length time.Duration) time.Time {
+	return l.Window.since(now, length)
+}
+
+func (w Window) since(now time.Time, length time.Duration) time.Time {
+	if w == WindowRolling {
+		return now.Add(-length)
+	}
+	return now.Truncate(length)
+}
+
+// CrossChainLimit caps the combined withdrawals of tokens that represent the
+// same asset on different chains. Token is the asset on the checker's own
+// chain; Tokens lists it on every chain, including this one, together with the
+// store the withdrawals of that chain are recorded in.
+type CrossChainLimit struct {
+	Name   string
+	Token  common.Address
+	Tokens []ChainToken
+	Limit
+}
+
+// ChainToken is a token on one chain counted towards a CrossChainLimit.
+type ChainToken struct {
+	Token common.Address
+	Store custody.WithdrawalStore
+}
+
+// Option configures optional Checker behaviour.
+type Option func(*Checker)
+
+// WithCrossChainLimits makes the Checker enforce the given cross-chain limits
+// in addition to its own ones.
+func WithCrossChainLimits(limits ...CrossChainLimit) Option {
+	return func(c *Checker) {
+		c.crossChainLimits = append(c.crossChainLimits, limits...)
+	}
 }
 
 type Checker struct {
-	globalLimits  map[common.Address]Limit
-	userOverrides map[common.Address]map[common.Address]Limit
-	store         custody.WithdrawalStore
-	nowFunc       func() time.Time
+	globalLimits      map[common.Address]Limit
+	userOverrides     map[common.Address]map[common.Address]Limit
+	tiers             map[string]map[common.Address]Limit
+	tierSource        TierSource
+	defaultUserLimits map[common.Address]Limit
+	crossChainLimits  []CrossChainLimit
+	fiatLimits        *FiatLimits
+	prices            PriceSource
+	recipients        RecipientLists
+	anomaly           *AnomalyDetection
+	reserves          map[common.Address]*big.Int
+	liquidity         LiquiditySource
+	store             custody.WithdrawalStore
+	nowFunc           func() time.Time
 }
 
 func New(
 	globalLimits map[common.Address]Limit,
 	userOverrides map[common.Address]map[common.Address]Limit,
 	store custody.WithdrawalStore,
+	opts ...Option,
 ) *Checker {
-	return &Checker{
+	c := &Checker{
 		globalLimits:  globalLimits,
 		userOverrides: userOverrides,
 		store:         store,
 		nowFunc:       time.Now,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
+// Check evaluates a withdrawal against the limit windows containing the
+// current wall-clock time.
 func (c *Checker) Check(user common.Address, token common.Address, amount *big.Int) error {
-	if amount.Sign() <= 0 {
-		return ErrInvalidAmount
-	}
-	if user == (common.Address{}) {
-		return ErrInvalidUser
-	}
+	return c.CheckAt(user, token, amount, c.nowFunc())
+}
 
-	if err := c.checkGlobalLimits(token, amount); err != nil {
-		return err
+// CheckAt evaluates a withdrawal against the limit windows containing at,
+// typically the timestamp of the block that requested it. Withdrawals must be
+// recorded with the same clock (see Record) so that replaying or backfilling
+// requests yields the same decisions as processing them in real time.
+func (c *Checker) CheckAt(user common.Address, token common.Address, amount *big.Int, at time.Time) error {
+	_, err := c.Evaluate(user, token, amount, at)
+	return err
+}
+
+// Evaluation describes the inputs a decision was based on beyond the
+// withdrawal itself.
+type Evaluation struct {
+	// Prices is the snapshot fiat limits were evaluated with, or nil if
+	// they did not apply.
+	Prices *PriceSnapshot
+	// Anomaly is the history of the user anomaly detection compared the
+	// withdrawal to, or nil if it did not run.
+	Anomaly *AnomalyStats
+}
+
+// Evaluate is CheckAt that also reports what the decision was based on, for
+// recording alongside it.
+func (c *Checker) Evaluate(user common.Address, token common.Address, amount *big.Int, at time.Time) (Evaluation, error) {
+	req := &policy.Request{User: user, Token: token, Amount: amount, Time: at}
+	var err error
+	for _, rule := range c.Rules() {
+		if err = rule.Check(req); err != nil {
+			break
+		}
 	}
+	prices, _ := req.Annotation(AnnotationPrices).(*PriceSnapshot)
+	anomaly, _ := req.Annotation(AnnotationAnomalyStats).(*AnomalyStats)
+	return Evaluation{Prices: prices, Anomaly: anomaly}, err
+}
 
-	if err := c.checkUserLimits(user, token, amount); err != nil {
-		return err
+// HasCrossChainLimit reports whether withdrawals of token count towards a
+// cross-chain limit, i.e. whether deciding on them depends on other chains.
+func (c *Checker) HasCrossChainLimit(token common.Address) bool {
+	for _, l := range c.crossChainLimits {
+		if l.Token == token {
+			return true
+		}
 	}
+	return false
+}
+
+func (c *Checker) checkCrossChainLimits(token common.Address, amount *big.Int, now time.Time) error {
+	for _, l := range c.crossChainLimits {
+		if l.Token != token {
+			continue
+		}
+
+		if l.Hourly != nil {
+			total, err := crossChainTotal(l.Tokens, l.since(now, time.Hour), now)
+			if err != nil {
+				return fmt.Errorf("failed to get cross-chain hourly withdrawn amount: %w", err)
+			}
+			newTotal := new(big.Int).Add(total, amount)
+			if newTotal.Cmp(l.Hourly) > 0 {
+				return fmt.Errorf("%w for %s: %s > %s", ErrCrossChainHourlyLimitExceeded, l.Name, newTotal, l.Hourly)
+			}
+		}
+
+		if l.Daily != nil {
+			total, err := crossChainTotal(l.Tokens, l.since(now, 24*time.Hour), now)
+			if err != nil {
+				return fmt.Errorf("failed to get cross-chain daily withdrawn amount: %w", err)
+			}
+			newTotal := new(big.Int).Add(total, amount)
+			if newTotal.Cmp(l.Daily) > 0 {
+				return fmt.Errorf("%w for %s: %s > %s", ErrCrossChainDailyLimitExceeded, l.Name, newTotal, l.Daily)
+			}
+		}
 
+		for _, w := range l.Windows {
+			total, err := crossChainTotal(l.Tokens, l.since(now, w.Period), now)
+			if err != nil {
+				return fmt.Errorf("failed to get cross-chain %s withdrawn amount: %w", w, err)
+			}
+			newTotal := new(big.Int).Add(total, amount)
+			if newTotal.Cmp(w.Max) > 0 {
+				return fmt.Errorf("%w (%s) for %s: %s > %s", ErrCrossChainWindowLimitExceeded, w, l.Name, newTotal, w.Max)
+			}
+		}
+	}
 	return nil
 }
 
-func (c *Checker) checkGlobalLimits(token common.Address, amount *big.Int) error {
+func crossChainTotal(tokens []ChainToken, since, until time.Time) (*big.Int, error) {
+	total := new(big.Int)
+	for _, t := range tokens {
+		withdrawn, err := t.Store.GetTotalWithdrawn(t.Token, since, until)
+		if err != nil {
+			return nil, err
+		}
+		total.Add(total, withdrawn)
+	}
+	return total, nil
+}
+
+func (c *Checker) checkGlobalLimits(user, token common.Address, amount *big.Int, now time.Time) error {
 	l, ok := c.globalLimits[token]
 	if !ok {
+		if c.hasFiatLimit(token) {
+			return nil
+		}
 		return fmt.Errorf("%w: %s", ErrNoLimitsConfigured, token.Hex())
 	}
 
-	now := c.nowFunc()
-
 	if l.Hourly != nil {
-		startOfHour := now.Truncate(time.Hour)
-		total, err := c.store.GetTotalWithdrawn(token, startOfHour)
+		hourStart := l.since(now, time.Hour)
+		total, err := c.store.GetTotalWithdrawn(token, hourStart, now)
 		if err != nil {
 			return fmt.Errorf("failed to get hourly withdrawn amount: %w", err)
 		}
@@ -86,8 +370,8 @@ func (c *Checker) checkGlobalLimits(token common.Address, amount *big.Int) error
 	}
 
 	if l.Daily != nil {
-		startOfDay := now.Truncate(24 * time.Hour)
-		total, err := c.store.GetTotalWithdrawn(token, startOfDay)
+		dayStart := l.since(now, 24*time.Hour)
+		total, err := c.store.GetTotalWithdrawn(token, dayStart, now)
 		if err != nil {
 			return fmt.Errorf("failed to get daily withdrawn amount: %w", err)
 		}
@@ -97,29 +381,40 @@ func (c *Checker) checkGlobalLimits(token common.Address, amount *big.Int) error
 		}
 	}
 
-	return nil
-}
-
-func (c *Checker) resolveUserLimit(user, token common.Address) *Limit {
-	if userTokens, ok := c.userOverrides[user]; ok {
-		if l, ok := userTokens[token]; ok {
-			return &l
+	for _, w := range l.Windows {
+		total, err := c.store.GetTotalWithdrawn(token, l.since(now, w.Period), now)
+		if err != nil {
+			return fmt.Errorf("failed to get %s withdrawn amount: %w", w, err)
+		}
+		newTotal := new(big.Int).Add(total, amount)
+		if newTotal.Cmp(w.Max) > 0 {
+			return fmt.Errorf("%w (%s) for %s: %s > %s", ErrWindowLimitExceeded, w, token.Hex(), newTotal, w.Max)
 		}
 	}
+
+	if err := checkCount(l.Count, l.Window, now, globalCountErrors, "for "+token.Hex(), func(since time.Time) (uint64, error) {
+		return c.store.CountWithdrawals(token, since, now)
+	}); err != nil {
+		return err
+	}
+	if err := checkCount(l.UserCount, l.Window, now, userCountErrors, fmt.Sprintf("for user %s token %s", user.Hex(), token.Hex()), func(since time.Time) (uint64, error) {
+		return c.store.CountWithdrawalsByUser(user, token, since, now)
+	}); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (c *Checker) checkUserLimits(user, token common.Address, amount *big.Int) error {
-	l := c.resolveUserLimit(user, token)
-	if l == nil {
-		return nil
+func (c *Checker) checkUserLimits(user, token common.Address, amount *big.Int, now time.Time) error {
+	l, err := c.resolveUserLimit(user, token)
+	if err != nil || l == nil {
+		return err
 	}
 
-	now := c.nowFunc()
-
 	if l.Hourly != nil {
-		startOfHour := now.Truncate(time.Hour)
-		total, err := c.store.GetTotalWithdrawnByUser(user, token, startOfHour)
+		hourStart := l.since(now, time.Hour)
+		total, err := c.store.GetTotalWithdrawnByUser(user, token, hourStart, now)
 		if err != nil {
 			return fmt.Errorf("failed to get per-user hourly withdrawn amount: %w", err)
 		}
@@ -131,8 +426,8 @@ func (c *Checker) checkUserLimits(user, token common.Address, amount *big.Int) e
 	}
 
 	if l.Daily != nil {
-		startOfDay := now.Truncate(24 * time.Hour)
-		total, err := c.store.GetTotalWithdrawnByUser(user, token, startOfDay)
+		dayStart := l.since(now, 24*time.Hour)
+		total, err := c.store.GetTotalWithdrawnByUser(user, token, dayStart, now)
 		if err != nil {
 			return fmt.Errorf("failed to get per-user daily withdrawn amount: %w", err)
 		}
@@ -143,9 +438,71 @@ func (c *Checker) checkUserLimits(user, token common.Address, amount *big.Int) e
 		}
 	}
 
+	for _, w := range l.Windows {
+		total, err := c.store.GetTotalWithdrawnByUser(user, token, l.since(now, w.Period), now)
+		if err != nil {
+			return fmt.Errorf("failed to get per-user %s withdrawn amount: %w", w, err)
+		}
+		newTotal := new(big.Int).Add(total, amount)
+		if newTotal.Cmp(w.Max) > 0 {
+			return fmt.Errorf("%w (%s) for user %s token %s: %s > %s",
+				ErrUserWindowLimitExceeded, w, user.Hex(), token.Hex(), newTotal, w.Max)
+		}
+	}
+
+	if err := checkCount(l.Count, l.Window, now, userCountErrors, fmt.Sprintf("for user %s token %s", user.Hex(), token.Hex()), func(since time.Time) (uint64, error) {
+		return c.store.CountWithdrawalsByUser(user, token, since, now)
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// countErrors are the errors reported when a withdrawal count cap is exceeded.
+type countErrors struct {
+	hourly, daily, window error
+}
+
+var (
+	globalCountErrors = countErrors{ErrHourlyCountExceeded, ErrDailyCountExceeded, ErrWindowCountExceeded}
+	userCountErrors   = countErrors{ErrUserHourlyCountExceeded, ErrUserDailyCountExceeded, ErrUserWindowCountExceeded}
+)
+
+// checkCount fails if one more withdrawal would exceed a cap of cl. count
+// returns the number of withdrawals recorded from the given time up to now, and scope
+// describes what is counted in error messages.
+func checkCount(cl CountLimit, window Window, now time.Time, errs countErrors, scope string, count func(since time.Time) (uint64, error)) error {
+	type countCap struct {
+		period time.Duration
+		max    uint64
+		err    error
+	}
+	var caps []countCap
+	if cl.Hourly > 0 {
+		caps = append(caps, countCap{time.Hour, cl.Hourly, errs.hourly})
+	}
+	if cl.Daily > 0 {
+		caps = append(caps, countCap{24 * time.Hour, cl.Daily, errs.daily})
+	}
+	for _, w := range cl.Windows {
+		caps = append(caps, countCap{w.Period, w.Max, fmt.Errorf("%w (%s)", errs.window, formatPeriod(w.Period))})
+	}
+
+	for _, cc := range caps {
+		n, err := count(window.since(now, cc.period))
+		if err != nil {
+			return fmt.Errorf("failed to count withdrawals %s: %w", scope, err)
+		}
+		if n+1 > cc.max {
+			return fmt.Errorf("%w %s: %d > %d", cc.err, scope, n+1, cc.max)
+		}
+	}
 	return nil
 }
 
+// Record stores a withdrawal for limit accounting. Its Timestamp places it in
+// the limit windows and should use the same clock as CheckAt.
 func (c *Checker) Record(w *custody.Withdrawal) error {
 	return c.store.Save(w)
 }
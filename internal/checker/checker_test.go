@@ -22,32 +22,71 @@ func (m *mockStore) Save(w *custody.Withdrawal) error {
 	return nil
 }
 
-func (m *mockStore) GetTotalWithdrawn(token common.Address, since time.Time) (*big.Int, error) {
+func (m *mockStore) GetTotalWithdrawn(token common.Address, since, until time.Time) (*big.Int, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	total := new(big.Int)
 	for _, w := range m.withdrawals {
-		if w.Token == token && !w.Timestamp.Before(since) {
+		if w.Token == token && !w.Timestamp.Before(since) && !w.Timestamp.After(until) {
 			total.Add(total, w.Amount)
 		}
 	}
 	return total, nil
 }
 
-func (m *mockStore) GetTotalWithdrawnByUser(user common.Address, token common.Address, since time.Time) (*big.Int, error) {
+func (m *mockStore) GetTotalWithdrawnByUser(user common.Address, token common.Address, since, until time.Time) (*big.Int, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	total := new(big.Int)
 	for _, w := range m.withdrawals {
-		if w.User == user && w.Token == token && !w.Timestamp.Before(since) {
+		if w.User == user && w.Token == token && !w.Timestamp.Before(since) && !w.Timestamp.After(until) {
 			total.Add(total, w.Amount)
 		}
 	}
 	return total, nil
 }
 
+func (m *mockStore) GetWithdrawalsByUser(user common.Address, since time.Time) ([]*custody.Withdrawal, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var result []*custody.Withdrawal
+	for _, w := range m.withdrawals {
+		if w.User == user && !w.Timestamp.Before(since) {
+			result = append(result, w)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockStore) CountWithdrawals(token common.Address, since, until time.Time) (uint64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	var n uint64
+	for _, w := range m.withdrawals {
+		if w.Token == token && !w.Timestamp.Before(since) && !w.Timestamp.After(until) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockStore) CountWithdrawalsByUser(user common.Address, token common.Address, since, until time.Time) (uint64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	var n uint64
+	for _, w := range m.withdrawals {
+		if w.User == user && w.Token == token && !w.Timestamp.Before(since) && !w.Timestamp.After(until) {
+			n++
+		}
+	}
+	return n, nil
+}
+
 var (
 	tokenA = common.HexToAddress("0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
 	tokenB = common.HexToAddress("0xBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB")
@@ -279,3 +318,220 @@ func TestCheck_NoPerUserLimitsConfigured(t *testing.T) {
 
 	require.NoError(t, c.Check(userA, tokenA, big.NewInt(500)))
 }
+
+func TestCheckAt_UsesGivenTime(t *testing.T) {
+	blockTime := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	store := &mockStore{
+		withdrawals: []*custody.Withdrawal{
+			{Token: tokenA, User: userA, Amount: big.NewInt(800), Timestamp: blockTime.Add(-10 * time.Minute)},
+		},
+	}
+	c := New(globalLimits(tokenA, big.NewInt(1000), nil), nil, store)
+	// Wall-clock time is hours later; the decision must only depend on the block time.
+	c.nowFunc = func() time.Time { return blockTime.Add(5 * time.Hour) }
+
+	require.NoError(t, c.Check(userA, tokenA, big.NewInt(300)))
+
+	err := c.CheckAt(userA, tokenA, big.NewInt(300), blockTime)
+	require.ErrorIs(t, err, ErrHourlyLimitExceeded)
+
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(300), blockTime.Add(time.Hour)))
+}
+
+func TestCheckAt_RollingWindowSpansBoundary(t *testing.T) {
+	store := &mockStore{}
+	limits := map[common.Address]Limit{
+		tokenA: {Hourly: big.NewInt(1000), Daily: big.NewInt(5000), Window: WindowRolling},
+	}
+	c := New(limits, nil, store)
+
+	at := time.Date(2025, 1, 1, 10, 59, 0, 0, time.UTC)
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(1000), at))
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(1000), Timestamp: at}))
+
+	// A calendar window would reset at 11:00.
+	err := c.CheckAt(userA, tokenA, big.NewInt(1), at.Add(time.Minute))
+	require.ErrorIs(t, err, ErrHourlyLimitExceeded)
+
+	// An hour after the withdrawal, it no longer counts.
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(1000), at.Add(time.Hour+time.Second)))
+}
+
+func TestCheckAt_RollingWindowIgnoresLaterWithdrawals(t *testing.T) {
+	store := &mockStore{}
+	limits := map[common.Address]Limit{
+		tokenA: {Hourly: big.NewInt(1000), Window: WindowRolling},
+	}
+	c := New(limits, nil, store)
+
+	at := time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC)
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(1000), Timestamp: at.Add(time.Minute)}))
+
+	// Replaying a withdrawal made before the recorded one gives the decision
+	// it got at the time.
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(1000), at))
+
+	err := c.CheckAt(userA, tokenA, big.NewInt(1), at.Add(time.Minute))
+	require.ErrorIs(t, err, ErrHourlyLimitExceeded)
+}
+
+func TestCheckAt_CalendarWindowResetsAtBoundary(t *testing.T) {
+	store := &mockStore{}
+	c := New(globalLimits(tokenA, big.NewInt(1000), big.NewInt(5000)), nil, store)
+
+	at := time.Date(2025, 1, 1, 10, 59, 0, 0, time.UTC)
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(1000), Timestamp: at}))
+
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(1000), at.Add(time.Minute)))
+}
+
+func TestCheckAt_RollingUserDailyLimit(t *testing.T) {
+	store := &mockStore{}
+	overrides := map[common.Address]map[common.Address]Limit{
+		userA: {tokenA: {Daily: big.NewInt(500), Window: WindowRolling}},
+	}
+	c := New(globalLimits(tokenA, nil, nil), overrides, store)
+
+	at := time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(500), Timestamp: at}))
+
+	err := c.CheckAt(userA, tokenA, big.NewInt(1), at.Add(2*time.Hour))
+	require.ErrorIs(t, err, ErrUserDailyLimitExceeded)
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(500), at.Add(25*time.Hour)))
+}
+
+func TestCheckAt_WindowLimits(t *testing.T) {
+	store := &mockStore{}
+	limits := map[common.Address]Limit{
+		tokenA: {
+			Daily: big.NewInt(1000),
+			Windows: []WindowLimit{
+				{Period: 10 * time.Minute, Max: big.NewInt(300)},
+				{Period: 7 * 24 * time.Hour, Max: big.NewInt(2000)},
+			},
+			Window: WindowRolling,
+		},
+	}
+	c := New(limits, nil, store)
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(300), Timestamp: at}))
+	err := c.CheckAt(userA, tokenA, big.NewInt(1), at.Add(5*time.Minute))
+	require.ErrorIs(t, err, ErrWindowLimitExceeded)
+	require.Contains(t, err.Error(), "(10m)")
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(300), at.Add(11*time.Minute)))
+
+	// 1900 over the last days stays under the daily cap each day but not the
+	// weekly one.
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(400), Timestamp: at.Add(time.Duration(i) * 24 * time.Hour)}))
+	}
+	err = c.CheckAt(userA, tokenA, big.NewInt(200), at.Add(5*24*time.Hour))
+	require.ErrorIs(t, err, ErrWindowLimitExceeded)
+	require.Contains(t, err.Error(), "(1w)")
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(200), at.Add(8*24*time.Hour)))
+}
+
+func TestCheckAt_UserWindowLimit(t *testing.T) {
+	store := &mockStore{}
+	overrides := map[common.Address]map[common.Address]Limit{
+		userA: {tokenA: {Windows: []WindowLimit{{Period: 30 * 24 * time.Hour, Max: big.NewInt(500)}}, Window: WindowRolling}},
+	}
+	c := New(globalLimits(tokenA, nil, nil), overrides, store)
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(500), Timestamp: at}))
+	err := c.CheckAt(userA, tokenA, big.NewInt(1), at.Add(20*24*time.Hour))
+	require.ErrorIs(t, err, ErrUserWindowLimitExceeded)
+	require.NoError(t, c.CheckAt(userB, tokenA, big.NewInt(1), at.Add(20*24*time.Hour)))
+}
+
+func TestCheckAt_UserCountLimit(t *testing.T) {
+	store := &mockStore{}
+	limits := map[common.Address]Limit{
+		tokenA: {UserCount: CountLimit{Hourly: 2}, Window: WindowRolling},
+	}
+	c := New(limits, nil, store)
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := range 2 {
+		require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(1), at))
+		require.NoError(t, c.Record(&custody.Withdrawal{WithdrawalID: [32]byte{byte(i)}, User: userA, Token: tokenA, Amount: big.NewInt(1), Timestamp: at}))
+	}
+
+	err := c.CheckAt(userA, tokenA, big.NewInt(1), at.Add(time.Minute))
+	require.ErrorIs(t, err, ErrUserHourlyCountExceeded)
+	require.NoError(t, c.CheckAt(userB, tokenA, big.NewInt(1), at.Add(time.Minute)))
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(1), at.Add(time.Hour+time.Second)))
+}
+
+func TestCheckAt_TokenCountLimit(t *testing.T) {
+	store := &mockStore{}
+	limits := map[common.Address]Limit{
+		tokenA: {Count: CountLimit{Daily: 2, Windows: []WindowCount{{Period: 10 * time.Minute, Max: 1}}}},
+	}
+	c := New(limits, nil, store)
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(1), Timestamp: at}))
+	err := c.CheckAt(userB, tokenA, big.NewInt(1), at.Add(time.Minute))
+	require.ErrorIs(t, err, ErrWindowCountExceeded)
+
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userB, Token: tokenA, Amount: big.NewInt(1), Timestamp: at.Add(time.Hour)}))
+	err = c.CheckAt(userB, tokenA, big.NewInt(1), at.Add(2*time.Hour))
+	require.ErrorIs(t, err, ErrDailyCountExceeded)
+}
+
+func TestCheckAt_PerUserOverrideCountLimit(t *testing.T) {
+	store := &mockStore{}
+	overrides := map[common.Address]map[common.Address]Limit{
+		userA: {tokenA: {Count: CountLimit{Daily: 1}}},
+	}
+	c := New(globalLimits(tokenA, nil, nil), overrides, store)
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(1), Timestamp: at}))
+	err := c.CheckAt(userA, tokenA, big.NewInt(1), at.Add(time.Hour))
+	require.ErrorIs(t, err, ErrUserDailyCountExceeded)
+	require.NoError(t, c.CheckAt(userB, tokenA, big.NewInt(1), at.Add(time.Hour)))
+}
+
+func TestCheck_CrossChainLimit(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	// The same asset is tokenA on this chain and tokenB on the other one.
+	local := &mockStore{
+		withdrawals: []*custody.Withdrawal{
+			{Token: tokenA, User: userA, Amount: big.NewInt(300), Timestamp: now.Add(-10 * time.Minute)},
+		},
+	}
+	remote := &mockStore{
+		withdrawals: []*custody.Withdrawal{
+			{Token: tokenB, User: userB, Amount: big.NewInt(500), Timestamp: now.Add(-20 * time.Minute)},
+			{Token: tokenB, User: userB, Amount: big.NewInt(900), Timestamp: now.Add(-2 * time.Hour)},
+		},
+	}
+	limit := CrossChainLimit{
+		Name:   "usdc",
+		Token:  tokenA,
+		Tokens: []ChainToken{{Token: tokenA, Store: local}, {Token: tokenB, Store: remote}},
+		Limit:  Limit{Hourly: big.NewInt(1000), Daily: big.NewInt(2000)},
+	}
+	c := New(globalLimits(tokenA, big.NewInt(10000), big.NewInt(10000)), nil, local, WithCrossChainLimits(limit))
+
+	require.True(t, c.HasCrossChainLimit(tokenA))
+	require.False(t, c.HasCrossChainLimit(tokenB))
+
+	// 300 + 500 withdrawn this hour across both chains.
+	require.NoError(t, c.CheckAt(userA, tokenA, big.NewInt(200), now))
+	err := c.CheckAt(userA, tokenA, big.NewInt(201), now)
+	require.ErrorIs(t, err, ErrCrossChainHourlyLimitExceeded)
+
+	// 1700 withdrawn today across both chains.
+	err = c.CheckAt(userA, tokenA, big.NewInt(301), now.Add(time.Hour))
+	require.ErrorIs(t, err, ErrCrossChainDailyLimitExceeded)
+
+	remote.err = errors.New("db down")
+	err = c.CheckAt(userA, tokenA, big.NewInt(1), now)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrCrossChainHourlyLimitExceeded)
+}
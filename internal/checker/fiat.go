@@ -0,0 +1,179 @@
+package checker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrFiatHourlyLimitExceeded     = errors.New("fiat hourly limit exceeded")
+	ErrFiatDailyLimitExceeded      = errors.New("fiat daily limit exceeded")
+	ErrUserFiatHourlyLimitExceeded = errors.New("per-user fiat hourly limit exceeded")
+	ErrUserFiatDailyLimitExceeded  = errors.New("per-user fiat daily limit exceeded")
+	ErrNoPrice                     = errors.New("no price for token")
+	ErrPricesUnavailable           = errors.New("token prices unavailable")
+)
+
+// PriceSnapshot is a set of token prices in a quote currency. Each price is
+// the value of one whole token, i.e. 10^decimals base units.
+type PriceSnapshot struct {
+	Currency string
+	// Time is when the prices were published or, if the source does not
+	// say, fetched.
+	Time   time.Time
+	Prices map[common.Address]*big.Rat
+}
+
+// PriceSource provides the token prices fiat limits are evaluated with.
+type PriceSource interface {
+	// Prices returns the current prices of the given tokens. It fails rather
+	// than return stale prices or leave some of the tokens out.
+	Prices(tokens []common.Address) (*PriceSnapshot, error)
+}
+
+// FiatLimits caps the combined value of the withdrawals of Tokens, converted
+// to Currency with the prices of a PriceSource. Totals are valued at the
+// prices current when a withdrawal is checked.
+type FiatLimits struct {
+	Currency string
+	// Tokens maps the tokens counted towards the limits to their decimals.
+	Tokens map[common.Address]uint8
+	// FiatLimit caps the value withdrawn by all users together.
+	FiatLimit
+	// PerUser caps the value withdrawn by each user.
+	PerUser FiatLimit
+	Window  Window
+}
+
+// FiatLimit caps the value withdrawn per hour and day. Nil means no cap.
+type FiatLimit struct {
+	Hourly *big.Rat
+	Daily  *big.Rat
+}
+
+// WithFiatLimits makes the Checker enforce limits valued in a quote currency,
+// with prices from source.
+func WithFiatLimits(limits FiatLimits, source PriceSource) Option {
+	return func(c *Checker) {
+		c.fiatLimits = &limits
+		c.prices = source
+	}
+}
+
+func (c *Checker) hasFiatLimit(token common.Address) bool {
+	if c.fiatLimits == nil {
+		return false
+	}
+	_, ok := c.fiatLimits.Tokens[token]
+	return ok
+}
+
+// fiatPrices returns the prices a withdrawal of token is evaluated with if it
+// counts towards the fiat limits. Errors of the price source wrap
+// ErrPricesUnavailable.
+func (c *Checker) fiatPrices(token common.Address) (*PriceSnapshot, error) {
+	if !c.hasFiatLimit(token) {
+		return nil, nil
+	}
+	prices, err := c.prices.Prices(c.fiatLimits.tokens())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPricesUnavailable, err)
+	}
+	return prices, nil
+}
+
+// checkFiatLimits evaluates a withdrawal of a token counted towards the fiat
+// limits with the prices returned by fiatPrices. Without prices the
+// withdrawal is not checked; the prices rule reports why.
+func (c *Checker) checkFiatLimits(user, token common.Address, amount *big.Int, now time.Time, prices *PriceSnapshot) error {
+	if !c.hasFiatLimit(token) || prices == nil {
+		return nil
+	}
+	fl := c.fiatLimits
+	if prices.Currency != "" && !strings.EqualFold(prices.Currency, fl.Currency) {
+		return fmt.Errorf("price source quotes %s, fiat limits are in %s", prices.Currency, fl.Currency)
+	}
+
+	value, err := fl.value(prices, token, amount)
+	if err != nil {
+		return err
+	}
+
+	tokens := fl.tokens()
+	windows := []struct {
+		max    *big.Rat
+		length time.Duration
+		err    error
+		user   bool
+	}{
+		{fl.Hourly, time.Hour, ErrFiatHourlyLimitExceeded, false},
+		{fl.Daily, 24 * time.Hour, ErrFiatDailyLimitExceeded, false},
+		{fl.PerUser.Hourly, time.Hour, ErrUserFiatHourlyLimitExceeded, true},
+		{fl.PerUser.Daily, 24 * time.Hour, ErrUserFiatDailyLimitExceeded, true},
+	}
+	for _, w := range windows {
+		if w.max == nil {
+			continue
+		}
+		since := fl.Window.since(now, w.length)
+
+		total := new(big.Rat).Set(value)
+		for _, t := range tokens {
+			var withdrawn *big.Int
+			if w.user {
+				withdrawn, err = c.store.GetTotalWithdrawnByUser(user, t, since, now)
+			} else {
+				withdrawn, err = c.store.GetTotalWithdrawn(t, since, now)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get withdrawn amount of %s: %w", t.Hex(), err)
+			}
+			if withdrawn.Sign() == 0 {
+				continue
+			}
+			v, err := fl.value(prices, t, withdrawn)
+			if err != nil {
+				return err
+			}
+			total.Add(total, v)
+		}
+
+		if total.Cmp(w.max) > 0 {
+			scope := "all users"
+			if w.user {
+				scope = "user " + user.Hex()
+			}
+			return fmt.Errorf("%w for %s: %s > %s %s",
+				w.err, scope, total.FloatString(2), w.max.FloatString(2), fl.Currency)
+		}
+	}
+	return nil
+}
+
+// tokens returns the tokens counted towards the limits in a stable order.
+func (fl *FiatLimits) tokens() []common.Address {
+	tokens := make([]common.Address, 0, len(fl.Tokens))
+	for t := range fl.Tokens {
+		tokens = append(tokens, t)
+	}
+	slices.SortFunc(tokens, func(a, b common.Address) int { return bytes.Compare(a[:], b[:]) })
+	return tokens
+}
+
+// value converts an amount of token in base units to the quote currency.
+func (fl *FiatLimits) value(prices *PriceSnapshot, token common.Address, amount *big.Int) (*big.Rat, error) {
+	price, ok := prices.Prices[token]
+	if !ok || price == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoPrice, token.Hex())
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(fl.Tokens[token])), nil)
+	v := new(big.Rat).SetFrac(amount, unit)
+	return v.Mul(v, price), nil
+}
@@ -0,0 +1,125 @@
+package checker
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/layer-3/nitewatch/custody"
+	"github.com/layer-3/nitewatch/policy"
+)
+
+type staticPrices struct {
+	snapshot *PriceSnapshot
+	err      error
+}
+
+func (s *staticPrices) Prices(tokens []common.Address) (*PriceSnapshot, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.snapshot, nil
+}
+
+func rat(s string) *big.Rat {
+	r, _ := new(big.Rat).SetString(s)
+	return r
+}
+
+func fiatChecker(store *mockStore, prices PriceSource, limits FiatLimits) *Checker {
+	limits.Currency = "USD"
+	limits.Tokens = map[common.Address]uint8{tokenA: 18, tokenB: 6}
+	return New(nil, nil, store, WithFiatLimits(limits, prices))
+}
+
+func TestEvaluate_FiatLimitAcrossTokens(t *testing.T) {
+	store := &mockStore{}
+	prices := &staticPrices{snapshot: &PriceSnapshot{
+		Currency: "USD",
+		Prices:   map[common.Address]*big.Rat{tokenA: rat("2000"), tokenB: rat("1")},
+	}}
+	c := fiatChecker(store, prices, FiatLimits{FiatLimit: FiatLimit{Hourly: rat("2500")}})
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// 1 token A is worth 2000 USD.
+	eval, err := c.Evaluate(userA, tokenA, big.NewInt(1e18), at)
+	require.NoError(t, err)
+	require.Same(t, prices.snapshot, eval.Prices)
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenA, Amount: big.NewInt(1e18), Timestamp: at}))
+
+	// 500 token B are worth 500 USD, which reaches but does not exceed the limit.
+	require.NoError(t, c.CheckAt(userB, tokenB, big.NewInt(500e6), at))
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userB, Token: tokenB, Amount: big.NewInt(500e6), Timestamp: at}))
+
+	eval, err = c.Evaluate(userB, tokenB, big.NewInt(1), at)
+	require.ErrorIs(t, err, ErrFiatHourlyLimitExceeded)
+	require.NotNil(t, eval.Prices, "prices should be reported for rejected withdrawals")
+
+	require.NoError(t, c.CheckAt(userB, tokenB, big.NewInt(1), at.Add(time.Hour)))
+}
+
+func TestEvaluate_FiatPerUserLimit(t *testing.T) {
+	store := &mockStore{}
+	prices := &staticPrices{snapshot: &PriceSnapshot{
+		Prices: map[common.Address]*big.Rat{tokenA: rat("2000"), tokenB: rat("1")},
+	}}
+	c := fiatChecker(store, prices, FiatLimits{PerUser: FiatLimit{Daily: rat("1000")}})
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, c.Record(&custody.Withdrawal{User: userA, Token: tokenB, Amount: big.NewInt(900e6), Timestamp: at}))
+
+	// 0.1 token A is worth 200 USD.
+	err := c.CheckAt(userA, tokenA, big.NewInt(1e17), at)
+	require.ErrorIs(t, err, ErrUserFiatDailyLimitExceeded)
+	require.NoError(t, c.CheckAt(userB, tokenA, big.NewInt(1e17), at))
+}
+
+func TestEvaluate_FiatPriceErrors(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("source error", func(t *testing.T) {
+		sourceErr := errors.New("unavailable")
+		c := fiatChecker(&mockStore{}, &staticPrices{err: sourceErr}, FiatLimits{FiatLimit: FiatLimit{Hourly: rat("1")}})
+		err := c.CheckAt(userA, tokenA, big.NewInt(1), at)
+		require.ErrorIs(t, err, sourceErr)
+		require.Equal(t, ReasonPriceUnavailable, ReasonCode(err))
+
+		// Only the prices rule fails, so that the outage can be handled
+		// apart from exceeded limits.
+		req := &policy.Request{User: userA, Token: tokenA, Amount: big.NewInt(1), Time: at}
+		for _, rule := range c.Rules() {
+			if rule.Name() == RulePrices {
+				require.ErrorIs(t, rule.Check(req), ErrPricesUnavailable)
+			} else {
+				require.NoError(t, rule.Check(req), rule.Name())
+			}
+		}
+	})
+
+	t.Run("missing price", func(t *testing.T) {
+		prices := &staticPrices{snapshot: &PriceSnapshot{Prices: map[common.Address]*big.Rat{tokenB: rat("1")}}}
+		c := fiatChecker(&mockStore{}, prices, FiatLimits{FiatLimit: FiatLimit{Hourly: rat("1")}})
+		require.ErrorIs(t, c.CheckAt(userA, tokenA, big.NewInt(1), at), ErrNoPrice)
+	})
+
+	t.Run("currency mismatch", func(t *testing.T) {
+		prices := &staticPrices{snapshot: &PriceSnapshot{
+			Currency: "EUR",
+			Prices:   map[common.Address]*big.Rat{tokenA: rat("1"), tokenB: rat("1")},
+		}}
+		c := fiatChecker(&mockStore{}, prices, FiatLimits{FiatLimit: FiatLimit{Hourly: rat("1000")}})
+		require.Error(t, c.CheckAt(userA, tokenA, big.NewInt(1), at))
+	})
+}
+
+func TestCheck_TokenWithoutLimits(t *testing.T) {
+	prices := &staticPrices{snapshot: &PriceSnapshot{Prices: map[common.Address]*big.Rat{tokenA: rat("1"), tokenB: rat("1")}}}
+	c := New(nil, nil, &mockStore{}, WithFiatLimits(FiatLimits{Tokens: map[common.Address]uint8{tokenA: 0}}, prices))
+
+	require.NoError(t, c.Check(userA, tokenA, big.NewInt(1)))
+	require.ErrorIs(t, c.Check(userA, tokenB, big.NewInt(1)), ErrNoLimitsConfigured)
+}
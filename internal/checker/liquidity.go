@@ -0,0 +1,84 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrInsufficientLiquidity = errors.New("insufficient liquidity")
+	ErrReserveFloor          = errors.New("withdrawal would breach reserve floor")
+	ErrLiquidityUnavailable  = errors.New("custody liquidity unavailable")
+)
+
+// LiquiditySource reports the funds a custody contract has to pay withdrawals
+// with.
+type LiquiditySource interface {
+	// Balance returns the amount of token held by contract.
+	Balance(contract, token common.Address) (*big.Int, error)
+	// Committed returns the amount of token that withdrawals from contract
+	// approved but not yet paid out, other than withdrawalID, will take from
+	// its balance, as of the chain time at.
+	Committed(contract, token common.Address, withdrawalID [32]byte, at time.Time) (*big.Int, error)
+}
+
+// Liquidity is what the liquidity rule compared a withdrawal to.
+type Liquidity struct {
+	Balance   *big.Int
+	Committed *big.Int
+	Reserve   *big.Int
+	// Shortfall is the amount the contract has to be topped up by to pay
+	// the withdrawal and keep its reserve, zero if none.
+	Shortfall *big.Int
+}
+
+// WithLiquidity makes the Checker verify that the custody contract can pay a
+// withdrawal on top of the withdrawals already approved, and keep the reserve
+// of the token afterwards. Tokens without a reserve need none.
+func WithLiquidity(reserves map[common.Address]*big.Int, source LiquiditySource) Option {
+	return func(c *Checker) {
+		c.reserves = reserves
+		c.liquidity = source
+	}
+}
+
+// CheckLiquidity compares a withdrawal from contract to the contract's
+// balance at the chain time at, as the liquidity rule does for the time of the
+// request. It returns what it compared, also when the withdrawal cannot be paid. Errors reading the liquidity of the contract
+// wrap ErrLiquidityUnavailable. Withdrawals that do not name their contract are
+// not checked.
+func (c *Checker) CheckLiquidity(contract common.Address, withdrawalID [32]byte, token common.Address, amount *big.Int, at time.Time) (*Liquidity, error) {
+	if c.liquidity == nil || contract == (common.Address{}) {
+		return nil, nil
+	}
+	balance, err := c.liquidity.Balance(contract, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get balance of %s: %w", ErrLiquidityUnavailable, contract.Hex(), err)
+	}
+	committed, err := c.liquidity.Committed(contract, token, withdrawalID, at)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get committed withdrawals of %s: %w", ErrLiquidityUnavailable, contract.Hex(), err)
+	}
+	reserve := c.reserves[token]
+	if reserve == nil {
+		reserve = new(big.Int)
+	}
+
+	l := &Liquidity{Balance: balance, Committed: committed, Reserve: reserve, Shortfall: new(big.Int)}
+	payable := new(big.Int).Add(committed, amount)
+	required := new(big.Int).Add(payable, reserve)
+	if balance.Cmp(required) >= 0 {
+		return l, nil
+	}
+	l.Shortfall.Sub(required, balance)
+	if balance.Cmp(payable) < 0 {
+		return l, fmt.Errorf("%w for %s: balance %s < %s committed + %s requested",
+			ErrInsufficientLiquidity, token.Hex(), balance, committed, amount)
+	}
+	return l, fmt.Errorf("%w for %s: balance %s - %s committed - %s requested < reserve %s",
+		ErrReserveFloor, token.Hex(), balance, committed, amount, reserve)
+}
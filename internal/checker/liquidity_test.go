@@ -0,0 +1,103 @@
+package checker
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/layer-3/nitewatch/policy"
+)
+
+var custodyA = common.HexToAddress("0xcccccccccccccccccccccccccccccccccccccccc")
+
+type mockLiquidity struct {
+	balance   *big.Int
+	committed *big.Int
+	excluded  [32]byte
+	at        time.Time
+	err       error
+}
+
+func (m *mockLiquidity) Balance(common.Address, common.Address) (*big.Int, error) {
+	return m.balance, m.err
+}
+
+func (m *mockLiquidity) Committed(_, _ common.Address, withdrawalID [32]byte, at time.Time) (*big.Int, error) {
+	m.excluded = withdrawalID
+	m.at = at
+	return m.committed, nil
+}
+
+// checkRule applies the rule of c named name to a withdrawal of amount of
+// token from custodyA.
+func checkRule(t *testing.T, c *Checker, name string, token common.Address, amount int64) (*policy.Request, error) {
+	t.Helper()
+	req := &policy.Request{Contract: custodyA, WithdrawalID: [32]byte{1}, User: userA, Token: token, Amount: big.NewInt(amount)}
+	for _, rule := range c.Rules() {
+		if rule.Name() == name {
+			return req, rule.Check(req)
+		}
+	}
+	t.Fatalf("no rule %s", name)
+	return nil, nil
+}
+
+func TestLiquidity(t *testing.T) {
+	source := &mockLiquidity{balance: big.NewInt(1000), committed: big.NewInt(300)}
+	c := New(globalLimits(tokenA, nil, big.NewInt(1e6)), nil, &mockStore{},
+		WithLiquidity(map[common.Address]*big.Int{tokenA: big.NewInt(200)}, source))
+
+	req, err := checkRule(t, c, RuleLiquidity, tokenA, 500)
+	require.NoError(t, err)
+	require.Equal(t, [32]byte{1}, source.excluded, "the withdrawal checked is not committed")
+	l := req.Annotation(AnnotationLiquidity).(*Liquidity)
+	require.Equal(t, "0", l.Shortfall.String())
+
+	req, err = checkRule(t, c, RuleLiquidity, tokenA, 600)
+	require.ErrorIs(t, err, ErrReserveFloor)
+	require.Equal(t, ReasonReserveFloor, ReasonCode(err))
+	require.Equal(t, "100", req.Annotation(AnnotationLiquidity).(*Liquidity).Shortfall.String())
+
+	req, err = checkRule(t, c, RuleLiquidity, tokenA, 800)
+	require.ErrorIs(t, err, ErrInsufficientLiquidity)
+	require.Equal(t, ReasonInsufficientLiquidity, ReasonCode(err))
+	require.Equal(t, "300", req.Annotation(AnnotationLiquidity).(*Liquidity).Shortfall.String())
+
+	// tokenB has no reserve.
+	_, err = checkRule(t, c, RuleLiquidity, tokenB, 700)
+	require.NoError(t, err)
+}
+
+func TestLiquidity_SourceError(t *testing.T) {
+	c := New(globalLimits(tokenA, nil, big.NewInt(1e6)), nil, &mockStore{},
+		WithLiquidity(nil, &mockLiquidity{err: errors.New("rpc down")}))
+
+	req, err := checkRule(t, c, RuleLiquidity, tokenA, 1)
+	require.ErrorContains(t, err, "failed to get balance")
+	require.ErrorIs(t, err, ErrLiquidityUnavailable)
+	require.Equal(t, ReasonLiquidityUnavailable, ReasonCode(err))
+	require.Nil(t, req.Annotation(AnnotationLiquidity))
+
+	// CheckAt does not know the contract and skips the rule.
+	require.NoError(t, c.Check(userA, tokenA, big.NewInt(1)))
+}
+
+func TestLiquidity_ChainTime(t *testing.T) {
+	source := &mockLiquidity{balance: big.NewInt(1000), committed: big.NewInt(0)}
+	c := New(globalLimits(tokenA, nil, big.NewInt(1e6)), nil, &mockStore{}, WithLiquidity(nil, source))
+
+	// Committed withdrawals expire relative to the chain time of the
+	// request, not to the wall clock.
+	requested := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	req := &policy.Request{Contract: custodyA, WithdrawalID: [32]byte{1}, User: userA, Token: tokenA, Amount: big.NewInt(1), Time: requested}
+	for _, rule := range c.Rules() {
+		if rule.Name() == RuleLiquidity {
+			require.NoError(t, rule.Check(req))
+		}
+	}
+	require.Equal(t, requested, source.at)
+}
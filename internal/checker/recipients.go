@@ -0,0 +1,44 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrRecipientDenied     = errors.New("recipient is on the denylist")
+	ErrRecipientNotAllowed = errors.New("recipient is not on the allowlist for token")
+)
+
+// AddressSet is a set of addresses, e.g. an *addrlist.List.
+type AddressSet interface {
+	Contains(addr common.Address) bool
+}
+
+// RecipientLists restricts who withdrawals may be sent to. Withdrawals to an
+// address on Denylist are rejected. Withdrawals of a token with an entry in
+// Allowlists are rejected unless the recipient is on it.
+type RecipientLists struct {
+	Denylist   AddressSet
+	Allowlists map[common.Address]AddressSet
+}
+
+// WithRecipientLists makes the Checker enforce the given recipient lists
+// before any limit.
+func WithRecipientLists(lists RecipientLists) Option {
+	return func(c *Checker) {
+		c.recipients = lists
+	}
+}
+
+func (c *Checker) checkRecipient(user, token common.Address) error {
+	if c.recipients.Denylist != nil && c.recipients.Denylist.Contains(user) {
+		return fmt.Errorf("%w: %s", ErrRecipientDenied, user.Hex())
+	}
+	if allowlist, ok := c.recipients.Allowlists[token]; ok && !allowlist.Contains(user) {
+		return fmt.Errorf("%w %s: %s", ErrRecipientNotAllowed, token.Hex(), user.Hex())
+	}
+	return nil
+}
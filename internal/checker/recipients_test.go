@@ -0,0 +1,58 @@
+package checker
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+type addressSet map[common.Address]bool
+
+func (s addressSet) Contains(addr common.Address) bool { return s[addr] }
+
+func TestCheck_RecipientDenylist(t *testing.T) {
+	c := New(globalLimits(tokenA, big.NewInt(1000), nil), nil, &mockStore{},
+		WithRecipientLists(RecipientLists{Denylist: addressSet{userA: true}}))
+
+	err := c.Check(userA, tokenA, big.NewInt(1))
+	require.ErrorIs(t, err, ErrRecipientDenied)
+	require.Equal(t, ReasonRecipientDenied, ReasonCode(err))
+	require.NoError(t, c.Check(userB, tokenA, big.NewInt(1)))
+}
+
+func TestCheck_RecipientAllowlist(t *testing.T) {
+	limits := map[common.Address]Limit{
+		tokenA: {Hourly: big.NewInt(1000)},
+		tokenB: {Hourly: big.NewInt(1000)},
+	}
+	c := New(limits, nil, &mockStore{},
+		WithRecipientLists(RecipientLists{Allowlists: map[common.Address]AddressSet{tokenA: addressSet{userA: true}}}))
+
+	require.NoError(t, c.Check(userA, tokenA, big.NewInt(1)))
+	err := c.Check(userB, tokenA, big.NewInt(1))
+	require.ErrorIs(t, err, ErrRecipientNotAllowed)
+	require.Equal(t, ReasonRecipientNotAllowed, ReasonCode(err))
+
+	// Tokens without an allowlist are not restricted.
+	require.NoError(t, c.Check(userB, tokenB, big.NewInt(1)))
+}
+
+func TestReasonCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{ErrInvalidAmount, ReasonInvalidAmount},
+		{fmt.Errorf("%w for token: 2 > 1", ErrUserDailyLimitExceeded), ReasonAmountLimit},
+		{fmt.Errorf("%w (7d) for token: 2 > 1", ErrWindowCountExceeded), ReasonCountLimit},
+		{ErrFiatHourlyLimitExceeded, ReasonFiatLimit},
+		{fmt.Errorf("%w: timeout", ErrPricesUnavailable), ReasonPriceUnavailable},
+		{fmt.Errorf("failed to get hourly withdrawn amount: disk full"), ReasonCheckFailed},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.code, ReasonCode(tt.err), tt.err.Error())
+	}
+}
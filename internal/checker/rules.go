@@ -0,0 +1,86 @@
+package checker
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/layer-3/nitewatch/policy"
+)
+
+// Names of the rules returned by Checker.Rules.
+const (
+	RuleSanity           = "sanity"
+	RuleRecipients       = "recipients"
+	RuleLimits           = "limits"
+	RuleUserLimits       = "user_limits"
+	RuleCrossChainLimits = "cross_chain_limits"
+	RulePrices           = "prices"
+	RuleFiatLimits       = "fiat_limits"
+	RuleAnomaly          = "anomaly"
+	RuleLiquidity        = "liquidity"
+)
+
+// AnnotationPrices is the request annotation under which the prices rule
+// stores the *PriceSnapshot the fiat limits rule evaluates the withdrawal with.
+const AnnotationPrices = "prices"
+
+// AnnotationAnomalyStats is the request annotation under which the anomaly
+// rule stores the *AnomalyStats it compared the withdrawal to.
+const AnnotationAnomalyStats = "anomaly_stats"
+
+// AnnotationLiquidity is the request annotation under which the liquidity
+// rule stores the *Liquidity it compared the withdrawal to.
+const AnnotationLiquidity = "liquidity"
+
+// Rules returns the checks of the Checker as policy rules, in the order
+// CheckAt applies them. The sanity rule must come first: the others assume a
+// positive amount.
+func (c *Checker) Rules() []policy.Rule {
+	return []policy.Rule{
+		policy.NewRule(RuleSanity, func(req *policy.Request) error {
+			if req.Amount == nil || req.Amount.Sign() <= 0 {
+				return ErrInvalidAmount
+			}
+			if req.User == (common.Address{}) {
+				return ErrInvalidUser
+			}
+			return nil
+		}),
+		policy.NewRule(RuleRecipients, func(req *policy.Request) error {
+			return c.checkRecipient(req.User, req.Token)
+		}),
+		policy.NewRule(RuleLimits, func(req *policy.Request) error {
+			return c.checkGlobalLimits(req.User, req.Token, req.Amount, req.Time)
+		}),
+		policy.NewRule(RuleUserLimits, func(req *policy.Request) error {
+			return c.checkUserLimits(req.User, req.Token, req.Amount, req.Time)
+		}),
+		policy.NewRule(RuleCrossChainLimits, func(req *policy.Request) error {
+			return c.checkCrossChainLimits(req.Token, req.Amount, req.Time)
+		}),
+		policy.NewRule(RulePrices, func(req *policy.Request) error {
+			prices, err := c.fiatPrices(req.Token)
+			if prices != nil {
+				req.Annotate(AnnotationPrices, prices)
+			}
+			return err
+		}),
+		policy.NewRule(RuleFiatLimits, func(req *policy.Request) error {
+			prices, _ := req.Annotation(AnnotationPrices).(*PriceSnapshot)
+			return c.checkFiatLimits(req.User, req.Token, req.Amount, req.Time, prices)
+		}),
+		policy.NewRule(RuleAnomaly, func(req *policy.Request) error {
+			stats, err := c.checkAnomaly(req.User, req.Token, req.Amount, req.Time)
+			if stats != nil {
+				req.Annotate(AnnotationAnomalyStats, stats)
+			}
+			return err
+		}),
+		policy.NewRule(RuleLiquidity, func(req *policy.Request) error {
+			liquidity, err := c.CheckLiquidity(req.Contract, req.WithdrawalID, req.Token, req.Amount, req.Time)
+			if liquidity != nil {
+				req.Annotate(AnnotationLiquidity, liquidity)
+			}
+			return err
+		}),
+	}
+}
@@ -0,0 +1,53 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TierSource assigns users to limit tiers.
+type TierSource interface {
+	// UserTier returns the tier of user, or "" if it has none.
+	UserTier(user common.Address) (string, error)
+}
+
+// WithUserTiers makes the Checker apply the per-user limits of the tier source
+// assigns a user to, for tokens the user has no override for. Users of a tier
+// that is not in tiers are treated as having none.
+func WithUserTiers(tiers map[string]map[common.Address]Limit, source TierSource) Option {
+	return func(c *Checker) {
+		c.tiers = tiers
+		c.tierSource = source
+	}
+}
+
+// WithDefaultUserLimits makes the Checker apply per-user limits to every user
+// that has neither an override nor a tier limit for the token.
+func WithDefaultUserLimits(limits map[common.Address]Limit) Option {
+	return func(c *Checker) {
+		c.defaultUserLimits = limits
+	}
+}
+
+// resolveUserLimit returns the per-user limit of token for user: its override,
+// else the limit of its tier, else the default per-user limit. It returns nil
+// if there is none.
+func (c *Checker) resolveUserLimit(user, token common.Address) (*Limit, error) {
+	if l, ok := c.userOverrides[user][token]; ok {
+		return &l, nil
+	}
+	if c.tierSource != nil {
+		tier, err := c.tierSource.UserTier(user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tier of user %s: %w", user.Hex(), err)
+		}
+		if l, ok := c.tiers[tier][token]; ok {
+			return &l, nil
+		}
+	}
+	if l, ok := c.defaultUserLimits[token]; ok {
+		return &l, nil
+	}
+	return nil, nil
+}
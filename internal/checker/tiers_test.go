@@ -0,0 +1,87 @@
+package checker
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+type mapTiers map[common.Address]string
+
+func (m mapTiers) UserTier(user common.Address) (string, error) {
+	return m[user], nil
+}
+
+type failingTiers struct{}
+
+func (failingTiers) UserTier(common.Address) (string, error) {
+	return "", errors.New("db down")
+}
+
+var userC = common.HexToAddress("0x3333333333333333333333333333333333333333")
+
+func TestCheck_UserLimitResolution(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	store := &mockStore{}
+	global := map[common.Address]Limit{
+		tokenA: {Daily: big.NewInt(1e6)},
+		tokenB: {Daily: big.NewInt(1e6)},
+	}
+	overrides := map[common.Address]map[common.Address]Limit{
+		userA: {tokenA: {Daily: big.NewInt(500)}},
+	}
+	tiers := map[string]map[common.Address]Limit{
+		"vip": {tokenA: {Daily: big.NewInt(300)}},
+	}
+	defaults := map[common.Address]Limit{
+		tokenA: {Daily: big.NewInt(100)},
+		tokenB: {Daily: big.NewInt(100)},
+	}
+	c := New(global, overrides, store,
+		WithUserTiers(tiers, mapTiers{userA: "vip", userB: "vip", userC: "unknown"}),
+		WithDefaultUserLimits(defaults))
+	c.nowFunc = func() time.Time { return now }
+
+	// The override of userA wins over its tier.
+	require.NoError(t, c.Check(userA, tokenA, big.NewInt(500)))
+	require.ErrorIs(t, c.Check(userA, tokenA, big.NewInt(501)), ErrUserDailyLimitExceeded)
+
+	// userB has no override and gets the limit of its tier.
+	require.NoError(t, c.Check(userB, tokenA, big.NewInt(300)))
+	require.ErrorIs(t, c.Check(userB, tokenA, big.NewInt(301)), ErrUserDailyLimitExceeded)
+
+	// Neither the override of userA nor the tier covers tokenB.
+	require.ErrorIs(t, c.Check(userA, tokenB, big.NewInt(101)), ErrUserDailyLimitExceeded)
+	require.ErrorIs(t, c.Check(userB, tokenB, big.NewInt(101)), ErrUserDailyLimitExceeded)
+
+	// Users of an unknown tier or without one get the default limits.
+	require.ErrorIs(t, c.Check(userC, tokenA, big.NewInt(101)), ErrUserDailyLimitExceeded)
+	require.NoError(t, c.Check(common.HexToAddress("0x4444444444444444444444444444444444444444"), tokenA, big.NewInt(100)))
+}
+
+func TestCheck_NoDefaultUserLimits(t *testing.T) {
+	c := New(globalLimits(tokenA, nil, big.NewInt(1e6)), nil, &mockStore{},
+		WithUserTiers(map[string]map[common.Address]Limit{"vip": {tokenA: {Daily: big.NewInt(100)}}}, mapTiers{userA: "vip"}))
+
+	require.ErrorIs(t, c.Check(userA, tokenA, big.NewInt(101)), ErrUserDailyLimitExceeded)
+	require.NoError(t, c.Check(userB, tokenA, big.NewInt(1e6)), "users without a tier have no per-user limit")
+}
+
+func TestCheck_TierSourceError(t *testing.T) {
+	c := New(globalLimits(tokenA, nil, big.NewInt(1e6)), nil, &mockStore{},
+		WithUserTiers(nil, failingTiers{}))
+
+	err := c.Check(userA, tokenA, big.NewInt(1))
+	require.ErrorContains(t, err, "failed to get tier of user")
+	require.Equal(t, ReasonCheckFailed, ReasonCode(err))
+
+	// An override does not need the tier.
+	c = New(globalLimits(tokenA, nil, big.NewInt(1e6)), map[common.Address]map[common.Address]Limit{
+		userA: {tokenA: {Daily: big.NewInt(10)}},
+	}, &mockStore{}, WithUserTiers(nil, failingTiers{}))
+	require.NoError(t, c.Check(userA, tokenA, big.NewInt(1)))
+}
@@ -0,0 +1,179 @@
+// Package price provides sources of the token prices fiat-denominated
+// withdrawal limits are evaluated with. File and HTTP both read a JSON
+// document of the form
+//
+//	{
+//	  "currency": "USD",
+//	  "updated_at": "2025-01-01T12:00:00Z",
+//	  "prices": {
+//	    "0x0000000000000000000000000000000000000000": "3150.42",
+//	    "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48": "1.0001"
+//	  }
+//	}
+//
+// where each price is a decimal string for one whole token. updated_at is
+// optional; without it, prices are considered as old as the file or response.
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/layer-3/nitewatch/internal/checker"
+)
+
+// ErrStale is returned when the latest prices are older than the maximum age.
+var ErrStale = errors.New("prices are stale")
+
+type document struct {
+	Currency  string            `json:"currency"`
+	UpdatedAt *time.Time        `json:"updated_at"`
+	Prices    map[string]string `json:"prices"`
+}
+
+// Options configures how often a Source reloads prices and how old they may
+// get before it refuses to return them.
+type Options struct {
+	RefreshInterval time.Duration
+	MaxAge          time.Duration
+}
+
+// Source caches the prices of a document loaded from a file or URL. Prices are
+// reloaded when they were loaded more than RefreshInterval ago. If reloading
+// fails, the cached prices are used until they are older than MaxAge.
+type Source struct {
+	load func(ctx context.Context) (*checker.PriceSnapshot, error)
+	opts Options
+
+	mu       sync.Mutex
+	snapshot *checker.PriceSnapshot
+	loadedAt time.Time
+	loadErr  error
+	nowFunc  func() time.Time
+}
+
+var _ checker.PriceSource = (*Source)(nil)
+
+// NewFile returns a Source reading the document from a local file.
+func NewFile(path string, opts Options) *Source {
+	return newSource(opts, func(ctx context.Context) (*checker.PriceSnapshot, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parse(data, info.ModTime())
+	})
+}
+
+// NewHTTP returns a Source fetching the document from url with a GET request.
+func NewHTTP(url string, client *http.Client, opts Options) *Source {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return newSource(opts, func(ctx context.Context) (*checker.PriceSnapshot, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return nil, err
+		}
+		return parse(data, time.Now())
+	})
+}
+
+func newSource(opts Options, load func(ctx context.Context) (*checker.PriceSnapshot, error)) *Source {
+	return &Source{load: load, opts: opts, nowFunc: time.Now}
+}
+
+// Prices returns the cached prices of the given tokens, reloading them first
+// if they are due.
+func (s *Source) Prices(tokens []common.Address) (*checker.PriceSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.nowFunc()
+	if s.snapshot == nil || now.Sub(s.loadedAt) >= s.opts.RefreshInterval {
+		snapshot, err := s.load(context.Background())
+		if err == nil {
+			s.snapshot, s.loadErr = snapshot, nil
+		} else {
+			s.loadErr = err
+		}
+		// Also back off after a failure instead of reloading on every call.
+		s.loadedAt = now
+	}
+
+	if s.snapshot == nil {
+		return nil, fmt.Errorf("failed to load prices: %w", s.loadErr)
+	}
+	if age := now.Sub(s.snapshot.Time); s.opts.MaxAge > 0 && age > s.opts.MaxAge {
+		if s.loadErr != nil {
+			return nil, fmt.Errorf("%w: %s old, reload failed: %w", ErrStale, age.Round(time.Second), s.loadErr)
+		}
+		return nil, fmt.Errorf("%w: %s old", ErrStale, age.Round(time.Second))
+	}
+
+	result := &checker.PriceSnapshot{
+		Currency: s.snapshot.Currency,
+		Time:     s.snapshot.Time,
+		Prices:   make(map[common.Address]*big.Rat, len(tokens)),
+	}
+	for _, token := range tokens {
+		price, ok := s.snapshot.Prices[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", checker.ErrNoPrice, token.Hex())
+		}
+		result.Prices[token] = price
+	}
+	return result, nil
+}
+
+func parse(data []byte, loadedAt time.Time) (*checker.PriceSnapshot, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid price document: %w", err)
+	}
+	snapshot := &checker.PriceSnapshot{
+		Currency: doc.Currency,
+		Time:     loadedAt,
+		Prices:   make(map[common.Address]*big.Rat, len(doc.Prices)),
+	}
+	if doc.UpdatedAt != nil {
+		snapshot.Time = *doc.UpdatedAt
+	}
+	for addr, value := range doc.Prices {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("invalid token address in price document: %s", addr)
+		}
+		price, ok := new(big.Rat).SetString(value)
+		if !ok || price.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid price for %s: %q", addr, value)
+		}
+		snapshot.Prices[common.HexToAddress(addr)] = price
+	}
+	return snapshot, nil
+}
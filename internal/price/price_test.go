@@ -0,0 +1,91 @@
+package price
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/layer-3/nitewatch/internal/checker"
+)
+
+var (
+	eth  = common.Address{}
+	usdc = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+)
+
+func TestFile_Prices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"currency": "USD",
+		"prices": {
+			"0x0000000000000000000000000000000000000000": "3150.42",
+			"0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48": "1.0001"
+		}
+	}`), 0o600))
+
+	src := NewFile(path, Options{RefreshInterval: time.Minute, MaxAge: time.Hour})
+	snapshot, err := src.Prices([]common.Address{eth, usdc})
+	require.NoError(t, err)
+	require.Equal(t, "USD", snapshot.Currency)
+	require.Equal(t, "3150.42", snapshot.Prices[eth].FloatString(2))
+	require.Equal(t, "1.0001", snapshot.Prices[usdc].FloatString(4))
+
+	_, err = src.Prices([]common.Address{common.HexToAddress("0x01")})
+	require.ErrorIs(t, err, checker.ErrNoPrice)
+}
+
+func TestFile_InvalidDocument(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"prices": {"0x0000000000000000000000000000000000000000": "-1"}}`), 0o600))
+
+	_, err := NewFile(path, Options{}).Prices([]common.Address{eth})
+	require.ErrorContains(t, err, "invalid price")
+}
+
+func TestHTTP_RefreshAndStaleness(t *testing.T) {
+	var requests atomic.Int32
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"currency": "USD", "updated_at": "2025-01-01T12:00:00Z", "prices": {"0x0000000000000000000000000000000000000000": "3000"}}`))
+	}))
+	defer server.Close()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	src := NewHTTP(server.URL, server.Client(), Options{RefreshInterval: time.Minute, MaxAge: 5 * time.Minute})
+	src.nowFunc = func() time.Time { return now }
+
+	snapshot, err := src.Prices([]common.Address{eth})
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), snapshot.Time)
+
+	// Cached within the refresh interval.
+	now = now.Add(30 * time.Second)
+	_, err = src.Prices([]common.Address{eth})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), requests.Load())
+
+	// A failed reload falls back to the cached prices while they are fresh.
+	fail.Store(true)
+	now = now.Add(time.Minute)
+	_, err = src.Prices([]common.Address{eth})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), requests.Load())
+
+	// The source keeps publishing prices from 12:00, which become stale.
+	fail.Store(false)
+	now = now.Add(5 * time.Minute)
+	_, err = src.Prices([]common.Address{eth})
+	require.ErrorIs(t, err, ErrStale)
+}
@@ -3,68 +3,188 @@ package store
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
 	"github.com/layer-3/nitewatch/custody"
 )
 
+// WithdrawalModel is a withdrawal accounted towards the limits. The composite
+// indexes serve the window sums, which select by token (and user) and a
+// timestamp range.
 type WithdrawalModel struct {
 	gorm.Model
 	WithdrawalID string `gorm:"uniqueIndex;type:varchar(66)"`
-	User         string `gorm:"index;type:varchar(42)"`
-	Token        string `gorm:"index;type:varchar(42)"`
+	User         string `gorm:"index;index:idx_withdrawal_user_token_time,priority:1;type:varchar(42)"`
+	Token        string `gorm:"index;index:idx_withdrawal_token_time,priority:1;index:idx_withdrawal_user_token_time,priority:2;type:varchar(42)"`
 	Amount       string `gorm:"type:text"`
 	BlockNumber  uint64
 	TxHash       string    `gorm:"type:varchar(66)"`
-	Timestamp    time.Time `gorm:"index"`
+	Timestamp    time.Time `gorm:"index;index:idx_withdrawal_token_time,priority:2;index:idx_withdrawal_user_token_time,priority:3"`
 }
 
+// BlockCursorModel is the position of a stream in the events of one custody
+// contract. Cursors stored before contracts were tracked separately have an
+// empty Contract.
 type BlockCursorModel struct {
 	StreamName  string    `gorm:"primaryKey;type:varchar(64)"`
+	Contract    string    `gorm:"primaryKey;type:varchar(42);default:''"`
 	BlockNumber uint64    `gorm:"not null"`
+	BlockHash   string    `gorm:"type:varchar(66);not null;default:''"`
 	LogIndex    uint      `gorm:"not null"`
 	UpdatedAt   time.Time `gorm:"not null;autoUpdateTime"`
 }
 
 type WithdrawEventModel struct {
-	ID           uint64    `gorm:"primaryKey;autoIncrement"`
-	WithdrawalID string    `gorm:"type:varchar(66);not null;uniqueIndex"`
-	UserAddress  string    `gorm:"type:varchar(42);not null"`
-	TokenAddress string    `gorm:"type:varchar(42);not null"`
-	Amount       string    `gorm:"type:text;not null"`
-	Decision     string    `gorm:"type:varchar(16);not null"`
-	Reason       string    `gorm:"type:text;not null;default:''"`
-	BlockNumber  uint64    `gorm:"not null"`
-	TxHash       string    `gorm:"type:varchar(66);not null"`
-	LogIndex     uint      `gorm:"not null"`
-	CreatedAt    time.Time `gorm:"not null;autoCreateTime"`
+	ID            uint64 `gorm:"primaryKey;autoIncrement"`
+	WithdrawalID  string `gorm:"type:varchar(66);not null;uniqueIndex"`
+	Contract      string `gorm:"type:varchar(42);not null;default:''"`
+	ContractLabel string `gorm:"type:varchar(64);not null;default:''"`
+	UserAddress   string `gorm:"type:varchar(42);not null"`
+	TokenAddress  string `gorm:"type:varchar(42);not null"`
+	Amount        string `gorm:"type:text;not null"`
+	Decision      string `gorm:"type:varchar(16);not null"`
+	Reason        string `gorm:"type:text;not null;default:''"`
+	// ReasonCode classifies the reason of a rejection, see checker.ReasonCode.
+	ReasonCode   string `gorm:"type:varchar(32);not null;default:'';index"`
+	BlockNumber  uint64 `gorm:"not null;index"`
+	BlockHash    string `gorm:"type:varchar(66);not null;default:''"`
+	BlockTime    time.Time
+	TxHash       string `gorm:"type:varchar(66);not null"`
+	LogIndex     uint   `gorm:"not null"`
+	Action       string `gorm:"type:varchar(16);not null;default:''"`
+	ActionTxHash string `gorm:"type:varchar(66);not null;default:''"`
+	ActionRawTx  string `gorm:"type:text;not null;default:''"`
+	// PriceSnapshot is the JSON-encoded prices fiat limits were evaluated
+	// with, empty if they did not apply.
+	PriceSnapshot string `gorm:"type:text;not null;default:''"`
+	// AnomalyStats is the JSON-encoded history statistics of the user that
+	// anomaly detection compared the withdrawal to, empty if it did not run.
+	AnomalyStats string `gorm:"type:text;not null;default:''"`
+	// ReviewDeadline is when a held withdrawal is rejected unless an
+	// operator reviewed it, and ReviewedBy the operator who did.
+	ReviewDeadline *time.Time `gorm:"index"`
+	ReviewedBy     string     `gorm:"type:varchar(64);not null;default:''"`
+	CreatedAt      time.Time  `gorm:"not null;autoCreateTime"`
 }
 
+// DecisionProcessing marks a withdraw event whose finalize or reject
+// transaction was signed and is about to be, or was, broadcast, but whose
+// outcome is not recorded yet. The signed transaction is kept in ActionRawTx
+// so that a restarted worker can track it down instead of sending another one.
+const DecisionProcessing = "processing"
+
+// Actions taken on-chain for a withdraw event.
+const (
+	ActionFinalize = "finalize"
+	ActionReject   = "reject"
+)
+
+// DecisionPending marks a withdraw event whose finalize transaction added an
+// approval that did not yet meet the contract's approval threshold. Other
+// signers may still finalize it until it expires.
+const DecisionPending = "pending"
+
+// DecisionHeld marks a withdraw event that violated a rule whose action is to
+// hold it: neither finalized nor rejected, it awaits an operator.
+const DecisionHeld = "held"
+
+// DecisionOrphaned marks a withdraw event whose block was removed from the
+// canonical chain by a reorg. Orphaned events do not count as processed, so
+// the withdrawal is re-evaluated if it reappears on the new canonical chain.
+const DecisionOrphaned = "orphaned"
+
+// withdrawStartedStream is the cursor stream advanced by RecordWithdrawEvent.
+const withdrawStartedStream = "withdraw_started"
+
 type PendingRejectionModel struct {
 	ID           uint64    `gorm:"primaryKey;autoIncrement"`
 	WithdrawalID string    `gorm:"type:varchar(66);not null;uniqueIndex"`
+	Contract     string    `gorm:"type:varchar(42);not null;default:''"`
 	Reason       string    `gorm:"type:text;not null;default:''"`
 	Completed    bool      `gorm:"not null;default:false"`
 	CreatedAt    time.Time `gorm:"not null;autoCreateTime"`
 }
 
+// DeadLetterModel is a log the listener could not decode. Topics holds the
+// comma-separated hex topics and Data the hex-encoded log data.
+type DeadLetterModel struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement"`
+	Stream      string    `gorm:"type:varchar(64);not null;index"`
+	Contract    string    `gorm:"type:varchar(42);not null"`
+	BlockNumber uint64    `gorm:"not null"`
+	BlockHash   string    `gorm:"type:varchar(66);not null;uniqueIndex:idx_dead_letter_log"`
+	TxHash      string    `gorm:"type:varchar(66);not null"`
+	LogIndex    uint      `gorm:"not null;uniqueIndex:idx_dead_letter_log"`
+	Topics      string    `gorm:"type:text;not null"`
+	Data        string    `gorm:"type:text;not null"`
+	Reason      string    `gorm:"type:text;not null;default:''"`
+	Replayed    bool      `gorm:"not null;default:false;index"`
+	CreatedAt   time.Time `gorm:"not null;autoCreateTime"`
+}
+
+// Log reconstructs the original log.
+func (m *DeadLetterModel) Log() types.Log {
+	var topics []common.Hash
+	if m.Topics != "" {
+		for _, topic := range strings.Split(m.Topics, ",") {
+			topics = append(topics, common.HexToHash(topic))
+		}
+	}
+	return types.Log{
+		Address:     common.HexToAddress(m.Contract),
+		Topics:      topics,
+		Data:        common.FromHex(m.Data),
+		BlockNumber: m.BlockNumber,
+		TxHash:      common.HexToHash(m.TxHash),
+		BlockHash:   common.HexToHash(m.BlockHash),
+		Index:       m.LogIndex,
+	}
+}
+
+// UserTierModel assigns a user to a limit tier.
+type UserTierModel struct {
+	UserAddress string    `gorm:"type:varchar(42);primaryKey"`
+	Tier        string    `gorm:"type:varchar(64);not null"`
+	UpdatedAt   time.Time `gorm:"not null;autoUpdateTime"`
+}
+
+// PauseModel is the pause state of the worker, a single row. While paused,
+// withdrawals are held instead of finalized.
+type PauseModel struct {
+	ID        uint      `gorm:"primaryKey"`
+	Paused    bool      `gorm:"not null;default:false"`
+	Reason    string    `gorm:"type:text;not null;default:''"`
+	ChangedBy string    `gorm:"type:varchar(64);not null;default:''"`
+	ChangedAt time.Time `gorm:"not null"`
+}
+
 type Adapter struct {
 	db *gorm.DB
 }
 
 func NewAdapter(db *gorm.DB) (*Adapter, error) {
-	if err := db.AutoMigrate(&WithdrawalModel{}, &BlockCursorModel{}, &WithdrawEventModel{}, &PendingRejectionModel{}); err != nil {
+	if err := migrateCursorContracts(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate cursors: %w", err)
+	}
+	if err := db.AutoMigrate(&WithdrawalModel{}, &BlockCursorModel{}, &WithdrawEventModel{}, &PendingRejectionModel{}, &DeadLetterModel{}, &UserTierModel{}, &PauseModel{}); err != nil {
 		return nil, err
 	}
 	return &Adapter{db: db}, nil
 }
 
-var _ custody.WithdrawalStore = (*Adapter)(nil)
+var (
+	_ custody.WithdrawalStore = (*Adapter)(nil)
+	_ custody.DeadLetterStore = (*Adapter)(nil)
+)
 
 func (a *Adapter) Save(w *custody.Withdrawal) error {
 	model := &WithdrawalModel{
@@ -79,62 +199,310 @@ func (a *Adapter) Save(w *custody.Withdrawal) error {
 	return a.db.Create(model).Error
 }
 
-func (a *Adapter) GetTotalWithdrawn(token common.Address, since time.Time) (*big.Int, error) {
-	var withdrawals []WithdrawalModel
-	if err := a.db.Where("token = ? AND timestamp >= ?", token.Hex(), since).Find(&withdrawals).Error; err != nil {
-		return nil, err
-	}
-	return sumAmounts(withdrawals)
+func (a *Adapter) GetTotalWithdrawn(token common.Address, since, until time.Time) (*big.Int, error) {
+	return a.sumWithdrawn(a.db.Where("token = ? AND timestamp >= ? AND timestamp <= ?", token.Hex(), since, until))
 }
 
-func (a *Adapter) GetTotalWithdrawnByUser(user, token common.Address, since time.Time) (*big.Int, error) {
-	var withdrawals []WithdrawalModel
-	if err := a.db.Where("user = ? AND token = ? AND timestamp >= ?", user.Hex(), token.Hex(), since).Find(&withdrawals).Error; err != nil {
-		return nil, err
-	}
-	return sumAmounts(withdrawals)
+func (a *Adapter) GetTotalWithdrawnByUser(user, token common.Address, since, until time.Time) (*big.Int, error) {
+	return a.sumWithdrawn(a.db.Where("user = ? AND token = ? AND timestamp >= ? AND timestamp <= ?",
+		user.Hex(), token.Hex(), since, until))
 }
 
-func sumAmounts(withdrawals []WithdrawalModel) (*big.Int, error) {
+// sumWithdrawn adds up the amounts of the withdrawals matching query. Amounts
+// are uint256 decimal strings that overflow SQL integers, so only the amounts
+// in the window are loaded and added up here.
+func (a *Adapter) sumWithdrawn(query *gorm.DB) (*big.Int, error) {
+	var amounts []string
+	if err := query.Model(&WithdrawalModel{}).Pluck("amount", &amounts).Error; err != nil {
+		return nil, err
+	}
 	total := new(big.Int)
-	for _, w := range withdrawals {
-		amount, ok := new(big.Int).SetString(w.Amount, 10)
+	for _, s := range amounts {
+		amount, ok := new(big.Int).SetString(s, 10)
 		if !ok {
-			return nil, fmt.Errorf("corrupted amount in withdrawal %s: %q", w.WithdrawalID, w.Amount)
+			return nil, fmt.Errorf("corrupted withdrawal amount: %q", s)
 		}
 		total.Add(total, amount)
 	}
 	return total, nil
 }
 
-func (a *Adapter) GetCursor(streamName string) (blockNumber uint64, logIndex uint32, err error) {
+func (a *Adapter) CountWithdrawals(token common.Address, since, until time.Time) (uint64, error) {
+	var count int64
+	if err := a.db.Model(&WithdrawalModel{}).
+		Where("token = ? AND timestamp >= ? AND timestamp <= ?", token.Hex(), since, until).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return uint64(count), nil
+}
+
+func (a *Adapter) CountWithdrawalsByUser(user, token common.Address, since, until time.Time) (uint64, error) {
+	var count int64
+	if err := a.db.Model(&WithdrawalModel{}).
+		Where("user = ? AND token = ? AND timestamp >= ? AND timestamp <= ?", user.Hex(), token.Hex(), since, until).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return uint64(count), nil
+}
+
+func (a *Adapter) GetWithdrawalsByUser(user common.Address, since time.Time) ([]*custody.Withdrawal, error) {
+	var models []WithdrawalModel
+	if err := a.db.Where("user = ? AND timestamp >= ?", user.Hex(), since).
+		Order("timestamp, id").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	withdrawals := make([]*custody.Withdrawal, 0, len(models))
+	for _, m := range models {
+		amount, ok := new(big.Int).SetString(m.Amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("corrupted amount in withdrawal %s: %q", m.WithdrawalID, m.Amount)
+		}
+		withdrawals = append(withdrawals, &custody.Withdrawal{
+			WithdrawalID: common.HexToHash(m.WithdrawalID),
+			User:         common.HexToAddress(m.User),
+			Token:        common.HexToAddress(m.Token),
+			Amount:       amount,
+			BlockNumber:  m.BlockNumber,
+			TxHash:       common.HexToHash(m.TxHash),
+			Timestamp:    m.Timestamp,
+		})
+	}
+	return withdrawals, nil
+}
+
+// GetCursor returns the persisted cursor of a stream that is not tracked per
+// contract, or a zero cursor if none was stored yet.
+func (a *Adapter) GetCursor(streamName string) (custody.Cursor, error) {
+	return a.GetContractCursor(streamName, "")
+}
+
+// GetContractCursor returns the persisted cursor of a stream in the events of
+// the given contract, or a zero cursor if none was stored yet.
+func (a *Adapter) GetContractCursor(streamName, contract string) (custody.Cursor, error) {
 	var cursor BlockCursorModel
-	result := a.db.Where("stream_name = ?", streamName).First(&cursor)
+	result := a.db.Where("stream_name = ? AND contract = ?", streamName, contract).First(&cursor)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return 0, 0, nil
+			return custody.Cursor{}, nil
 		}
-		return 0, 0, result.Error
+		return custody.Cursor{}, result.Error
+	}
+	return custody.Cursor{
+		BlockNumber: cursor.BlockNumber,
+		LogIndex:    uint32(cursor.LogIndex),
+		BlockHash:   common.HexToHash(cursor.BlockHash),
+	}, nil
+}
+
+// GetWithdrawEventBlocks returns up to limit of the most recent distinct blocks
+// that recorded withdraw events were emitted in, oldest first. Orphaned events
+// and events recorded without a block hash are excluded.
+func (a *Adapter) GetWithdrawEventBlocks(limit int) ([]custody.BlockRef, error) {
+	var rows []struct {
+		BlockNumber uint64
+		BlockHash   string
+	}
+	err := a.db.Model(&WithdrawEventModel{}).
+		Distinct("block_number", "block_hash").
+		Where("decision <> ? AND block_hash <> ''", DecisionOrphaned).
+		Order("block_number DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]custody.BlockRef, len(rows))
+	for i, row := range rows {
+		refs[len(rows)-1-i] = custody.BlockRef{Number: row.BlockNumber, Hash: common.HexToHash(row.BlockHash)}
 	}
-	return cursor.BlockNumber, uint32(cursor.LogIndex), nil
+	return refs, nil
 }
 
+// RecordWithdrawEvent stores the decision taken for a withdraw event and
+// advances the withdraw_started cursor of its contract to it in the same
+// transaction. The cursor never moves backwards, e.g. when a dead letter is replayed. An
+// existing decision is only replaced if it was orphaned by a reorg or is
+// still processing, or it is held for review.
 func (a *Adapter) RecordWithdrawEvent(ev *WithdrawEventModel) error {
 	return a.db.Transaction(func(tx *gorm.DB) error {
-		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(ev)
-		if result.Error != nil {
-			return result.Error
+		if err := upsertWithdrawEvent(tx, ev); err != nil {
+			return err
 		}
-		return upsertCursor(tx, "withdraw_started", ev.BlockNumber, ev.LogIndex)
+		return advanceCursor(tx, withdrawStartedStream, ev.Contract, ev.BlockNumber, ev.BlockHash, ev.LogIndex)
 	})
 }
 
+// SaveWithdrawIntent stores a withdraw event with DecisionProcessing before its
+// transaction is broadcast. The cursor is left untouched, so the event is
+// redelivered if the worker stops before recording the outcome.
+func (a *Adapter) SaveWithdrawIntent(ev *WithdrawEventModel) error {
+	if ev.Decision != DecisionProcessing {
+		return fmt.Errorf("withdraw intent must have decision %q, got %q", DecisionProcessing, ev.Decision)
+	}
+	return upsertWithdrawEvent(a.db, ev)
+}
+
+func upsertWithdrawEvent(tx *gorm.DB, ev *WithdrawEventModel) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "withdrawal_id"}},
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.IN{Column: clause.Column{Table: "withdraw_event_models", Name: "decision"}, Values: []any{DecisionOrphaned, DecisionProcessing, DecisionHeld}},
+		}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"contract", "contract_label", "user_address", "token_address", "amount", "decision", "reason", "reason_code",
+			"block_number", "block_hash", "block_time", "tx_hash", "log_index",
+			"action", "action_tx_hash", "action_raw_tx", "price_snapshot", "anomaly_stats", "review_deadline", "reviewed_by", "created_at",
+		}),
+	}).Create(ev).Error
+}
+
+// GetWithdrawEvent returns the event recorded for the withdrawal, or nil if
+// there is none.
+func (a *Adapter) GetWithdrawEvent(withdrawalID string) (*WithdrawEventModel, error) {
+	var ev WithdrawEventModel
+	if err := a.db.Where("withdrawal_id = ?", withdrawalID).First(&ev).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// ListHeldWithdrawEvents returns the withdraw events held for review, those
+// with the earliest deadline first.
+func (a *Adapter) ListHeldWithdrawEvents() ([]WithdrawEventModel, error) {
+	var events []WithdrawEventModel
+	if err := a.db.Where("decision = ?", DecisionHeld).Order("review_deadline, id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ListReviewIntents returns the processing intents of reviewed withdrawals:
+// transactions sent for a held withdrawal whose outcome is not recorded yet.
+func (a *Adapter) ListReviewIntents() ([]WithdrawEventModel, error) {
+	var events []WithdrawEventModel
+	if err := a.db.Where("decision = ? AND review_deadline IS NOT NULL", DecisionProcessing).Order("id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetCommittedAmount returns the amount of token that withdrawals from
+// contract approved but not yet paid out will take from its balance: those
+// awaiting the approval threshold that were requested at or after since, as
+// older ones expired, and those whose finalize transaction is in flight. The
+// withdrawal excludeID is not counted.
+func (a *Adapter) GetCommittedAmount(contract, token common.Address, excludeID string, since time.Time) (*big.Int, error) {
+	var events []WithdrawEventModel
+	if err := a.db.Select("withdrawal_id", "amount").
+		Where("contract = ? AND token_address = ? AND withdrawal_id <> ?", contract.Hex(), token.Hex(), excludeID).
+		Where("(decision = ? AND block_time >= ?) OR (decision = ? AND action = ?)",
+			DecisionPending, since, DecisionProcessing, ActionFinalize).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	total := new(big.Int)
+	for _, ev := range events {
+		amount, ok := new(big.Int).SetString(ev.Amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("corrupted amount in withdraw event %s: %q", ev.WithdrawalID, ev.Amount)
+		}
+		total.Add(total, amount)
+	}
+	return total, nil
+}
+
+// ListPendingWithdrawEvents returns the withdraw events from contract awaiting
+// the approval threshold.
+func (a *Adapter) ListPendingWithdrawEvents(contract common.Address) ([]WithdrawEventModel, error) {
+	var events []WithdrawEventModel
+	if err := a.db.Where("contract = ? AND decision = ?", contract.Hex(), DecisionPending).
+		Order("id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// CompletePendingWithdrawEvent records that a withdrawal awaiting the approval
+// threshold was finalized on-chain by other signers.
+func (a *Adapter) CompletePendingWithdrawEvent(withdrawalID string) error {
+	return a.db.Model(&WithdrawEventModel{}).
+		Where("withdrawal_id = ? AND decision = ?", withdrawalID, DecisionPending).
+		Updates(map[string]any{"decision": "approved", "reason": "approval added, finalized on-chain"}).Error
+}
+
+// HasWithdrawEvent reports whether a final decision was already recorded for
+// the withdrawal. Orphaned and processing decisions are not considered.
 func (a *Adapter) HasWithdrawEvent(withdrawalID string) bool {
 	var count int64
-	a.db.Model(&WithdrawEventModel{}).Where("withdrawal_id = ?", withdrawalID).Count(&count)
+	a.db.Model(&WithdrawEventModel{}).
+		Where("withdrawal_id = ? AND decision NOT IN ?", withdrawalID, []string{DecisionOrphaned, DecisionProcessing}).
+		Count(&count)
 	return count > 0
 }
 
+// HasWithdrawal reports whether the withdrawal was already saved for limit tracking.
+func (a *Adapter) HasWithdrawal(withdrawalID string) (bool, error) {
+	var count int64
+	if err := a.db.Model(&WithdrawalModel{}).Where("withdrawal_id = ?", withdrawalID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// OrphanWithdrawEvents marks every decision recorded at or after fromBlock as
+// orphaned and rewinds the withdraw_started cursors past the given canonical
+// ancestor to it. Withdrawals recorded for limit tracking and pending rejections of
+// the orphaned events are removed so that re-evaluation starts afresh.
+// It returns the withdrawal IDs that were orphaned.
+func (a *Adapter) OrphanWithdrawEvents(fromBlock uint64, ancestor custody.BlockRef) ([]string, error) {
+	var ids []string
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&WithdrawEventModel{}).
+			Where("block_number >= ? AND decision <> ?", fromBlock, DecisionOrphaned).
+			Pluck("withdrawal_id", &ids).Error; err != nil {
+			return err
+		}
+
+		if len(ids) > 0 {
+			if err := tx.Model(&WithdrawEventModel{}).
+				Where("withdrawal_id IN ?", ids).
+				Updates(map[string]any{
+					"decision": DecisionOrphaned,
+					"reason":   fmt.Sprintf("orphaned by chain reorganization at block %d", fromBlock),
+				}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("withdrawal_id IN ?", ids).Delete(&WithdrawalModel{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("withdrawal_id IN ?", ids).Delete(&PendingRejectionModel{}).Error; err != nil {
+				return err
+			}
+		}
+
+		var hash string
+		if ancestor.Hash != (common.Hash{}) {
+			hash = ancestor.Hash.Hex()
+		}
+		// The ancestor block was fully processed before the reorg.
+		return tx.Model(&BlockCursorModel{}).
+			Where("stream_name = ? AND block_number > ?", withdrawStartedStream, ancestor.Number).
+			Updates(map[string]any{
+				"block_number": ancestor.Number,
+				"block_hash":   hash,
+				"log_index":    uint(math.MaxUint32),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 func (a *Adapter) SavePendingRejection(p *PendingRejectionModel) error {
 	return a.db.Clauses(clause.OnConflict{DoNothing: true}).Create(p).Error
 }
@@ -153,14 +521,155 @@ func (a *Adapter) CompletePendingRejection(withdrawalID string) error {
 		Update("completed", true).Error
 }
 
-func upsertCursor(tx *gorm.DB, streamName string, blockNumber uint64, logIndex uint) error {
+// SaveDeadLetter stores a log the listener could not decode. Saving the same
+// log again is a no-op.
+func (a *Adapter) SaveDeadLetter(stream string, log types.Log, reason string) error {
+	topics := make([]string, len(log.Topics))
+	for i, topic := range log.Topics {
+		topics[i] = topic.Hex()
+	}
+	model := &DeadLetterModel{
+		Stream:      stream,
+		Contract:    log.Address.Hex(),
+		BlockNumber: log.BlockNumber,
+		BlockHash:   log.BlockHash.Hex(),
+		TxHash:      log.TxHash.Hex(),
+		LogIndex:    log.Index,
+		Topics:      strings.Join(topics, ","),
+		Data:        hexutil.Encode(log.Data),
+		Reason:      reason,
+	}
+	return a.db.Clauses(clause.OnConflict{DoNothing: true}).Create(model).Error
+}
+
+// ListDeadLetters returns dead letters oldest first, skipping replayed ones
+// unless includeReplayed is set.
+func (a *Adapter) ListDeadLetters(includeReplayed bool) ([]DeadLetterModel, error) {
+	query := a.db.Order("block_number, log_index")
+	if !includeReplayed {
+		query = query.Where("replayed = ?", false)
+	}
+	var letters []DeadLetterModel
+	if err := query.Find(&letters).Error; err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// GetDeadLetter returns the dead letter with the given ID, or nil if there is none.
+func (a *Adapter) GetDeadLetter(id uint64) (*DeadLetterModel, error) {
+	var letter DeadLetterModel
+	if err := a.db.First(&letter, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &letter, nil
+}
+
+func (a *Adapter) MarkDeadLetterReplayed(id uint64) error {
+	return a.db.Model(&DeadLetterModel{}).Where("id = ?", id).Update("replayed", true).Error
+}
+
+// UserTier returns the tier user is assigned to, or "" if none.
+func (a *Adapter) UserTier(user common.Address) (string, error) {
+	var m UserTierModel
+	err := a.db.Where("user_address = ?", user.Hex()).Take(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return m.Tier, nil
+}
+
+// SetUserTier assigns user to tier, replacing any previous assignment. An
+// empty tier removes the assignment.
+func (a *Adapter) SetUserTier(user common.Address, tier string) error {
+	if tier == "" {
+		return a.db.Where("user_address = ?", user.Hex()).Delete(&UserTierModel{}).Error
+	}
+	return a.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"tier", "updated_at"}),
+	}).Create(&UserTierModel{UserAddress: user.Hex(), Tier: tier}).Error
+}
+
+// ListUserTiers returns all tier assignments ordered by user.
+func (a *Adapter) ListUserTiers() ([]UserTierModel, error) {
+	var tiers []UserTierModel
+	if err := a.db.Order("user_address").Find(&tiers).Error; err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+// GetPause returns the stored pause state, or nil if it was never set.
+func (a *Adapter) GetPause() (*PauseModel, error) {
+	var m PauseModel
+	if err := a.db.Take(&m, 1).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SavePause stores the pause state.
+func (a *Adapter) SavePause(p *PauseModel) error {
+	p.ID = 1
+	return a.db.Save(p).Error
+}
+
+// advanceCursor stores a cursor unless the stored one is already at or past
+// the given position.
+func advanceCursor(tx *gorm.DB, streamName, contract string, blockNumber uint64, blockHash string, logIndex uint) error {
 	cursor := BlockCursorModel{
 		StreamName:  streamName,
+		Contract:    contract,
 		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
 		LogIndex:    logIndex,
 	}
+	table := "block_cursor_models"
 	return tx.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "stream_name"}},
-		DoUpdates: clause.AssignmentColumns([]string{"block_number", "log_index", "updated_at"}),
+		Columns: []clause.Column{{Name: "stream_name"}, {Name: "contract"}},
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Or(
+				clause.Lt{Column: clause.Column{Table: table, Name: "block_number"}, Value: blockNumber},
+				clause.And(
+					clause.Eq{Column: clause.Column{Table: table, Name: "block_number"}, Value: blockNumber},
+					clause.Lt{Column: clause.Column{Table: table, Name: "log_index"}, Value: logIndex},
+				),
+			),
+		}},
+		DoUpdates: clause.AssignmentColumns([]string{"block_number", "block_hash", "log_index", "updated_at"}),
 	}).Create(&cursor).Error
 }
+
+// migrateCursorContracts rebuilds a cursor table created before cursors were
+// kept per contract, since SQLite cannot change a primary key in place.
+// Existing cursors keep an empty contract.
+func migrateCursorContracts(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&BlockCursorModel{}) || m.HasColumn(&BlockCursorModel{}, "Contract") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		const legacy = "block_cursor_models_legacy"
+		if err := tx.Migrator().RenameTable(&BlockCursorModel{}, legacy); err != nil {
+			return err
+		}
+		if err := tx.Migrator().CreateTable(&BlockCursorModel{}); err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO block_cursor_models (stream_name, contract, block_number, block_hash, log_index, updated_at) " +
+			"SELECT stream_name, '', block_number, block_hash, log_index, updated_at FROM " + legacy).Error; err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable(legacy)
+	})
+}
@@ -1,11 +1,13 @@
 package store
 
 import (
+	"math"
 	"math/big"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -46,7 +48,7 @@ func TestSave(t *testing.T) {
 
 	require.NoError(t, a.Save(w))
 
-	total, err := a.GetTotalWithdrawn(tokenA, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	total, err := a.GetTotalWithdrawn(tokenA, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
 	require.NoError(t, err)
 	require.Equal(t, "1000", total.String())
 }
@@ -66,10 +68,75 @@ func TestSave_DuplicateWithdrawalID(t *testing.T) {
 	require.Error(t, a.Save(w))
 }
 
+func TestNewAdapter_CreatesWindowIndexes(t *testing.T) {
+	a := newTestAdapter(t)
+
+	require.True(t, a.db.Migrator().HasIndex(&WithdrawalModel{}, "idx_withdrawal_token_time"))
+	require.True(t, a.db.Migrator().HasIndex(&WithdrawalModel{}, "idx_withdrawal_user_token_time"))
+}
+
+func TestCountWithdrawals(t *testing.T) {
+	a := newTestAdapter(t)
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	withdrawals := []*custody.Withdrawal{
+		{WithdrawalID: [32]byte{1}, User: user, Token: tokenA, Amount: big.NewInt(100), Timestamp: base.Add(-2 * time.Hour)},
+		{WithdrawalID: [32]byte{2}, User: user, Token: tokenA, Amount: big.NewInt(200), Timestamp: base},
+		{WithdrawalID: [32]byte{3}, User: other, Token: tokenA, Amount: big.NewInt(300), Timestamp: base},
+		{WithdrawalID: [32]byte{4}, User: user, Token: tokenB, Amount: big.NewInt(400), Timestamp: base},
+	}
+	for _, w := range withdrawals {
+		require.NoError(t, a.Save(w))
+	}
+
+	n, err := a.CountWithdrawals(tokenA, base.Add(-time.Hour), base.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), n)
+
+	n, err = a.CountWithdrawalsByUser(user, tokenA, base.Add(-3*time.Hour), base.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), n)
+
+	n, err = a.CountWithdrawalsByUser(user, tokenA, base.Add(time.Second), base.Add(time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = a.CountWithdrawals(tokenA, base.Add(-3*time.Hour), base.Add(-time.Second))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), n)
+}
+
+func TestGetWithdrawalsByUser(t *testing.T) {
+	a := newTestAdapter(t)
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	withdrawals := []*custody.Withdrawal{
+		{WithdrawalID: [32]byte{1}, User: user, Token: tokenB, Amount: big.NewInt(400), Timestamp: base},
+		{WithdrawalID: [32]byte{2}, User: user, Token: tokenA, Amount: big.NewInt(100), Timestamp: base.Add(-2 * time.Hour)},
+		{WithdrawalID: [32]byte{3}, User: other, Token: tokenA, Amount: big.NewInt(300), Timestamp: base},
+		{WithdrawalID: [32]byte{4}, User: user, Token: tokenA, Amount: big.NewInt(200), Timestamp: base.Add(-time.Hour), BlockNumber: 7},
+	}
+	for _, w := range withdrawals {
+		require.NoError(t, a.Save(w))
+	}
+
+	got, err := a.GetWithdrawalsByUser(user, base.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, [32]byte{4}, got[0].WithdrawalID)
+	require.Equal(t, tokenA, got[0].Token)
+	require.Equal(t, "200", got[0].Amount.String())
+	require.Equal(t, uint64(7), got[0].BlockNumber)
+	require.True(t, got[0].Timestamp.Equal(base.Add(-time.Hour)))
+	require.Equal(t, [32]byte{1}, got[1].WithdrawalID)
+}
+
 func TestGetTotalWithdrawn_Empty(t *testing.T) {
 	a := newTestAdapter(t)
 
-	total, err := a.GetTotalWithdrawn(tokenA, time.Time{})
+	total, err := a.GetTotalWithdrawn(tokenA, time.Time{}, time.Now())
 	require.NoError(t, err)
 	require.Equal(t, 0, total.Sign())
 }
@@ -88,17 +155,26 @@ func TestGetTotalWithdrawn_TimeFilter(t *testing.T) {
 		require.NoError(t, a.Save(w))
 	}
 
-	total, err := a.GetTotalWithdrawn(tokenA, base)
+	total, err := a.GetTotalWithdrawn(tokenA, base, base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, "300", total.String())
 
-	total, err = a.GetTotalWithdrawn(tokenA, base.Add(-1*time.Hour))
+	total, err = a.GetTotalWithdrawn(tokenA, base.Add(-1*time.Hour), base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, "500", total.String())
 
-	total, err = a.GetTotalWithdrawn(tokenA, base.Add(-3*time.Hour))
+	total, err = a.GetTotalWithdrawn(tokenA, base.Add(-3*time.Hour), base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, "600", total.String())
+
+	// Withdrawals recorded after the window ends do not count.
+	total, err = a.GetTotalWithdrawn(tokenA, base.Add(-3*time.Hour), base)
+	require.NoError(t, err)
+	require.Equal(t, "300", total.String())
+
+	total, err = a.GetTotalWithdrawn(tokenA, base.Add(-3*time.Hour), base.Add(-30*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, "300", total.String(), "the end of the window is inclusive")
 }
 
 func TestGetTotalWithdrawn_TokenFilter(t *testing.T) {
@@ -115,11 +191,11 @@ func TestGetTotalWithdrawn_TokenFilter(t *testing.T) {
 		require.NoError(t, a.Save(w))
 	}
 
-	totalA, err := a.GetTotalWithdrawn(tokenA, base.Add(-time.Hour))
+	totalA, err := a.GetTotalWithdrawn(tokenA, base.Add(-time.Hour), base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, "400", totalA.String())
 
-	totalB, err := a.GetTotalWithdrawn(tokenB, base.Add(-time.Hour))
+	totalB, err := a.GetTotalWithdrawn(tokenB, base.Add(-time.Hour), base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, "200", totalB.String())
 }
@@ -141,22 +217,22 @@ func TestGetTotalWithdrawnByUser(t *testing.T) {
 	}
 
 	// user + tokenA = 100 + 200 = 300
-	total, err := a.GetTotalWithdrawnByUser(user, tokenA, base.Add(-time.Hour))
+	total, err := a.GetTotalWithdrawnByUser(user, tokenA, base.Add(-time.Hour), base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, "300", total.String())
 
 	// userB + tokenA = 300
-	total, err = a.GetTotalWithdrawnByUser(userB, tokenA, base.Add(-time.Hour))
+	total, err = a.GetTotalWithdrawnByUser(userB, tokenA, base.Add(-time.Hour), base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, "300", total.String())
 
 	// user + tokenB = 400
-	total, err = a.GetTotalWithdrawnByUser(user, tokenB, base.Add(-time.Hour))
+	total, err = a.GetTotalWithdrawnByUser(user, tokenB, base.Add(-time.Hour), base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, "400", total.String())
 
 	// userB + tokenB = 0 (no withdrawals)
-	total, err = a.GetTotalWithdrawnByUser(userB, tokenB, base.Add(-time.Hour))
+	total, err = a.GetTotalWithdrawnByUser(userB, tokenB, base.Add(-time.Hour), base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, 0, total.Sign())
 }
@@ -176,7 +252,469 @@ func TestGetTotalWithdrawn_LargeAmounts(t *testing.T) {
 	}
 	require.NoError(t, a.Save(w))
 
-	total, err := a.GetTotalWithdrawn(tokenA, base.Add(-time.Hour))
+	total, err := a.GetTotalWithdrawn(tokenA, base.Add(-time.Hour), base.Add(time.Hour))
 	require.NoError(t, err)
 	require.Equal(t, bigAmount.String(), total.String())
 }
+
+func TestRecordWithdrawEvent_AdvancesCursor(t *testing.T) {
+	a := newTestAdapter(t)
+
+	cursor, err := a.GetCursor("withdraw_started")
+	require.NoError(t, err)
+	require.Equal(t, custody.Cursor{}, cursor)
+
+	blockHash := common.HexToHash("0xb10c")
+	require.NoError(t, a.RecordWithdrawEvent(&WithdrawEventModel{
+		WithdrawalID: common.Hash{1}.Hex(),
+		UserAddress:  user.Hex(),
+		TokenAddress: tokenA.Hex(),
+		Amount:       "100",
+		Decision:     "approved",
+		BlockNumber:  42,
+		BlockHash:    blockHash.Hex(),
+		TxHash:       common.HexToHash("0xdeadbeef").Hex(),
+		LogIndex:     3,
+	}))
+
+	cursor, err = a.GetCursor("withdraw_started")
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), cursor.BlockNumber)
+	require.Equal(t, uint32(3), cursor.LogIndex)
+	require.Equal(t, blockHash, cursor.BlockHash)
+
+	// Recording an older event does not move the cursor backwards.
+	require.NoError(t, a.RecordWithdrawEvent(&WithdrawEventModel{
+		WithdrawalID: common.Hash{2}.Hex(),
+		UserAddress:  user.Hex(),
+		TokenAddress: tokenA.Hex(),
+		Amount:       "100",
+		Decision:     "approved",
+		BlockNumber:  42,
+		BlockHash:    blockHash.Hex(),
+		TxHash:       common.HexToHash("0xdeadbeef").Hex(),
+		LogIndex:     1,
+	}))
+	cursor, err = a.GetCursor("withdraw_started")
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), cursor.BlockNumber)
+	require.Equal(t, uint32(3), cursor.LogIndex)
+}
+
+func TestRecordWithdrawEvent_PerContractCursors(t *testing.T) {
+	a := newTestAdapter(t)
+	contractA := common.HexToAddress("0xC0").Hex()
+	contractB := common.HexToAddress("0xC1").Hex()
+
+	for i, ev := range []struct {
+		contract string
+		block    uint64
+	}{{contractA, 42}, {contractB, 10}} {
+		require.NoError(t, a.RecordWithdrawEvent(&WithdrawEventModel{
+			WithdrawalID:  common.Hash{byte(i + 1)}.Hex(),
+			Contract:      ev.contract,
+			ContractLabel: "custody",
+			UserAddress:   user.Hex(),
+			TokenAddress:  tokenA.Hex(),
+			Amount:        "100",
+			Decision:      "approved",
+			BlockNumber:   ev.block,
+			BlockHash:     common.BigToHash(new(big.Int).SetUint64(ev.block)).Hex(),
+			TxHash:        common.HexToHash("0xdeadbeef").Hex(),
+		}))
+	}
+
+	cursor, err := a.GetContractCursor("withdraw_started", contractA)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), cursor.BlockNumber)
+	cursor, err = a.GetContractCursor("withdraw_started", contractB)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), cursor.BlockNumber)
+	cursor, err = a.GetCursor("withdraw_started")
+	require.NoError(t, err)
+	require.Equal(t, custody.Cursor{}, cursor)
+
+	got, err := a.GetWithdrawEvent(common.Hash{1}.Hex())
+	require.NoError(t, err)
+	require.Equal(t, contractA, got.Contract)
+	require.Equal(t, "custody", got.ContractLabel)
+
+	// A reorg only rewinds cursors past the ancestor.
+	_, err = a.OrphanWithdrawEvents(30, custody.BlockRef{Number: 29})
+	require.NoError(t, err)
+	cursor, err = a.GetContractCursor("withdraw_started", contractA)
+	require.NoError(t, err)
+	require.Equal(t, uint64(29), cursor.BlockNumber)
+	require.Equal(t, uint32(math.MaxUint32), cursor.LogIndex)
+	cursor, err = a.GetContractCursor("withdraw_started", contractB)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), cursor.BlockNumber)
+}
+
+func TestNewAdapter_MigratesLegacyCursors(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	require.NoError(t, err)
+
+	type legacyCursor struct {
+		StreamName  string `gorm:"primaryKey;type:varchar(64)"`
+		BlockNumber uint64 `gorm:"not null"`
+		BlockHash   string `gorm:"type:varchar(66);not null;default:''"`
+		LogIndex    uint   `gorm:"not null"`
+		UpdatedAt   time.Time
+	}
+	require.NoError(t, db.Table("block_cursor_models").AutoMigrate(&legacyCursor{}))
+	require.NoError(t, db.Table("block_cursor_models").Create(&legacyCursor{
+		StreamName:  "withdraw_started",
+		BlockNumber: 42,
+		BlockHash:   common.HexToHash("0xb10c").Hex(),
+		LogIndex:    3,
+	}).Error)
+
+	a, err := NewAdapter(db)
+	require.NoError(t, err)
+
+	cursor, err := a.GetCursor("withdraw_started")
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), cursor.BlockNumber)
+	require.Equal(t, uint32(3), cursor.LogIndex)
+
+	contract := common.HexToAddress("0xC0").Hex()
+	require.NoError(t, a.RecordWithdrawEvent(&WithdrawEventModel{
+		WithdrawalID: common.Hash{1}.Hex(),
+		Contract:     contract,
+		UserAddress:  user.Hex(),
+		TokenAddress: tokenA.Hex(),
+		Amount:       "100",
+		Decision:     "approved",
+		BlockNumber:  50,
+		TxHash:       common.HexToHash("0xdeadbeef").Hex(),
+	}))
+	cursor, err = a.GetContractCursor("withdraw_started", contract)
+	require.NoError(t, err)
+	require.Equal(t, uint64(50), cursor.BlockNumber)
+}
+
+func TestOrphanWithdrawEvents(t *testing.T) {
+	a := newTestAdapter(t)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, block := range []uint64{10, 20, 30} {
+		id := common.Hash{byte(i + 1)}
+		require.NoError(t, a.RecordWithdrawEvent(&WithdrawEventModel{
+			WithdrawalID: id.Hex(),
+			UserAddress:  user.Hex(),
+			TokenAddress: tokenA.Hex(),
+			Amount:       "100",
+			Decision:     "approved",
+			BlockNumber:  block,
+			BlockHash:    common.BigToHash(new(big.Int).SetUint64(block)).Hex(),
+			TxHash:       id.Hex(),
+		}))
+		require.NoError(t, a.Save(&custody.Withdrawal{
+			WithdrawalID: id,
+			User:         user,
+			Token:        tokenA,
+			Amount:       big.NewInt(100),
+			BlockNumber:  block,
+			Timestamp:    base,
+		}))
+	}
+
+	refs, err := a.GetWithdrawEventBlocks(10)
+	require.NoError(t, err)
+	require.Len(t, refs, 3)
+	require.Equal(t, uint64(10), refs[0].Number)
+	require.Equal(t, uint64(30), refs[2].Number)
+
+	ancestor := custody.BlockRef{Number: 19, Hash: common.HexToHash("0x19")}
+	ids, err := a.OrphanWithdrawEvents(20, ancestor)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{common.Hash{2}.Hex(), common.Hash{3}.Hex()}, ids)
+
+	require.True(t, a.HasWithdrawEvent(common.Hash{1}.Hex()))
+	require.False(t, a.HasWithdrawEvent(common.Hash{2}.Hex()))
+	require.False(t, a.HasWithdrawEvent(common.Hash{3}.Hex()))
+
+	// Orphaned withdrawals no longer count towards limits.
+	total, err := a.GetTotalWithdrawn(tokenA, base.Add(-time.Hour), base.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, "100", total.String())
+
+	cursor, err := a.GetCursor("withdraw_started")
+	require.NoError(t, err)
+	require.Equal(t, ancestor.Number, cursor.BlockNumber)
+	require.Equal(t, ancestor.Hash, cursor.BlockHash)
+
+	refs, err = a.GetWithdrawEventBlocks(10)
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+
+	// A re-evaluated decision replaces the orphaned one.
+	require.NoError(t, a.RecordWithdrawEvent(&WithdrawEventModel{
+		WithdrawalID: common.Hash{2}.Hex(),
+		UserAddress:  user.Hex(),
+		TokenAddress: tokenA.Hex(),
+		Amount:       "100",
+		Decision:     "rejected",
+		BlockNumber:  21,
+		TxHash:       common.Hash{2}.Hex(),
+	}))
+	require.True(t, a.HasWithdrawEvent(common.Hash{2}.Hex()))
+}
+
+func TestSaveWithdrawIntent(t *testing.T) {
+	a := newTestAdapter(t)
+
+	id := common.Hash{1}.Hex()
+	intent := &WithdrawEventModel{
+		WithdrawalID: id,
+		UserAddress:  user.Hex(),
+		TokenAddress: tokenA.Hex(),
+		Amount:       "100",
+		Decision:     DecisionProcessing,
+		BlockNumber:  42,
+		BlockHash:    common.HexToHash("0xb10c").Hex(),
+		TxHash:       common.HexToHash("0xdeadbeef").Hex(),
+		Action:       ActionFinalize,
+		ActionTxHash: common.HexToHash("0xf1").Hex(),
+		ActionRawTx:  "0x01",
+	}
+	require.NoError(t, a.SaveWithdrawIntent(intent))
+
+	// An intent is not a decision and does not move the cursor.
+	require.False(t, a.HasWithdrawEvent(id))
+	cursor, err := a.GetCursor("withdraw_started")
+	require.NoError(t, err)
+	require.Equal(t, custody.Cursor{}, cursor)
+
+	got, err := a.GetWithdrawEvent(id)
+	require.NoError(t, err)
+	require.Equal(t, DecisionProcessing, got.Decision)
+	require.Equal(t, intent.ActionTxHash, got.ActionTxHash)
+	require.Equal(t, "0x01", got.ActionRawTx)
+
+	final := *intent
+	final.ID = 0
+	final.Decision = "approved"
+	require.NoError(t, a.RecordWithdrawEvent(&final))
+	require.True(t, a.HasWithdrawEvent(id))
+
+	got, err = a.GetWithdrawEvent(id)
+	require.NoError(t, err)
+	require.Equal(t, "approved", got.Decision)
+
+	// A final decision is never replaced by a later intent or decision.
+	require.NoError(t, a.SaveWithdrawIntent(intent))
+	final.Decision = "error"
+	require.NoError(t, a.RecordWithdrawEvent(&final))
+	got, err = a.GetWithdrawEvent(id)
+	require.NoError(t, err)
+	require.Equal(t, "approved", got.Decision)
+
+	missing, err := a.GetWithdrawEvent(common.Hash{2}.Hex())
+	require.NoError(t, err)
+	require.Nil(t, missing)
+}
+
+func TestHeldWithdrawEvents(t *testing.T) {
+	a := newTestAdapter(t)
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	held := func(id byte, deadline time.Time) *WithdrawEventModel {
+		return &WithdrawEventModel{
+			WithdrawalID:   common.Hash{id}.Hex(),
+			UserAddress:    user.Hex(),
+			TokenAddress:   tokenA.Hex(),
+			Amount:         "100",
+			Decision:       DecisionHeld,
+			BlockNumber:    uint64(id),
+			TxHash:         common.HexToHash("0xdeadbeef").Hex(),
+			ReviewDeadline: &deadline,
+		}
+	}
+	require.NoError(t, a.RecordWithdrawEvent(held(1, base.Add(2*time.Hour))))
+	require.NoError(t, a.RecordWithdrawEvent(held(2, base.Add(time.Hour))))
+	require.True(t, a.HasWithdrawEvent(common.Hash{1}.Hex()))
+
+	events, err := a.ListHeldWithdrawEvents()
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, common.Hash{2}.Hex(), events[0].WithdrawalID)
+	require.True(t, events[0].ReviewDeadline.Equal(base.Add(time.Hour)))
+
+	// A reviewed withdrawal goes through an intent to its final decision.
+	intent := held(2, base.Add(time.Hour))
+	intent.Decision = DecisionProcessing
+	intent.ReviewedBy = "alice"
+	intent.Action = ActionFinalize
+	require.NoError(t, a.SaveWithdrawIntent(intent))
+
+	events, err = a.ListHeldWithdrawEvents()
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	intents, err := a.ListReviewIntents()
+	require.NoError(t, err)
+	require.Len(t, intents, 1)
+	require.Equal(t, "alice", intents[0].ReviewedBy)
+
+	final := held(2, base.Add(time.Hour))
+	final.Decision = "approved"
+	require.NoError(t, a.RecordWithdrawEvent(final))
+	intents, err = a.ListReviewIntents()
+	require.NoError(t, err)
+	require.Empty(t, intents)
+}
+
+func TestGetCommittedAmount(t *testing.T) {
+	a := newTestAdapter(t)
+
+	contract := common.HexToAddress("0xcccccccccccccccccccccccccccccccccccccccc")
+	now := time.Now()
+	since := now.Add(-time.Hour)
+	event := func(id byte, decision, action, amount string) *WithdrawEventModel {
+		return &WithdrawEventModel{
+			WithdrawalID: common.Hash{id}.Hex(),
+			Contract:     contract.Hex(),
+			UserAddress:  user.Hex(),
+			TokenAddress: tokenA.Hex(),
+			Amount:       amount,
+			Decision:     decision,
+			BlockNumber:  uint64(id),
+			BlockTime:    now,
+			TxHash:       common.HexToHash("0xdeadbeef").Hex(),
+			Action:       action,
+		}
+	}
+	require.NoError(t, a.RecordWithdrawEvent(event(1, DecisionPending, ActionFinalize, "100")))
+	require.NoError(t, a.RecordWithdrawEvent(event(2, "approved", ActionFinalize, "1000")))
+	require.NoError(t, a.RecordWithdrawEvent(event(3, DecisionHeld, "", "1000")))
+	require.NoError(t, a.SaveWithdrawIntent(event(4, DecisionProcessing, ActionFinalize, "20")))
+	require.NoError(t, a.SaveWithdrawIntent(event(5, DecisionProcessing, ActionReject, "1000")))
+	other := event(6, DecisionPending, ActionFinalize, "1000")
+	other.TokenAddress = tokenB.Hex()
+	require.NoError(t, a.RecordWithdrawEvent(other))
+	expired := event(7, DecisionPending, ActionFinalize, "1000")
+	expired.BlockTime = since.Add(-time.Second)
+	require.NoError(t, a.RecordWithdrawEvent(expired))
+	require.NoError(t, a.RecordWithdrawEvent(event(8, DecisionPending, ActionFinalize, "1000")))
+
+	pending, err := a.ListPendingWithdrawEvents(contract)
+	require.NoError(t, err)
+	require.Len(t, pending, 4)
+	for i, id := range []byte{1, 6, 7, 8} {
+		require.Equal(t, common.Hash{id}.Hex(), pending[i].WithdrawalID)
+	}
+
+	committed, err := a.GetCommittedAmount(contract, tokenA, "", since)
+	require.NoError(t, err)
+	require.Equal(t, "1120", committed.String(), "the expired approval does not count")
+
+	// Other signers finalized withdrawal 8 on-chain.
+	require.NoError(t, a.CompletePendingWithdrawEvent(common.Hash{8}.Hex()))
+	ev, err := a.GetWithdrawEvent(common.Hash{8}.Hex())
+	require.NoError(t, err)
+	require.Equal(t, "approved", ev.Decision)
+
+	committed, err = a.GetCommittedAmount(contract, tokenA, "", since)
+	require.NoError(t, err)
+	require.Equal(t, "120", committed.String(), "the finalized approval does not count")
+
+	committed, err = a.GetCommittedAmount(contract, tokenA, "", time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, "1120", committed.String(), "approvals do not expire without a cutoff")
+
+	committed, err = a.GetCommittedAmount(contract, tokenA, common.Hash{4}.Hex(), since)
+	require.NoError(t, err)
+	require.Equal(t, "100", committed.String())
+
+	committed, err = a.GetCommittedAmount(common.HexToAddress("0xdd"), tokenA, "", since)
+	require.NoError(t, err)
+	require.Equal(t, "0", committed.String())
+}
+
+func TestDeadLetters(t *testing.T) {
+	a := newTestAdapter(t)
+
+	log := types.Log{
+		Address:     common.HexToAddress("0xC0C0"),
+		Topics:      []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")},
+		Data:        []byte{0xde, 0xad},
+		BlockNumber: 42,
+		BlockHash:   common.HexToHash("0xb10c"),
+		TxHash:      common.HexToHash("0x7a"),
+		Index:       3,
+	}
+	require.NoError(t, a.SaveDeadLetter("withdraw-started", log, "abi mismatch"))
+	// The same log is stored once.
+	require.NoError(t, a.SaveDeadLetter("withdraw-started", log, "abi mismatch"))
+
+	letters, err := a.ListDeadLetters(false)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	require.Equal(t, "withdraw-started", letters[0].Stream)
+	require.Equal(t, "abi mismatch", letters[0].Reason)
+	require.Equal(t, log, letters[0].Log())
+
+	require.NoError(t, a.MarkDeadLetterReplayed(letters[0].ID))
+	letters, err = a.ListDeadLetters(false)
+	require.NoError(t, err)
+	require.Empty(t, letters)
+
+	letter, err := a.GetDeadLetter(1)
+	require.NoError(t, err)
+	require.True(t, letter.Replayed)
+
+	letter, err = a.GetDeadLetter(99)
+	require.NoError(t, err)
+	require.Nil(t, letter)
+}
+
+func TestUserTiers(t *testing.T) {
+	a := newTestAdapter(t)
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	tier, err := a.UserTier(user)
+	require.NoError(t, err)
+	require.Empty(t, tier)
+
+	require.NoError(t, a.SetUserTier(user, "retail"))
+	require.NoError(t, a.SetUserTier(other, "market_maker"))
+	require.NoError(t, a.SetUserTier(user, "vip"))
+	tier, err = a.UserTier(user)
+	require.NoError(t, err)
+	require.Equal(t, "vip", tier)
+
+	tiers, err := a.ListUserTiers()
+	require.NoError(t, err)
+	require.Len(t, tiers, 2)
+
+	require.NoError(t, a.SetUserTier(user, ""))
+	tier, err = a.UserTier(user)
+	require.NoError(t, err)
+	require.Empty(t, tier)
+	tiers, err = a.ListUserTiers()
+	require.NoError(t, err)
+	require.Len(t, tiers, 1)
+	require.Equal(t, other.Hex(), tiers[0].UserAddress)
+}
+
+func TestPause(t *testing.T) {
+	a := newTestAdapter(t)
+
+	p, err := a.GetPause()
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, a.SavePause(&PauseModel{Paused: true, Reason: "incident", ChangedBy: "alice", ChangedAt: at}))
+	require.NoError(t, a.SavePause(&PauseModel{Paused: false, ChangedBy: "bob", ChangedAt: at.Add(time.Hour)}))
+
+	p, err = a.GetPause()
+	require.NoError(t, err)
+	require.False(t, p.Paused)
+	require.Empty(t, p.Reason)
+	require.Equal(t, "bob", p.ChangedBy)
+	require.True(t, p.ChangedAt.Equal(at.Add(time.Hour)))
+}
@@ -0,0 +1,211 @@
+// Package policy decides what happens to a withdrawal request by evaluating
+// rules against it. Each rule of a Policy carries the action taken when the
+// withdrawal violates it: reject it, hold it for manual review, or only log
+// the violation. Rules can be composed with AllOf and AnyOf.
+//
+// The worker's built-in limit checks are rules like any other; additional
+// rules are registered with service.WithRule.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Request is a withdrawal being evaluated.
+type Request struct {
+	// Chain is the name of the chain the withdrawal was requested on, empty
+	// when a single unnamed chain is configured.
+	Chain        string
+	Contract     common.Address
+	WithdrawalID [32]byte
+	User         common.Address
+	Token        common.Address
+	Amount       *big.Int
+	// Time is the chain time the withdrawal was requested at.
+	Time time.Time
+
+	annotations map[string]any
+}
+
+// Annotate attaches a value a rule based its verdict on, e.g. the prices it
+// used, so that it can be recorded with the decision.
+func (r *Request) Annotate(key string, value any) {
+	if r.annotations == nil {
+		r.annotations = make(map[string]any)
+	}
+	r.annotations[key] = value
+}
+
+// Annotation returns the value attached under key, or nil.
+func (r *Request) Annotation(key string) any {
+	return r.annotations[key]
+}
+
+// Rule is a condition a withdrawal must meet.
+type Rule interface {
+	// Name identifies the rule in logs and recorded decisions. Names of
+	// custom rules are recorded as reason codes and should be short.
+	Name() string
+	// Check returns nil if the withdrawal meets the rule and an error
+	// describing the violation otherwise. A rule that cannot be evaluated,
+	// e.g. because a data source failed, should return an error too.
+	Check(req *Request) error
+}
+
+type ruleFunc struct {
+	name  string
+	check func(req *Request) error
+}
+
+func (r ruleFunc) Name() string             { return r.name }
+func (r ruleFunc) Check(req *Request) error { return r.check(req) }
+
+// NewRule returns a Rule that calls check.
+func NewRule(name string, check func(req *Request) error) Rule {
+	return ruleFunc{name: name, check: check}
+}
+
+// AllOf returns a rule met when every one of rules is met. Rules are checked
+// in order and the first violation is returned.
+func AllOf(name string, rules ...Rule) Rule {
+	return NewRule(name, func(req *Request) error {
+		for _, rule := range rules {
+			if err := rule.Check(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AnyOf returns a rule met when at least one of rules is met. If none is,
+// the violation joins the errors of all of them.
+func AnyOf(name string, rules ...Rule) Rule {
+	return NewRule(name, func(req *Request) error {
+		var errs []error
+		for _, rule := range rules {
+			err := rule.Check(req)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", rule.Name(), err))
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// Action is what a Policy does when a withdrawal violates a rule. Actions are
+// ordered by severity.
+type Action int
+
+const (
+	// ActionLog only logs the violation; the withdrawal proceeds.
+	ActionLog Action = iota + 1
+	// ActionHold leaves the withdrawal to an operator to approve or reject.
+	ActionHold
+	// ActionReject rejects the withdrawal.
+	ActionReject
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionLog:
+		return "log"
+	case ActionHold:
+		return "hold"
+	case ActionReject:
+		return "reject"
+	default:
+		return "none"
+	}
+}
+
+// ParseAction parses the name of an action as returned by Action.String.
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(s) {
+	case "log":
+		return ActionLog, nil
+	case "hold":
+		return ActionHold, nil
+	case "reject":
+		return ActionReject, nil
+	default:
+		return 0, fmt.Errorf("unknown policy action %q (must be reject, hold or log)", s)
+	}
+}
+
+// Policy is an ordered list of rules with the action taken on violation.
+type Policy struct {
+	entries []entry
+}
+
+type entry struct {
+	rule   Rule
+	action Action
+}
+
+// New returns an empty Policy, which allows every withdrawal.
+func New() *Policy {
+	return &Policy{}
+}
+
+// Add appends a rule to the policy.
+func (p *Policy) Add(rule Rule, action Action) *Policy {
+	p.entries = append(p.entries, entry{rule: rule, action: action})
+	return p
+}
+
+// Violation is a rule a withdrawal did not meet.
+type Violation struct {
+	Rule   string
+	Action Action
+	Err    error
+}
+
+// Decision is the outcome of evaluating a Policy.
+type Decision struct {
+	// Action is the most severe action among the violations, or zero if
+	// there are none.
+	Action     Action
+	Violations []Violation
+}
+
+// Violation returns the first violation that determined the action, or nil
+// if there is none.
+func (d Decision) Violation() *Violation {
+	for i := range d.Violations {
+		if d.Violations[i].Action == d.Action {
+			return &d.Violations[i]
+		}
+	}
+	return nil
+}
+
+// Evaluate checks the rules in order and returns the resulting decision.
+// Evaluation stops at the first rule whose violation rejects the withdrawal.
+func (p *Policy) Evaluate(req *Request) Decision {
+	var d Decision
+	for _, e := range p.entries {
+		err := e.rule.Check(req)
+		if err == nil {
+			continue
+		}
+		d.Violations = append(d.Violations, Violation{Rule: e.rule.Name(), Action: e.action, Err: err})
+		if e.action > d.Action {
+			d.Action = e.action
+		}
+		if e.action == ActionReject {
+			break
+		}
+	}
+	return d
+}
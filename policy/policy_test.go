@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errViolated = errors.New("violated")
+
+func pass(name string) Rule {
+	return NewRule(name, func(*Request) error { return nil })
+}
+
+func fail(name string) Rule {
+	return NewRule(name, func(*Request) error { return errViolated })
+}
+
+func TestAllOf(t *testing.T) {
+	req := &Request{Amount: big.NewInt(1)}
+	assert.NoError(t, AllOf("all", pass("a"), pass("b")).Check(req))
+	assert.ErrorIs(t, AllOf("all", pass("a"), fail("b")).Check(req), errViolated)
+	assert.NoError(t, AllOf("all").Check(req))
+}
+
+func TestAnyOf(t *testing.T) {
+	req := &Request{Amount: big.NewInt(1)}
+	assert.NoError(t, AnyOf("any", fail("a"), pass("b")).Check(req))
+	err := AnyOf("any", fail("a"), fail("b")).Check(req)
+	require.ErrorIs(t, err, errViolated)
+	assert.Contains(t, err.Error(), "a: violated")
+	assert.Contains(t, err.Error(), "b: violated")
+	assert.NoError(t, AnyOf("any").Check(req))
+}
+
+func TestEvaluate(t *testing.T) {
+	req := &Request{Amount: big.NewInt(1)}
+
+	d := New().Add(pass("a"), ActionReject).Evaluate(req)
+	assert.Equal(t, Action(0), d.Action)
+	assert.Nil(t, d.Violation())
+
+	// The most severe action wins; the first violation with it is reported.
+	d = New().
+		Add(fail("logged"), ActionLog).
+		Add(fail("held"), ActionHold).
+		Add(fail("held_too"), ActionHold).
+		Evaluate(req)
+	assert.Equal(t, ActionHold, d.Action)
+	assert.Len(t, d.Violations, 3)
+	assert.Equal(t, "held", d.Violation().Rule)
+
+	// Evaluation stops at the first rejection.
+	checked := false
+	d = New().
+		Add(fail("rejected"), ActionReject).
+		Add(NewRule("after", func(*Request) error { checked = true; return nil }), ActionLog).
+		Evaluate(req)
+	assert.Equal(t, ActionReject, d.Action)
+	assert.Equal(t, "rejected", d.Violation().Rule)
+	assert.False(t, checked)
+}
+
+func TestAnnotations(t *testing.T) {
+	req := &Request{}
+	assert.Nil(t, req.Annotation("k"))
+	req.Annotate("k", 1)
+	assert.Equal(t, 1, req.Annotation("k"))
+}
+
+func TestParseAction(t *testing.T) {
+	for _, a := range []Action{ActionLog, ActionHold, ActionReject} {
+		parsed, err := ParseAction(a.String())
+		require.NoError(t, err)
+		assert.Equal(t, a, parsed)
+	}
+	_, err := ParseAction("approve")
+	assert.Error(t, err)
+}
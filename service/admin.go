@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/layer-3/nitewatch/custody"
+	"github.com/layer-3/nitewatch/internal/store"
+)
+
+var (
+	// ErrDeadLetterNotFound is returned when replaying a dead letter that does not exist.
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrUnknownChain is returned when an admin request names a chain that is
+	// not configured, or names none while several are.
+	ErrUnknownChain = errors.New("unknown chain")
+)
+
+// registerAdminRoutes mounts the operator API under /admin. The routes require
+// the configured admin token as a bearer token and are not served at all when
+// no token is configured.
+func (svc *Service) registerAdminRoutes(engine *gin.Engine) {
+	if svc.Config.AdminToken == "" {
+		return
+	}
+	admin := engine.Group("/admin", svc.requireAdminToken)
+	admin.GET("/dead-letters", svc.handleListDeadLetters)
+	admin.POST("/dead-letters/:id/replay", svc.handleReplayDeadLetter)
+	admin.GET("/holds", svc.handleListHolds)
+	admin.POST("/holds/:id/approve", svc.handleApproveHold)
+	admin.POST("/holds/:id/reject", svc.handleRejectHold)
+	admin.GET("/pause", svc.handleGetPause)
+	admin.POST("/pause", svc.handlePause)
+	admin.POST("/resume", svc.handleResume)
+	admin.GET("/tiers", svc.handleListTiers)
+	admin.PUT("/tiers/:user", svc.handleSetTier)
+	admin.DELETE("/tiers/:user", svc.handleDeleteTier)
+}
+
+func (svc *Service) requireAdminToken(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(svc.Config.AdminToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	c.Next()
+}
+
+// DeadLetter is the API representation of a log the listener could not decode.
+type DeadLetter struct {
+	Chain       string    `json:"chain"`
+	ID          uint64    `json:"id"`
+	Stream      string    `json:"stream"`
+	Contract    string    `json:"contract"`
+	BlockNumber uint64    `json:"block_number"`
+	BlockHash   string    `json:"block_hash"`
+	TxHash      string    `json:"tx_hash"`
+	LogIndex    uint      `json:"log_index"`
+	Topics      []string  `json:"topics"`
+	Data        string    `json:"data"`
+	Reason      string    `json:"reason"`
+	Replayed    bool      `json:"replayed"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newDeadLetter(chain string, m *store.DeadLetterModel) DeadLetter {
+	topics := []string{}
+	if m.Topics != "" {
+		topics = strings.Split(m.Topics, ",")
+	}
+	return DeadLetter{
+		Chain:       chain,
+		ID:          m.ID,
+		Stream:      m.Stream,
+		Contract:    m.Contract,
+		BlockNumber: m.BlockNumber,
+		BlockHash:   m.BlockHash,
+		TxHash:      m.TxHash,
+		LogIndex:    m.LogIndex,
+		Topics:      topics,
+		Data:        m.Data,
+		Reason:      m.Reason,
+		Replayed:    m.Replayed,
+		CreatedAt:   m.CreatedAt,
+	}
+}
+
+func (svc *Service) handleListDeadLetters(c *gin.Context) {
+	resp := []DeadLetter{}
+	for _, ch := range svc.chains {
+		letters, err := ch.store.ListDeadLetters(c.Query("all") == "true")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range letters {
+			resp = append(resp, newDeadLetter(ch.name, &letters[i]))
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (svc *Service) handleReplayDeadLetter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dead letter id"})
+		return
+	}
+	event, err := svc.ReplayDeadLetter(c.Request.Context(), c.Query("chain"), id)
+	switch {
+	case errors.Is(err, ErrDeadLetterNotFound), errors.Is(err, ErrUnknownChain):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"id": id, "event": fmt.Sprintf("%T", event), "status": "replayed"})
+	}
+}
+
+// ReplayDeadLetter decodes a dead-lettered log again, typically after the
+// contract bindings were updated, and processes it as if the listener had
+// delivered it. A withdrawal request goes through the same checks and
+// exactly-once bookkeeping as one from the live stream; other events are not
+// acted upon by the worker and are only marked replayed. The dead letter is
+// left untouched if it still cannot be decoded or processing fails.
+// chainName may be empty if only one chain is configured.
+func (svc *Service) ReplayDeadLetter(ctx context.Context, chainName string, id uint64) (custody.Event, error) {
+	ch := svc.chainNamed(chainName)
+	if ch == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownChain, chainName)
+	}
+	return ch.replayDeadLetter(ctx, id)
+}
+
+func (ch *chain) replayDeadLetter(ctx context.Context, id uint64) (custody.Event, error) {
+	letter, err := ch.store.GetDeadLetter(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dead letter: %w", err)
+	}
+	if letter == nil {
+		return nil, fmt.Errorf("%w: %d", ErrDeadLetterNotFound, id)
+	}
+	logger := ch.logger.With("dead_letter", id, "stream", letter.Stream,
+		"block_number", letter.BlockNumber, "tx_hash", letter.TxHash, "log_index", letter.LogIndex)
+
+	log := letter.Log()
+	header, err := ch.ethClient.HeaderByNumber(ctx, new(big.Int).SetUint64(log.BlockNumber))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block header: %w", err)
+	}
+	if header.Hash() != log.BlockHash {
+		return nil, fmt.Errorf("block %d is no longer canonical", log.BlockNumber)
+	}
+	event, err := ch.listener.DecodeLog(log, time.Unix(int64(header.Time), 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode log: %w", err)
+	}
+
+	if withdrawal, ok := event.(*custody.WithdrawStartedEvent); ok {
+		ch.txMu.Lock()
+		err := ch.processWithdrawal(ctx, withdrawal)
+		ch.txMu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("failed to process withdrawal: %w", err)
+		}
+	}
+
+	if err := ch.store.MarkDeadLetterReplayed(id); err != nil {
+		return nil, fmt.Errorf("failed to mark dead letter replayed: %w", err)
+	}
+	logger.Info("Replayed dead letter", "event", fmt.Sprintf("%T", event))
+	return event, nil
+}
@@ -0,0 +1,924 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/layer-3/nitewatch/config"
+	"github.com/layer-3/nitewatch/custody"
+	"github.com/layer-3/nitewatch/internal/checker"
+	"github.com/layer-3/nitewatch/internal/store"
+	"github.com/layer-3/nitewatch/policy"
+)
+
+// chain is the withdrawal pipeline of one chain: it watches the chain's
+// custody contracts, enforces its limits and keeps its decisions and cursors
+// in its own store. Chains of a Service run independently of each other.
+type chain struct {
+	svc  *Service
+	name string
+	conf config.BlockchainConfig
+
+	logger    *slog.Logger
+	ethClient custody.EthBackend
+	contracts []*custodyContract
+	listener  eventListener
+	checker   *checker.Checker
+	store     *store.Adapter
+
+	// policy decides on withdrawals with the rules of checker and the custom
+	// rules of the service; builtinRules holds the names of the former.
+	policy       *policy.Policy
+	builtinRules map[string]bool
+
+	// txMu serializes the code paths that send custody transactions so that
+	// they do not race for the signer's nonce.
+	txMu sync.Mutex
+	// wake makes the held withdrawal processor run without waiting for its
+	// next tick.
+	wake chan struct{}
+}
+
+func newChain(svc *Service, conf config.ChainConfig, client custody.EthBackend) (*chain, error) {
+	if conf.Blockchain.ConfirmationBlocks == 0 {
+		return nil, fmt.Errorf("confirmation_blocks must be > 0")
+	}
+
+	logger := svc.Logger
+	if conf.Name != "" {
+		logger = logger.With("chain", conf.Name)
+	}
+
+	gormDB, err := gorm.Open(sqlite.Open(conf.DBPath), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db, err := store.NewAdapter(gormDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	chainID, err := client.ChainID(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	var contracts []*custodyContract
+	for _, cc := range conf.Blockchain.CustodyContracts() {
+		pk := strings.TrimPrefix(cc.PrivateKey, "0x")
+		key, err := crypto.HexToECDSA(pk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key of contract %s: %w", cc.Label, err)
+		}
+
+		auth, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create transactor: %w", err)
+		}
+
+		addr := common.HexToAddress(cc.Address)
+
+		// Verify that the configured private key's address is authorized on the
+		// contract before starting. This catches misconfiguration early rather
+		// than failing on the first finalizeWithdraw/rejectWithdraw call.
+		if err := verifySigner(client, addr, auth.From, logger.With("label", cc.Label)); err != nil {
+			return nil, err
+		}
+
+		withdrawContract, err := custody.NewIWithdraw(addr, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to bind IWithdraw contract: %w", err)
+		}
+		expiry := cc.OperationExpiry
+		if expiry == 0 {
+			if expiry, err = operationExpiry(client, addr, logger.With("label", cc.Label)); err != nil {
+				return nil, fmt.Errorf("failed to get operation expiry of contract %s: %w", cc.Label, err)
+			}
+		}
+		if expiry > 0 && expiry <= svc.Config.Review.Margin {
+			return nil, fmt.Errorf("operation expiry of contract %s (%s) must exceed review.margin (%s)",
+				cc.Label, expiry, svc.Config.Review.Margin)
+		}
+		contracts = append(contracts, &custodyContract{
+			label:      cc.Label,
+			address:    addr,
+			contract:   withdrawContract,
+			auth:       auth,
+			startBlock: cc.StartBlock,
+			expiry:     expiry,
+		})
+	}
+
+	listenerOpts := []custody.ListenerOption{
+		custody.WithName(conf.Name),
+		custody.WithConfirmationMode(custody.ConfirmationMode(conf.Blockchain.ConfirmationMode)),
+		// The worker commits every event itself; see processWithdrawal.
+		custody.WithAcknowledgements(),
+		custody.WithBackfillConcurrency(conf.Blockchain.BackfillConcurrency),
+		custody.WithDeadLetterStore(db),
+	}
+	if conf.Blockchain.ListenMode != config.ListenModePoll {
+		listenerOpts = append(listenerOpts, custody.WithHeadSubscription())
+	}
+	for _, c := range contracts[1:] {
+		listenerOpts = append(listenerOpts, custody.WithContracts(c.address))
+	}
+	listener := custody.NewListener(client, contracts[0].address, conf.Blockchain.ConfirmationBlocks, conf.Blockchain.PollInterval, contracts[0].contract, nil, listenerOpts...)
+
+	return &chain{
+		svc:       svc,
+		name:      conf.Name,
+		conf:      conf.Blockchain,
+		logger:    logger,
+		ethClient: client,
+		contracts: contracts,
+		listener:  listener,
+		store:     db,
+		wake:      make(chan struct{}, 1),
+	}, nil
+}
+
+// stream scopes a stream name to the chain in metrics and health reports.
+func (ch *chain) stream(name string) string {
+	if ch.name == "" {
+		return name
+	}
+	return ch.name + "/" + name
+}
+
+// eventListener is the part of custody.Listener a chain uses.
+type eventListener interface {
+	WatchWithdrawStarted(ctx context.Context, sink chan<- *custody.WithdrawStartedEvent, reorgs chan<- *custody.ReorgEvent, from custody.Cursor) error
+	DecodeLog(log types.Log, blockTime time.Time) (custody.Event, error)
+}
+
+// custodyContract is a custody contract the worker enforces limits over,
+// with the transactor its finalize and reject transactions are signed by.
+type custodyContract struct {
+	label      string
+	address    common.Address
+	contract   *custody.IWithdraw
+	auth       *bind.TransactOpts
+	startBlock uint64
+	// expiry is how long after a request the contract accepts finalizing
+	// it, 0 if it does not expire.
+	expiry time.Duration
+}
+
+// operationExpiry reads OPERATION_EXPIRY() of a custody contract. Contracts
+// that do not expose it, i.e. revert the call, are assumed not to expire;
+// other errors are returned.
+func operationExpiry(client custody.EthBackend, addr common.Address, logger *slog.Logger) (time.Duration, error) {
+	caller, err := custody.NewQuorumCustodyCaller(addr, client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to bind QuorumCustody caller: %w", err)
+	}
+	expiry, err := caller.OPERATIONEXPIRY(&bind.CallOpts{})
+	if err != nil {
+		if !isContractRevert(err) {
+			return 0, err
+		}
+		logger.Warn("Custody contract has no OPERATION_EXPIRY, assuming withdrawals do not expire; set operation_expiry otherwise")
+		return 0, nil
+	}
+	if !expiry.IsInt64() || expiry.Int64() > int64(math.MaxInt64/time.Second) {
+		return 0, fmt.Errorf("OPERATION_EXPIRY out of range: %s", expiry)
+	}
+	return time.Duration(expiry.Int64()) * time.Second, nil
+}
+
+// withdrawStartedStream is the cursor stream of processed withdrawal requests.
+const withdrawStartedStream = "withdraw_started"
+
+// Restart policy of the event listener: a failed stream is restarted after a
+// back-off that doubles from minRestartDelay up to maxRestartDelay. A stream
+// that ran for listenerStableAfter is considered recovered, which clears the
+// degraded health state and resets the back-off and the restart limit.
+var (
+	minRestartDelay     = 5 * time.Second
+	maxRestartDelay     = 5 * time.Minute
+	listenerStableAfter = 5 * time.Minute
+)
+
+// superviseWithdrawStream runs the WithdrawStarted stream and restarts it from
+// the persisted cursor whenever the listener fails. While the stream is down
+// or has not yet run stably after a failure, the worker reports itself as
+// degraded. It returns an error once listener_max_restarts consecutive
+// restarts have failed, which stops the worker.
+func (ch *chain) superviseWithdrawStream(ctx context.Context) error {
+	stream := ch.stream(withdrawStartedStream)
+	logger := ch.logger.With("stream", stream)
+	maxRestarts := ch.conf.ListenerMaxRestarts
+
+	restarts := 0
+	for {
+		listenerUpGauge.WithLabelValues(stream).Set(1)
+		err := ch.runWithdrawStream(ctx, func() {
+			if restarts > 0 {
+				logger.Info("Event listener recovered", "restarts", restarts)
+			}
+			restarts = 0
+			ch.svc.setHealthy(stream)
+		})
+		listenerUpGauge.WithLabelValues(stream).Set(0)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			err = errors.New("listener stopped unexpectedly")
+		}
+		ch.svc.setDegraded(stream, err)
+
+		if maxRestarts > 0 && restarts >= maxRestarts {
+			logger.Error("Event ingestion stopped, restart limit reached", "error", err, "restarts", restarts)
+			return fmt.Errorf("%s listener failed after %d restarts: %w", stream, restarts, err)
+		}
+
+		delay := min(minRestartDelay<<min(restarts, 10), maxRestartDelay)
+		logger.Error("Event ingestion stopped, restarting listener", "error", err, "restart", restarts+1, "retry_in", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil
+		}
+		restarts++
+		listenerRestartsCounter.WithLabelValues(stream).Inc()
+	}
+}
+
+// runWithdrawStream watches WithdrawStarted events from the persisted cursor
+// until ctx is cancelled or the listener fails. onStable is called once the
+// stream has been running for listenerStableAfter.
+func (ch *chain) runWithdrawStream(ctx context.Context, onStable func()) error {
+	from, err := ch.withdrawCursor()
+	if err != nil {
+		return fmt.Errorf("failed to read withdraw_started cursors: %w", err)
+	}
+	if from.BlockHash != (common.Hash{}) {
+		// Let the listener trace a reorg that happened while we were down
+		// back to the last block we processed that is still canonical.
+		from.History, err = ch.store.GetWithdrawEventBlocks(reorgHistoryDepth)
+		if err != nil {
+			ch.logger.Warn("Failed to read processed block history", "error", err)
+		}
+	}
+
+	ch.logger.Info("Starting WithdrawStarted event watcher", "from_block", from.BlockNumber, "from_log_index", from.LogIndex,
+		"contracts", len(ch.contracts), "confirmation_mode", ch.conf.ConfirmationMode)
+	withdrawals := make(chan *custody.WithdrawStartedEvent)
+	reorgs := make(chan *custody.ReorgEvent)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- ch.listener.WatchWithdrawStarted(ctx, withdrawals, reorgs, from)
+	}()
+
+	stable := time.After(listenerStableAfter)
+	for {
+		select {
+		case event, ok := <-withdrawals:
+			if !ok {
+				return <-listenErr
+			}
+			ch.txMu.Lock()
+			err := ch.processWithdrawal(ctx, event)
+			ch.txMu.Unlock()
+			event.Ack(err)
+		case reorg, ok := <-reorgs:
+			if !ok {
+				reorgs = nil
+				continue
+			}
+			ch.processReorg(reorg)
+		case <-stable:
+			onStable()
+		}
+	}
+}
+
+// processWithdrawal decides on a WithdrawStarted event and returns nil once
+// the outcome is durably recorded, which commits the event in the listener.
+// A non-nil error leaves the event uncommitted so that it is redelivered.
+//
+// Together with the listener's at-least-once delivery this gives every
+// withdrawal exactly one effect:
+//   - the decision and the withdraw_started cursor are written in one
+//     transaction, and events with a final decision are skipped;
+//   - before a finalize or reject transaction is broadcast it is signed and
+//     persisted as a processing intent, so a redelivered event resumes the
+//     transaction already sent instead of sending another one;
+//   - the custody contract rejects a second approval or finalization of the
+//     same withdrawal, which bounds the damage if a resumed intent is given
+//     up on while its transaction was still pending.
+func (ch *chain) processWithdrawal(ctx context.Context, event *custody.WithdrawStartedEvent) error {
+	c := ch.custodyAt(event.Contract)
+	if c == nil {
+		return fmt.Errorf("withdrawal from unknown custody contract %s", event.Contract.Hex())
+	}
+	if ch.checker.HasCrossChainLimit(event.Token) {
+		ch.svc.crossChainMu.Lock()
+		defer ch.svc.crossChainMu.Unlock()
+	}
+	wID := common.Hash(event.WithdrawalID).Hex()
+	logger := ch.logger.With(
+		"contract", c.label,
+		"withdrawal_id", wID,
+		"user", event.User.Hex(),
+		"token", event.Token.Hex(),
+		"amount", event.Amount,
+	)
+
+	existing, err := ch.store.GetWithdrawEvent(wID)
+	if err != nil {
+		return fmt.Errorf("failed to look up withdraw event %s: %w", wID, err)
+	}
+	if existing != nil && existing.Decision != store.DecisionProcessing && existing.Decision != store.DecisionOrphaned {
+		logger.Info("Event already processed, skipping")
+		return nil
+	}
+
+	baseModel := store.WithdrawEventModel{
+		WithdrawalID:  wID,
+		Contract:      c.address.Hex(),
+		ContractLabel: c.label,
+		UserAddress:   event.User.Hex(),
+		TokenAddress:  event.Token.Hex(),
+		Amount:        event.Amount.String(),
+		BlockNumber:   event.BlockNumber,
+		BlockHash:     event.BlockHash.Hex(),
+		BlockTime:     eventTime(event),
+		TxHash:        event.TxHash.Hex(),
+		LogIndex:      uint(event.LogIndex),
+	}
+
+	if existing != nil && existing.Decision == store.DecisionProcessing {
+		tx, receipt, err := ch.recoverInFlight(ctx, logger, existing)
+		if err != nil {
+			return err
+		}
+		if receipt != nil {
+			logger.Info("Recovered in-flight transaction", "action", existing.Action, "tx_hash", tx.Hash().Hex())
+			baseModel.Action = existing.Action
+			baseModel.ActionTxHash = existing.ActionTxHash
+			baseModel.ActionRawTx = existing.ActionRawTx
+			baseModel.PriceSnapshot = existing.PriceSnapshot
+			baseModel.AnomalyStats = existing.AnomalyStats
+			baseModel.ReasonCode = existing.ReasonCode
+			if existing.Action == store.ActionReject {
+				return ch.completeReject(logger, &baseModel, existing.Reason, receipt)
+			}
+			return ch.completeFinalize(logger, c, event, &baseModel, tx, receipt)
+		}
+		logger.Warn("In-flight transaction was dropped, re-evaluating withdrawal", "action", existing.Action, "tx_hash", existing.ActionTxHash)
+	}
+
+	logger.Info("Processing withdrawal request")
+	return ch.decide(ctx, logger, c, event, &baseModel)
+}
+
+// decide evaluates a withdrawal against the policy and acts on the decision.
+// Withdrawals the policy allows are held instead of finalized while the
+// worker is paused, or if finalizing them trips the circuit breaker.
+func (ch *chain) decide(ctx context.Context, logger *slog.Logger, c *custodyContract, event *custody.WithdrawStartedEvent, baseModel *store.WithdrawEventModel) error {
+	// Limits are evaluated at the time the withdrawal was requested on-chain, so
+	// the decision does not depend on when the event is processed.
+	req := &policy.Request{
+		Chain:        ch.name,
+		Contract:     c.address,
+		WithdrawalID: event.WithdrawalID,
+		User:         event.User,
+		Token:        event.Token,
+		Amount:       event.Amount,
+		Time:         eventTime(event),
+	}
+	decision := ch.policy.Evaluate(req)
+	ch.reportLiquidity(logger, req)
+	if prices, ok := req.Annotation(checker.AnnotationPrices).(*checker.PriceSnapshot); ok {
+		snapshot, err := encodePriceSnapshot(prices)
+		if err != nil {
+			return fmt.Errorf("failed to encode price snapshot: %w", err)
+		}
+		baseModel.PriceSnapshot = snapshot
+	}
+	if stats, ok := req.Annotation(checker.AnnotationAnomalyStats).(*checker.AnomalyStats); ok {
+		encoded, err := encodeAnomalyStats(stats)
+		if err != nil {
+			return fmt.Errorf("failed to encode anomaly statistics: %w", err)
+		}
+		baseModel.AnomalyStats = encoded
+	}
+	for _, v := range decision.Violations {
+		if v.Action == policy.ActionLog {
+			logger.Warn("Withdrawal violates policy rule, allowing", "rule", v.Rule, "reason", v.Err)
+		}
+	}
+
+	switch decision.Action {
+	case policy.ActionReject, policy.ActionHold:
+		v := decision.Violation()
+		reason := v.Err
+		if ch.builtinRules[v.Rule] {
+			baseModel.ReasonCode = checker.ReasonCode(reason)
+		} else {
+			baseModel.ReasonCode = v.Rule
+			reason = fmt.Errorf("%s: %w", v.Rule, reason)
+		}
+		if decision.Action == policy.ActionHold {
+			return ch.hold(logger, c, baseModel, reason)
+		}
+		ch.svc.countRejection()
+		return ch.reject(ctx, logger, c, event, baseModel, reason)
+	}
+
+	if pause := ch.svc.Paused(); pause.Paused {
+		return ch.holdPaused(logger, c, baseModel, pause.Reason)
+	}
+	trip, err := ch.outflowTrip(ctx, event)
+	if errors.Is(err, errOutflowUnavailable) {
+		baseModel.ReasonCode = ReasonOutflowUnavailable
+		return ch.hold(logger, c, baseModel, err)
+	}
+	if err != nil {
+		return err
+	}
+	if trip != "" {
+		ch.svc.trip(trip)
+		return ch.holdPaused(logger, c, baseModel, ch.svc.Paused().Reason)
+	}
+	ch.svc.countApproval()
+	return ch.finalize(ctx, logger, c, event, baseModel)
+}
+
+// hold records the withdrawal as held for an operator without sending a
+// transaction. It is rejected if not reviewed by its deadline.
+func (ch *chain) hold(logger *slog.Logger, c *custodyContract, baseModel *store.WithdrawEventModel, reason error) error {
+	deadline := ch.reviewDeadline(c, baseModel.BlockTime)
+	if c.expiry == 0 && baseModel.ReviewDeadline != nil {
+		// Held again on evaluating it again; see reevaluate.
+		deadline = *baseModel.ReviewDeadline
+	}
+	logger.Warn("Withdrawal held by policy", "reason", reason, "reason_code", baseModel.ReasonCode, "review_deadline", deadline)
+	baseModel.ReviewDeadline = &deadline
+	baseModel.Decision = store.DecisionHeld
+	baseModel.Reason = reason.Error()
+	return ch.recordEvent(logger, baseModel)
+}
+
+func (ch *chain) reject(ctx context.Context, logger *slog.Logger, c *custodyContract, event *custody.WithdrawStartedEvent, baseModel *store.WithdrawEventModel, reason error) error {
+	logger.Warn("Withdrawal blocked by policy, rejecting", "reason", reason, "reason_code", baseModel.ReasonCode)
+
+	tx, err := ch.sendAction(ctx, c, baseModel, store.ActionReject, reason.Error(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.RejectWithdraw(opts, event.WithdrawalID)
+	})
+	if err != nil {
+		var intentErr *intentError
+		if errors.As(err, &intentErr) {
+			return err
+		}
+		// Rejection may fail if the contract requires expiry (ThresholdCustody).
+		// Schedule a deferred retry.
+		logger.Warn("Immediate reject failed, deferring until expiry", "error", err)
+		return ch.deferRejection(logger, baseModel, reason.Error())
+	}
+
+	logger.Info("Sent reject transaction", "tx_hash", tx.Hash().Hex())
+	receipt, err := bind.WaitMined(ctx, ch.ethClient, tx)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Leave the intent in place; the transaction is resumed on restart.
+			return ctx.Err()
+		}
+		logger.Error("Failed waiting for reject tx to be mined", "error", err)
+		baseModel.Decision = "error"
+		baseModel.Reason = fmt.Sprintf("reject tx mining failed: %v", err)
+		return ch.recordEvent(logger, baseModel)
+	}
+	return ch.completeReject(logger, baseModel, reason.Error(), receipt)
+}
+
+func (ch *chain) completeReject(logger *slog.Logger, baseModel *store.WithdrawEventModel, reason string, receipt *types.Receipt) error {
+	if receipt.Status != 1 {
+		// On-chain revert (e.g. WithdrawalNotExpired). Defer the rejection.
+		logger.Warn("Reject tx reverted on-chain, deferring until expiry")
+		return ch.deferRejection(logger, baseModel, reason)
+	}
+	baseModel.Decision = "rejected"
+	baseModel.Reason = reason
+	return ch.recordEvent(logger, baseModel)
+}
+
+func (ch *chain) deferRejection(logger *slog.Logger, baseModel *store.WithdrawEventModel, reason string) error {
+	pending := &store.PendingRejectionModel{
+		WithdrawalID: baseModel.WithdrawalID,
+		Contract:     baseModel.Contract,
+		Reason:       reason,
+	}
+	if err := ch.store.SavePendingRejection(pending); err != nil {
+		return fmt.Errorf("failed to save pending rejection: %w", err)
+	}
+	baseModel.Decision = "rejected"
+	baseModel.Reason = reason
+	return ch.recordEvent(logger, baseModel)
+}
+
+// finalize sends the finalize transaction of an approved withdrawal and records
+// the outcome. The withdrawal is held instead if the worker was paused since it
+// was approved.
+func (ch *chain) finalize(ctx context.Context, logger *slog.Logger, c *custodyContract, event *custody.WithdrawStartedEvent, baseModel *store.WithdrawEventModel) error {
+	tx, err := ch.sendAction(ctx, c, baseModel, store.ActionFinalize, baseModel.Reason, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.FinalizeWithdraw(opts, event.WithdrawalID)
+	})
+	if errors.Is(err, ErrPaused) {
+		// Replace the intent of the transaction that was not sent.
+		baseModel.Action, baseModel.ActionTxHash, baseModel.ActionRawTx = "", "", ""
+		return ch.holdPaused(logger, c, baseModel, ch.svc.Paused().Reason)
+	}
+	if err != nil {
+		var intentErr *intentError
+		if errors.As(err, &intentErr) {
+			return err
+		}
+		logger.Error("Failed to finalize withdrawal", "error", err)
+		baseModel.Decision = "error"
+		baseModel.Reason = fmt.Sprintf("finalize tx failed: %v", err)
+		return ch.recordEvent(logger, baseModel)
+	}
+
+	logger.Info("Sent finalize transaction", "tx_hash", tx.Hash().Hex())
+
+	receipt, err := bind.WaitMined(ctx, ch.ethClient, tx)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Leave the intent in place; the transaction is resumed on restart.
+			return ctx.Err()
+		}
+		logger.Error("Transaction mining failed", "error", err)
+		baseModel.Decision = "error"
+		baseModel.Reason = fmt.Sprintf("finalize tx mining failed: %v", err)
+		return ch.recordEvent(logger, baseModel)
+	}
+	return ch.completeFinalize(logger, c, event, baseModel, tx, receipt)
+}
+
+func (ch *chain) completeFinalize(logger *slog.Logger, c *custodyContract, event *custody.WithdrawStartedEvent, baseModel *store.WithdrawEventModel, tx *types.Transaction, receipt *types.Receipt) error {
+	if receipt.Status != 1 {
+		logger.Error("Withdrawal finalization tx reverted")
+		baseModel.Decision = "error"
+		baseModel.Reason = "finalize tx reverted on-chain"
+		return ch.recordEvent(logger, baseModel)
+	}
+
+	// Check receipt logs for WithdrawFinalized event to confirm actual execution.
+	// In ThresholdCustody, finalizeWithdraw adds an approval; the withdrawal only
+	// executes when the threshold is met and emits WithdrawFinalized.
+	executed := false
+	for _, log := range receipt.Logs {
+		if log.Address != c.address {
+			continue
+		}
+		finalized, parseErr := c.contract.ParseWithdrawFinalized(*log)
+		if parseErr != nil {
+			continue
+		}
+		if finalized.WithdrawalId == event.WithdrawalID && finalized.Success {
+			executed = true
+			break
+		}
+	}
+
+	if !executed {
+		logger.Info("Approval recorded on-chain, threshold not yet met")
+		baseModel.Decision = store.DecisionPending
+		baseModel.Reason = "approval added, awaiting threshold"
+		return ch.recordEvent(logger, baseModel)
+	}
+
+	logger.Info("Withdrawal finalized successfully on-chain")
+
+	// The withdrawal may already have been saved by a run that stopped
+	// before recording the decision.
+	saved, err := ch.store.HasWithdrawal(baseModel.WithdrawalID)
+	if err != nil {
+		return fmt.Errorf("failed to look up withdrawal: %w", err)
+	}
+	if !saved {
+		record := &custody.Withdrawal{
+			WithdrawalID: event.WithdrawalID,
+			User:         event.User,
+			Token:        event.Token,
+			Amount:       event.Amount,
+			BlockNumber:  receipt.BlockNumber.Uint64(),
+			TxHash:       tx.Hash(),
+			Timestamp:    eventTime(event),
+		}
+		if err := ch.checker.Record(record); err != nil {
+			return fmt.Errorf("failed to record withdrawal: %w", err)
+		}
+	}
+
+	baseModel.Decision = "approved"
+	return ch.recordEvent(logger, baseModel)
+}
+
+// eventTime returns the time of the block that requested the withdrawal, or
+// the current time if the listener could not provide it.
+func eventTime(event *custody.WithdrawStartedEvent) time.Time {
+	if event.BlockTime.IsZero() {
+		return time.Now()
+	}
+	return event.BlockTime
+}
+
+// intentError reports that a transaction could not be persisted as an intent
+// and therefore was not broadcast.
+type intentError struct{ err error }
+
+func (e *intentError) Error() string { return fmt.Sprintf("failed to save withdraw intent: %v", e.err) }
+func (e *intentError) Unwrap() error { return e.err }
+
+// sendAction signs the transaction built by build with the key of c, persists
+// it as a processing intent on baseModel and only then broadcasts it. Errors
+// from build, such as a failed gas estimation, mean nothing was persisted or
+// sent. Finalize transactions are not sent while the worker is paused; the
+// pause is checked again when broadcasting, and ErrPaused is returned.
+func (ch *chain) sendAction(ctx context.Context, c *custodyContract, baseModel *store.WithdrawEventModel, action, reason string,
+	build func(*bind.TransactOpts) (*types.Transaction, error),
+) (*types.Transaction, error) {
+	if action == store.ActionFinalize && ch.svc.Paused().Paused {
+		return nil, ErrPaused
+	}
+	txAuth := *c.auth
+	txAuth.Context = ctx
+	txAuth.NoSend = true
+	tx, err := build(&txAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, &intentError{err: err}
+	}
+	baseModel.Action = action
+	baseModel.ActionTxHash = tx.Hash().Hex()
+	baseModel.ActionRawTx = hexutil.Encode(raw)
+
+	intent := *baseModel
+	intent.Decision = store.DecisionProcessing
+	intent.Reason = reason
+	if err := ch.store.SaveWithdrawIntent(&intent); err != nil {
+		return nil, &intentError{err: err}
+	}
+
+	if err := ch.broadcast(ctx, action, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// broadcast sends tx, the transaction of action. Finalize transactions are
+// only sent while the worker is not paused, and ErrPaused is returned
+// otherwise.
+func (ch *chain) broadcast(ctx context.Context, action string, tx *types.Transaction) error {
+	send := func(ctx context.Context) error { return ch.ethClient.SendTransaction(ctx, tx) }
+	if action != store.ActionFinalize {
+		return send(ctx)
+	}
+	return ch.svc.unlessPaused(ctx, send)
+}
+
+// inFlightRecoveryTimeout bounds how long a restarted worker waits for a
+// rebroadcast intent transaction before deciding it was dropped.
+const inFlightRecoveryTimeout = 5 * time.Minute
+
+// recoverInFlight tracks down the transaction of a processing intent left by a
+// previous run. It returns the mined receipt, or a nil receipt if the
+// transaction was dropped and the withdrawal has to be evaluated again.
+func (ch *chain) recoverInFlight(ctx context.Context, logger *slog.Logger, intent *store.WithdrawEventModel) (*types.Transaction, *types.Receipt, error) {
+	raw, err := hexutil.Decode(intent.ActionRawTx)
+	if err != nil {
+		logger.Warn("Processing intent has no usable transaction", "error", err)
+		return nil, nil, nil
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		logger.Warn("Processing intent has no usable transaction", "error", err)
+		return nil, nil, nil
+	}
+
+	receipt, err := ch.ethClient.TransactionReceipt(ctx, tx.Hash())
+	if err == nil {
+		return tx, receipt, nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return nil, nil, fmt.Errorf("failed to look up in-flight transaction %s: %w", tx.Hash().Hex(), err)
+	}
+
+	// The transaction may still be pending, or may never have reached the
+	// node. Rebroadcasting an already known transaction is harmless.
+	if err := ch.broadcast(ctx, intent.Action, tx); err != nil {
+		logger.Warn("Failed to rebroadcast in-flight transaction", "tx_hash", tx.Hash().Hex(), "error", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, inFlightRecoveryTimeout)
+	defer cancel()
+	receipt, err = bind.WaitMined(waitCtx, ch.ethClient, tx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, nil
+	}
+	return tx, receipt, nil
+}
+
+// processReorg marks the decisions taken for events in the orphaned range so
+// that the withdrawals are re-evaluated if the listener re-delivers them from
+// the new canonical chain. Transactions already sent for orphaned withdrawals
+// are not undone; if the withdrawal reappears and was already settled on-chain,
+// the re-sent transaction reverts and is recorded as an error.
+func (ch *chain) processReorg(reorg *custody.ReorgEvent) {
+	logger := ch.logger.With(
+		"from_block", reorg.FromBlock,
+		"to_block", reorg.ToBlock,
+		"ancestor_block", reorg.Ancestor.Number,
+	)
+	logger.Warn("Chain reorganization orphaned processed blocks", "orphaned_events", len(reorg.Events))
+
+	ids, err := ch.store.OrphanWithdrawEvents(reorg.FromBlock, reorg.Ancestor)
+	if err != nil {
+		logger.Error("Failed to mark orphaned withdraw events", "error", err)
+		return
+	}
+	for _, id := range ids {
+		logger.Warn("Withdrawal decision orphaned, awaiting re-evaluation", "withdrawal_id", id)
+	}
+}
+
+func (ch *chain) processDeferredRejections(ctx context.Context) {
+	ch.txMu.Lock()
+	defer ch.txMu.Unlock()
+
+	pending, err := ch.store.GetPendingRejections()
+	if err != nil {
+		ch.logger.Error("Failed to get pending rejections", "error", err)
+		return
+	}
+
+	for _, p := range pending {
+		// Rejections deferred before contracts were tracked separately
+		// belong to the first one.
+		c := ch.contracts[0]
+		if p.Contract != "" {
+			c = ch.custodyAt(common.HexToAddress(p.Contract))
+		}
+		if c == nil {
+			ch.logger.Error("Pending rejection of unknown custody contract", "withdrawal_id", p.WithdrawalID, "contract", p.Contract)
+			continue
+		}
+		logger := ch.logger.With("contract", c.label, "withdrawal_id", p.WithdrawalID, "reason", p.Reason)
+
+		var wID [32]byte
+		copy(wID[:], common.FromHex(p.WithdrawalID))
+
+		txAuth := *c.auth
+		txAuth.Context = ctx
+		tx, txErr := c.contract.RejectWithdraw(&txAuth, wID)
+		if txErr != nil {
+			// Will retry on next tick; may still be before expiry
+			logger.Warn("Deferred reject tx failed (may not be expired yet)", "error", txErr)
+			continue
+		}
+
+		logger.Info("Sent deferred reject transaction", "tx_hash", tx.Hash().Hex())
+		receipt, txErr := bind.WaitMined(ctx, ch.ethClient, tx)
+		if txErr != nil {
+			logger.Error("Deferred reject tx mining failed", "error", txErr)
+			continue
+		}
+
+		if receipt.Status == 1 {
+			logger.Info("Deferred rejection finalized on-chain")
+		} else {
+			logger.Warn("Deferred rejection tx reverted (may already be finalized)")
+		}
+
+		if err := ch.store.CompletePendingRejection(p.WithdrawalID); err != nil {
+			logger.Error("Failed to mark pending rejection as completed", "error", err)
+		}
+	}
+}
+
+// custodyAt returns the watched custody contract at addr, or nil if there is none.
+func (ch *chain) custodyAt(addr common.Address) *custodyContract {
+	for _, c := range ch.contracts {
+		if c.address == addr {
+			return c
+		}
+	}
+	return nil
+}
+
+// withdrawCursor returns the cursor the withdraw stream resumes from: that of
+// the contract furthest behind, carrying the positions of all contracts so
+// that events already processed for the others are skipped.
+func (ch *chain) withdrawCursor() (custody.Cursor, error) {
+	var from custody.Cursor
+	positions := make(map[common.Address]custody.Position, len(ch.contracts))
+	for i, c := range ch.contracts {
+		cursor, err := ch.store.GetContractCursor(withdrawStartedStream, c.address.Hex())
+		if err != nil {
+			return custody.Cursor{}, err
+		}
+		if cursor.BlockNumber == 0 && i == 0 {
+			// The cursor stored before contracts were tracked separately
+			// belongs to the first one.
+			if cursor, err = ch.store.GetCursor(withdrawStartedStream); err != nil {
+				return custody.Cursor{}, err
+			}
+		}
+		if cursor.BlockNumber == 0 && c.startBlock > 0 {
+			cursor = custody.Cursor{BlockNumber: c.startBlock}
+		}
+		positions[c.address] = custody.Position{BlockNumber: cursor.BlockNumber, LogIndex: cursor.LogIndex}
+		if i == 0 || cursor.BlockNumber < from.BlockNumber ||
+			(cursor.BlockNumber == from.BlockNumber && cursor.LogIndex < from.LogIndex) {
+			from = cursor
+		}
+	}
+	from.Contracts = positions
+	return from, nil
+}
+
+func (ch *chain) recordEvent(logger *slog.Logger, ev *store.WithdrawEventModel) error {
+	if err := ch.store.RecordWithdrawEvent(ev); err != nil {
+		logger.Error("Failed to record withdraw event", "error", err)
+		return err
+	}
+	return nil
+}
+
+// priceSnapshot is the recorded form of a checker.PriceSnapshot.
+type priceSnapshot struct {
+	Currency string            `json:"currency"`
+	Time     time.Time         `json:"time"`
+	Prices   map[string]string `json:"prices"`
+}
+
+func encodePriceSnapshot(s *checker.PriceSnapshot) (string, error) {
+	recorded := priceSnapshot{Currency: s.Currency, Time: s.Time, Prices: make(map[string]string, len(s.Prices))}
+	for token, price := range s.Prices {
+		prec, exact := price.FloatPrec()
+		if !exact {
+			prec = 18
+		}
+		recorded.Prices[token.Hex()] = price.FloatString(prec)
+	}
+	data, err := json.Marshal(recorded)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// anomalyStats is the recorded form of a checker.AnomalyStats. Amounts are
+// decimal strings in base units of the token.
+type anomalyStats struct {
+	History  int     `json:"history"`
+	Tokens   int     `json:"tokens"`
+	Mean     string  `json:"mean,omitempty"`
+	Median   string  `json:"median,omitempty"`
+	P90      string  `json:"p90,omitempty"`
+	Max      string  `json:"max,omitempty"`
+	Recent   int     `json:"recent"`
+	Expected float64 `json:"expected"`
+}
+
+func encodeAnomalyStats(s *checker.AnomalyStats) (string, error) {
+	recorded := anomalyStats{History: s.History, Tokens: s.Tokens, Recent: s.Recent, Expected: s.Expected}
+	if s.History > 0 {
+		recorded.Mean, recorded.Median = s.Mean.String(), s.Median.String()
+		recorded.P90, recorded.Max = s.P90.String(), s.Max.String()
+	}
+	data, err := json.Marshal(recorded)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
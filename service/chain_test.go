@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/layer-3/nitewatch/config"
+	"github.com/layer-3/nitewatch/custody"
+)
+
+// fakeListener runs WithdrawStarted streams that deliver no events; watch
+// decides how the n-th run (counting from 1) ends.
+type fakeListener struct {
+	runs  int
+	watch func(ctx context.Context, run int) error
+}
+
+func (l *fakeListener) WatchWithdrawStarted(ctx context.Context, sink chan<- *custody.WithdrawStartedEvent, reorgs chan<- *custody.ReorgEvent, _ custody.Cursor) error {
+	defer close(sink)
+	defer close(reorgs)
+	l.runs++
+	return l.watch(ctx, l.runs)
+}
+
+func (l *fakeListener) DecodeLog(types.Log, time.Time) (custody.Event, error) {
+	return nil, errors.New("not implemented")
+}
+
+// shortenRestartPolicy makes failed streams restart within milliseconds for
+// the duration of the test.
+func shortenRestartPolicy(t *testing.T) {
+	minDelay, maxDelay, stableAfter := minRestartDelay, maxRestartDelay, listenerStableAfter
+	minRestartDelay, maxRestartDelay, listenerStableAfter = time.Millisecond, 10*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() {
+		minRestartDelay, maxRestartDelay, listenerStableAfter = minDelay, maxDelay, stableAfter
+	})
+}
+
+func newSupervisedChain(name string, maxRestarts int, listener eventListener) *chain {
+	return &chain{
+		svc:      &Service{},
+		name:     name,
+		conf:     config.BlockchainConfig{ListenerMaxRestarts: maxRestarts},
+		logger:   slog.New(slog.DiscardHandler),
+		listener: listener,
+	}
+}
+
+func TestSuperviseWithdrawStream_RestartLimit(t *testing.T) {
+	shortenRestartPolicy(t)
+
+	var ch *chain
+	var health []error
+	recovered := false
+	listener := &fakeListener{watch: func(ctx context.Context, run int) error {
+		health = append(health, ch.svc.Health())
+		if run == 3 {
+			// Stay up until the supervisor considers the stream recovered.
+			for ch.svc.Health() != nil {
+				select {
+				case <-time.After(time.Millisecond):
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			recovered = true
+		}
+		return fmt.Errorf("node unavailable (run %d)", run)
+	}}
+	ch = newSupervisedChain("limit", 3, listener)
+	stream := ch.stream(withdrawStartedStream)
+	restarts := testutil.ToFloat64(listenerRestartsCounter.WithLabelValues(stream))
+
+	err := ch.superviseWithdrawStream(context.Background())
+
+	// Runs 1 and 2 fail, run 3 recovers and resets the limit, then runs 4 to
+	// 6 fail and the third restart in a row is the last one.
+	require.ErrorContains(t, err, "failed after 3 restarts")
+	require.ErrorContains(t, err, "node unavailable (run 6)")
+	require.Equal(t, 6, listener.runs)
+	require.True(t, recovered)
+	require.Equal(t, 5.0, testutil.ToFloat64(listenerRestartsCounter.WithLabelValues(stream))-restarts)
+	require.Zero(t, testutil.ToFloat64(listenerUpGauge.WithLabelValues(stream)))
+
+	// The worker is degraded whenever a stream is restarted after a failure,
+	// and stays so once the supervisor gave up.
+	require.NoError(t, health[0])
+	for run, err := range health[1:] {
+		require.ErrorContains(t, err, fmt.Sprintf("node unavailable (run %d)", run+1))
+	}
+	require.ErrorContains(t, ch.svc.Health(), "node unavailable (run 6)")
+}
+
+func TestSuperviseWithdrawStream_Unlimited(t *testing.T) {
+	shortenRestartPolicy(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener := &fakeListener{watch: func(ctx context.Context, run int) error {
+		if run == 10 {
+			cancel()
+			<-ctx.Done()
+			return nil
+		}
+		return errors.New("node unavailable")
+	}}
+	ch := newSupervisedChain("unlimited", 0, listener)
+
+	require.NoError(t, ch.superviseWithdrawStream(ctx))
+	require.Equal(t, 10, listener.runs)
+}
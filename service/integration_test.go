@@ -5,10 +5,13 @@ package service_test
 import (
 	"context"
 	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,9 +23,14 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient/simulated"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 
 	"github.com/layer-3/nitewatch/config"
 	"github.com/layer-3/nitewatch/custody"
+	"github.com/layer-3/nitewatch/internal/checker"
+	"github.com/layer-3/nitewatch/internal/store"
+	"github.com/layer-3/nitewatch/policy"
 	"github.com/layer-3/nitewatch/service"
 )
 
@@ -171,6 +179,12 @@ func runNitewatchService(t *testing.T, svc *service.Service) {
 // waitForWithdrawFinalized polls for a WithdrawFinalized event with the given success value.
 func waitForWithdrawFinalized(t *testing.T, env *testEnv, timeout time.Duration) *custody.SimpleCustodyWithdrawFinalized {
 	t.Helper()
+	return waitForWithdrawFinalizedOn(t, env.contract, timeout)
+}
+
+// waitForWithdrawFinalizedOn polls the given contract for a WithdrawFinalized event.
+func waitForWithdrawFinalizedOn(t *testing.T, contract *custody.SimpleCustody, timeout time.Duration) *custody.SimpleCustodyWithdrawFinalized {
+	t.Helper()
 
 	deadline := time.After(timeout)
 	ticker := time.NewTicker(200 * time.Millisecond)
@@ -182,7 +196,7 @@ func waitForWithdrawFinalized(t *testing.T, env *testEnv, timeout time.Duration)
 			t.Fatal("timed out waiting for WithdrawFinalized event")
 			return nil
 		case <-ticker.C:
-			iter, err := env.contract.FilterWithdrawFinalized(&bind.FilterOpts{
+			iter, err := contract.FilterWithdrawFinalized(&bind.FilterOpts{
 				Start:   0,
 				Context: context.Background(),
 			}, nil)
@@ -197,6 +211,43 @@ func waitForWithdrawFinalized(t *testing.T, env *testEnv, timeout time.Duration)
 	}
 }
 
+// startWithdraw starts a withdrawal of amount wei to the user and returns its ID.
+func startWithdraw(t *testing.T, env *testEnv, amount *big.Int, nonce int64) [32]byte {
+	t.Helper()
+
+	tx, err := env.contract.StartWithdraw(copyAuth(env.neodaxAuth()), env.userAddr(), common.Address{}, amount, big.NewInt(nonce))
+	require.NoError(t, err)
+	env.sim.Commit()
+	receipt, err := env.client.TransactionReceipt(context.Background(), tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), receipt.Status, "startWithdraw tx failed")
+	require.Len(t, receipt.Logs, 1)
+	started, err := env.contract.ParseWithdrawStarted(*receipt.Logs[0])
+	require.NoError(t, err)
+	return started.WithdrawalId
+}
+
+// waitForWithdrawalOutcome polls for the WithdrawFinalized event of the given
+// withdrawal and reports whether it succeeded.
+func waitForWithdrawalOutcome(t *testing.T, env *testEnv, withdrawalID [32]byte, timeout time.Duration) bool {
+	t.Helper()
+
+	var success bool
+	require.Eventually(t, func() bool {
+		iter, err := env.contract.FilterWithdrawFinalized(&bind.FilterOpts{Context: context.Background()}, [][32]byte{withdrawalID})
+		if err != nil {
+			return false
+		}
+		defer iter.Close()
+		if !iter.Next() {
+			return false
+		}
+		success = iter.Event.Success
+		return true
+	}, timeout, 200*time.Millisecond, "timed out waiting for WithdrawFinalized event")
+	return success
+}
+
 func TestWithdrawalFinalized(t *testing.T) {
 	env := newTestEnv(t)
 
@@ -296,6 +347,986 @@ func TestWithdrawalRejected(t *testing.T) {
 		"user balance should not change after rejection")
 }
 
+func TestLimitInWholeTokens(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := config.Config{
+		Blockchain: config.BlockchainConfig{
+			ContractAddr:       env.addr.Hex(),
+			PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+			ConfirmationBlocks: 1,
+			PollInterval:       200 * time.Millisecond,
+		},
+		Limits: config.LimitsConfig{
+			nativeToken: config.LimitConfig{Daily: "0.5 ETH"},
+		},
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+
+	userAuth := copyAuth(env.auths[3])
+	userAuth.Value = big.NewInt(1e18)
+	_, err = env.contract.Deposit(userAuth, common.Address{}, big.NewInt(1e18))
+	require.NoError(t, err)
+	env.sim.Commit()
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(3e17), 1)
+	assert.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected 0.3 ETH within a 0.5 ETH limit to be finalized")
+
+	withdrawalID = startWithdraw(t, env, big.NewInt(3e17), 2)
+	assert.False(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected 0.6 ETH over a 0.5 ETH limit to be rejected")
+}
+
+func TestLimitInWholeTokensInvalid(t *testing.T) {
+	env := newTestEnv(t)
+
+	newService := func(token string, limit config.LimitConfig) error {
+		conf := config.Config{
+			Blockchain: config.BlockchainConfig{
+				ContractAddr:       env.addr.Hex(),
+				PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+				ConfirmationBlocks: 1,
+			},
+			Limits:     config.LimitsConfig{token: limit},
+			DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+			ListenAddr: ":0",
+		}
+		_, err := service.NewWithBackend(conf, env.client)
+		return err
+	}
+
+	err := newService(nativeToken, config.LimitConfig{Daily: "0.0000000000000000001 ETH"})
+	assert.ErrorContains(t, err, "more than 18 decimals")
+
+	err = newService(nativeToken, config.LimitConfig{Daily: "10 USDC"})
+	assert.ErrorContains(t, err, "does not match token symbol ETH")
+
+	// Without decimals, symbol or unit, amounts are base units.
+	err = newService(nativeToken, config.LimitConfig{Daily: "10.5"})
+	assert.ErrorContains(t, err, `invalid amount "10.5"`)
+
+	// The custody contract has no decimals() to read.
+	err = newService(env.addr.Hex(), config.LimitConfig{Daily: "10.5", Symbol: "USDC"})
+	assert.ErrorContains(t, err, "failed to read decimals")
+}
+
+func TestUserTiers(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := config.Config{
+		Blockchain: config.BlockchainConfig{
+			ContractAddr:       env.addr.Hex(),
+			PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+			ConfirmationBlocks: 1,
+			PollInterval:       200 * time.Millisecond,
+		},
+		Limits: config.LimitsConfig{
+			nativeToken: config.LimitConfig{Daily: "10 ETH"},
+		},
+		UserLimits: config.LimitsConfig{
+			nativeToken: config.LimitConfig{Daily: "0.1 ETH"},
+		},
+		Tiers: map[string]config.LimitsConfig{
+			"vip": {nativeToken: config.LimitConfig{Daily: "0.5 ETH"}},
+		},
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+
+	userAuth := copyAuth(env.auths[3])
+	userAuth.Value = big.NewInt(1e18)
+	_, err = env.contract.Deposit(userAuth, common.Address{}, big.NewInt(1e18))
+	require.NoError(t, err)
+	env.sim.Commit()
+
+	require.ErrorIs(t, svc.SetUserTier(env.userAddr(), "gold"), service.ErrUnknownTier)
+	require.NoError(t, svc.SetUserTier(env.userAddr(), "vip"))
+	withdrawalID := startWithdraw(t, env, big.NewInt(3e17), 1)
+	assert.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected 0.3 ETH within the vip limit to be finalized")
+
+	// Without a tier, the default per-user limit applies.
+	require.NoError(t, svc.SetUserTier(env.userAddr(), ""))
+	withdrawalID = startWithdraw(t, env, big.NewInt(5e16), 2)
+	assert.False(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected 0.35 ETH over the default user limit to be rejected")
+	recorded := waitForDecision(t, svc, withdrawalID, 10*time.Second)
+	assert.Equal(t, checker.ReasonAmountLimit, recorded.ReasonCode)
+	assert.Contains(t, recorded.Reason, "per-user daily")
+}
+
+func TestMultipleContracts(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	// A second deployment whose nitewatch signer is the admin account.
+	addr2, tx, contract2, err := custody.DeploySimpleCustody(env.adminAuth(), env.client, env.addrs[0], env.addrs[1], env.addrs[0])
+	require.NoError(t, err)
+	env.sim.Commit()
+	receipt, err := env.client.TransactionReceipt(context.Background(), tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), receipt.Status, "contract deployment failed")
+
+	conf := config.Config{
+		Blockchain: config.BlockchainConfig{
+			PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+			ConfirmationBlocks: 1,
+			PollInterval:       200 * time.Millisecond,
+			Contracts: []config.ContractConfig{
+				{Address: env.addr.Hex(), Label: "v1"},
+				{Address: addr2.Hex(), Label: "v2", PrivateKey: fmt.Sprintf("%x", crypto.FromECDSA(env.keys[0]))},
+			},
+		},
+		Limits: config.LimitsConfig{
+			nativeToken: config.LimitConfig{
+				Hourly: "100000000000000000000",
+				Daily:  "100000000000000000000",
+			},
+		},
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+
+	for _, contract := range []*custody.SimpleCustody{env.contract, contract2} {
+		userAuth := copyAuth(env.auths[3])
+		userAuth.Value = big.NewInt(1e18)
+		_, err := contract.Deposit(userAuth, common.Address{}, big.NewInt(1e18))
+		require.NoError(t, err)
+		env.sim.Commit()
+		_, err = contract.StartWithdraw(copyAuth(env.neodaxAuth()), env.userAddr(), common.Address{}, big.NewInt(1e17), big.NewInt(1))
+		require.NoError(t, err)
+		env.sim.Commit()
+	}
+
+	// Each withdrawal is finalized with the signer key of its contract.
+	ev := waitForWithdrawFinalizedOn(t, env.contract, 30*time.Second)
+	assert.True(t, ev.Success, "expected withdrawal on the first contract to be finalized")
+	ev = waitForWithdrawFinalizedOn(t, contract2, 30*time.Second)
+	assert.True(t, ev.Success, "expected withdrawal on the second contract to be finalized")
+}
+
+func TestCrossChainLimit(t *testing.T) {
+	envA, envB := newTestEnv(t), newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, envA.sim, 100*time.Millisecond)
+	go autoCommit(ctx, envB.sim, 100*time.Millisecond)
+
+	chainConfig := func(name string, env *testEnv) config.ChainConfig {
+		return config.ChainConfig{
+			Name: name,
+			Blockchain: config.BlockchainConfig{
+				ContractAddr:       env.addr.Hex(),
+				PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+				ConfirmationBlocks: 1,
+				PollInterval:       200 * time.Millisecond,
+			},
+			Limits: config.LimitsConfig{
+				nativeToken: config.LimitConfig{Hourly: "100000000000000000000"},
+			},
+		}
+	}
+	conf := config.Config{
+		Chains: []config.ChainConfig{chainConfig("a", envA), chainConfig("b", envB)},
+		// 0.15 ETH across both chains.
+		CrossChainLimits: []config.CrossChainLimitConfig{{
+			Name:   "eth",
+			Tokens: map[string]string{"a": nativeToken, "b": nativeToken},
+			// Daily rather than hourly so that the two withdrawals are unlikely
+			// to straddle a window boundary.
+			LimitConfig: config.LimitConfig{Daily: "150000000000000000"},
+		}},
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+	svc, err := service.NewWithBackends(conf, []custody.EthBackend{envA.client, envB.client})
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+
+	withdraw := func(env *testEnv) {
+		userAuth := copyAuth(env.auths[3])
+		userAuth.Value = big.NewInt(1e18)
+		_, err := env.contract.Deposit(userAuth, common.Address{}, big.NewInt(1e18))
+		require.NoError(t, err)
+		env.sim.Commit()
+		_, err = env.contract.StartWithdraw(copyAuth(env.neodaxAuth()), env.userAddr(), common.Address{}, big.NewInt(1e17), big.NewInt(1))
+		require.NoError(t, err)
+		env.sim.Commit()
+	}
+
+	// 0.1 ETH on chain a fits the cross-chain limit.
+	withdraw(envA)
+	ev := waitForWithdrawFinalized(t, envA, 30*time.Second)
+	assert.True(t, ev.Success, "expected withdrawal on chain a to be finalized")
+
+	// Another 0.1 ETH on chain b would exceed it.
+	withdraw(envB)
+	ev = waitForWithdrawFinalized(t, envB, 30*time.Second)
+	assert.False(t, ev.Success, "expected withdrawal on chain b to be rejected")
+}
+
+func TestFiatLimit(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	pricesPath := filepath.Join(t.TempDir(), "prices.json")
+	require.NoError(t, os.WriteFile(pricesPath, []byte(`{"currency": "USD", "prices": {"`+nativeToken+`": "3000"}}`), 0o600))
+
+	decimals := uint8(18)
+	conf := config.Config{
+		Blockchain: config.BlockchainConfig{
+			ContractAddr:       env.addr.Hex(),
+			PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+			ConfirmationBlocks: 1,
+			PollInterval:       200 * time.Millisecond,
+		},
+		// No token limits: ETH is only capped by its value, 2000 USD a day.
+		FiatLimits: config.FiatLimitsConfig{
+			Currency:        "USD",
+			PriceSource:     config.PriceSourceConfig{File: pricesPath, RefreshInterval: time.Minute, MaxAge: time.Hour},
+			Tokens:          map[string]config.FiatTokenConfig{nativeToken: {Decimals: &decimals}},
+			FiatLimitConfig: config.FiatLimitConfig{Daily: "2000"},
+		},
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+
+	userAuth := copyAuth(env.auths[3])
+	userAuth.Value = big.NewInt(1e18)
+	_, err = env.contract.Deposit(userAuth, common.Address{}, big.NewInt(1e18))
+	require.NoError(t, err)
+	env.sim.Commit()
+
+	// 0.5 ETH is worth 1500 USD.
+	_, err = env.contract.StartWithdraw(copyAuth(env.neodaxAuth()), env.userAddr(), common.Address{}, big.NewInt(5e17), big.NewInt(1))
+	require.NoError(t, err)
+	env.sim.Commit()
+	ev := waitForWithdrawFinalized(t, env, 30*time.Second)
+	assert.True(t, ev.Success, "expected first withdrawal to be finalized")
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(5e17), 2)
+	assert.False(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected second withdrawal to be rejected")
+
+	gormDB, err := gorm.Open(sqlite.Open(svc.Config.DBPath), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := store.NewAdapter(gormDB)
+	require.NoError(t, err)
+	var recorded *store.WithdrawEventModel
+	require.Eventually(t, func() bool {
+		recorded, err = db.GetWithdrawEvent(common.Hash(withdrawalID).Hex())
+		return err == nil && recorded != nil && recorded.Decision == "rejected"
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Contains(t, recorded.Reason, "fiat daily limit exceeded")
+	assert.Equal(t, checker.ReasonFiatLimit, recorded.ReasonCode)
+	assert.JSONEq(t, `{"0x0000000000000000000000000000000000000000": "3000"}`, recordedPrices(t, recorded.PriceSnapshot))
+}
+
+func TestFiatPricesUnavailable(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	pricesPath := filepath.Join(t.TempDir(), "prices.json")
+	writePrices := func(updatedAt time.Time) {
+		data := fmt.Sprintf(`{"currency": "USD", "updated_at": %q, "prices": {%q: "3000"}}`, updatedAt.Format(time.RFC3339), nativeToken)
+		require.NoError(t, os.WriteFile(pricesPath, []byte(data), 0o600))
+	}
+	writePrices(time.Now().Add(-2 * time.Hour))
+
+	decimals := uint8(18)
+	conf := pauseTestConfig(t, env)
+	conf.Limits = nil
+	conf.FiatLimits = config.FiatLimitsConfig{
+		Currency:        "USD",
+		PriceSource:     config.PriceSourceConfig{File: pricesPath, RefreshInterval: 100 * time.Millisecond, MaxAge: time.Hour},
+		Tokens:          map[string]config.FiatTokenConfig{nativeToken: {Decimals: &decimals}},
+		FiatLimitConfig: config.FiatLimitConfig{Daily: "2000"},
+	}
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+	deposit(t, env, big.NewInt(1e18))
+
+	// The prices are stale, so the withdrawal is held rather than rejected.
+	withdrawalID := startWithdraw(t, env, big.NewInt(1e17), 1)
+	recorded := waitForDecision(t, svc, withdrawalID, 30*time.Second)
+	require.Equal(t, store.DecisionHeld, recorded.Decision)
+	assert.Equal(t, checker.ReasonPriceUnavailable, recorded.ReasonCode)
+
+	writePrices(time.Now())
+	assert.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected held withdrawal to be finalized once prices are available")
+	assert.Equal(t, "approved", waitForDecision(t, svc, withdrawalID, 10*time.Second).Decision)
+}
+
+// recordedPrices returns the prices object of a recorded price snapshot.
+func recordedPrices(t *testing.T, snapshot string) string {
+	t.Helper()
+	var decoded struct {
+		Prices json.RawMessage `json:"prices"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(snapshot), &decoded))
+	return string(decoded.Prices)
+}
+
+func TestRecipientDenylist(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	denylist := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(denylist, []byte(env.userAddr().Hex()+"\n"), 0o600))
+
+	conf := config.Config{
+		Blockchain: config.BlockchainConfig{
+			ContractAddr:       env.addr.Hex(),
+			PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+			ConfirmationBlocks: 1,
+			PollInterval:       200 * time.Millisecond,
+		},
+		Limits: config.LimitsConfig{
+			nativeToken: config.LimitConfig{Hourly: "100000000000000000000"},
+		},
+		Recipients: config.RecipientsConfig{Denylist: denylist, ReloadInterval: 100 * time.Millisecond},
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+
+	userAuth := copyAuth(env.auths[3])
+	userAuth.Value = big.NewInt(1e18)
+	_, err = env.contract.Deposit(userAuth, common.Address{}, big.NewInt(1e18))
+	require.NoError(t, err)
+	env.sim.Commit()
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(1e17), 1)
+	assert.False(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected withdrawal to a denied recipient to be rejected")
+
+	gormDB, err := gorm.Open(sqlite.Open(svc.Config.DBPath), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := store.NewAdapter(gormDB)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		recorded, err := db.GetWithdrawEvent(common.Hash(withdrawalID).Hex())
+		return err == nil && recorded != nil && recorded.ReasonCode == checker.ReasonRecipientDenied
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// Delisting the recipient takes effect without a restart.
+	require.NoError(t, os.WriteFile(denylist, []byte("# no entries\n"), 0o600))
+	time.Sleep(500 * time.Millisecond)
+
+	withdrawalID = startWithdraw(t, env, big.NewInt(1e17), 2)
+	assert.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected withdrawal to be finalized after delisting")
+}
+
+func TestPolicyActions(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := config.Config{
+		Blockchain: config.BlockchainConfig{
+			ContractAddr:       env.addr.Hex(),
+			PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+			ConfirmationBlocks: 1,
+			PollInterval:       200 * time.Millisecond,
+		},
+		Limits: config.LimitsConfig{
+			nativeToken: config.LimitConfig{Daily: "100000000000000000"},
+		},
+		// Exceeding the limits is only logged.
+		Policy:     config.PolicyConfig{Actions: map[string]string{checker.RuleLimits: "log"}},
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+	large := policy.NewRule("large_withdrawal", func(req *policy.Request) error {
+		if req.Amount.Cmp(big.NewInt(5e17)) >= 0 {
+			return fmt.Errorf("amount %s needs review", req.Amount)
+		}
+		return nil
+	})
+	svc, err := service.NewWithBackend(conf, env.client, service.WithRule(large, policy.ActionHold))
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+
+	userAuth := copyAuth(env.auths[3])
+	userAuth.Value = big.NewInt(1e18)
+	_, err = env.contract.Deposit(userAuth, common.Address{}, big.NewInt(1e18))
+	require.NoError(t, err)
+	env.sim.Commit()
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(2e17), 1)
+	assert.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected withdrawal over a log-only limit to be finalized")
+
+	withdrawalID = startWithdraw(t, env, big.NewInt(5e17), 2)
+
+	gormDB, err := gorm.Open(sqlite.Open(svc.Config.DBPath), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := store.NewAdapter(gormDB)
+	require.NoError(t, err)
+	var recorded *store.WithdrawEventModel
+	require.Eventually(t, func() bool {
+		recorded, err = db.GetWithdrawEvent(common.Hash(withdrawalID).Hex())
+		return err == nil && recorded != nil
+	}, 30*time.Second, 100*time.Millisecond)
+	assert.Equal(t, store.DecisionHeld, recorded.Decision)
+	assert.Equal(t, "large_withdrawal", recorded.ReasonCode)
+	assert.Contains(t, recorded.Reason, "large_withdrawal: amount 500000000000000000 needs review")
+
+	iter, err := env.contract.FilterWithdrawFinalized(&bind.FilterOpts{Context: context.Background()}, [][32]byte{withdrawalID})
+	require.NoError(t, err)
+	defer iter.Close()
+	assert.False(t, iter.Next(), "expected no transaction for a held withdrawal")
+}
+
+func TestAnomalousWithdrawalHeld(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := config.Config{
+		Blockchain: config.BlockchainConfig{
+			ContractAddr:       env.addr.Hex(),
+			PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+			ConfirmationBlocks: 1,
+			PollInterval:       200 * time.Millisecond,
+		},
+		Limits: config.LimitsConfig{
+			nativeToken: config.LimitConfig{Daily: "1000000000000000000"},
+		},
+		Anomaly: config.AnomalyConfig{
+			Lookback:        "30d",
+			MinHistory:      2,
+			AmountFactor:    5,
+			FrequencyWindow: "1h",
+		},
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+
+	userAuth := copyAuth(env.auths[3])
+	userAuth.Value = big.NewInt(1e18)
+	_, err = env.contract.Deposit(userAuth, common.Address{}, big.NewInt(1e18))
+	require.NoError(t, err)
+	env.sim.Commit()
+
+	for nonce := int64(1); nonce <= 2; nonce++ {
+		withdrawalID := startWithdraw(t, env, big.NewInt(1e16), nonce)
+		require.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected usual withdrawal to be finalized")
+	}
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(1e17), 3)
+
+	gormDB, err := gorm.Open(sqlite.Open(svc.Config.DBPath), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := store.NewAdapter(gormDB)
+	require.NoError(t, err)
+	var recorded *store.WithdrawEventModel
+	require.Eventually(t, func() bool {
+		recorded, err = db.GetWithdrawEvent(common.Hash(withdrawalID).Hex())
+		return err == nil && recorded != nil
+	}, 30*time.Second, 100*time.Millisecond)
+	assert.Equal(t, store.DecisionHeld, recorded.Decision)
+	assert.Equal(t, checker.ReasonAnomalousAmount, recorded.ReasonCode)
+
+	var stats map[string]any
+	require.NoError(t, json.Unmarshal([]byte(recorded.AnomalyStats), &stats))
+	assert.EqualValues(t, 2, stats["history"])
+	assert.Equal(t, "10000000000000000", stats["median"])
+}
+
+// waitForDecision waits until a decision other than processing is recorded
+// for the withdrawal and returns it.
+func waitForDecision(t *testing.T, svc *service.Service, withdrawalID [32]byte, timeout time.Duration) *store.WithdrawEventModel {
+	t.Helper()
+
+	gormDB, err := gorm.Open(sqlite.Open(svc.Config.DBPath), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := store.NewAdapter(gormDB)
+	require.NoError(t, err)
+	var recorded *store.WithdrawEventModel
+	require.Eventually(t, func() bool {
+		recorded, err = db.GetWithdrawEvent(common.Hash(withdrawalID).Hex())
+		return err == nil && recorded != nil && recorded.Decision != store.DecisionProcessing
+	}, timeout, 100*time.Millisecond)
+	return recorded
+}
+
+func newReviewService(t *testing.T, env *testEnv, review config.ReviewConfig) *service.Service {
+	t.Helper()
+
+	conf := config.Config{
+		Blockchain: config.BlockchainConfig{
+			ContractAddr:       env.addr.Hex(),
+			PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+			ConfirmationBlocks: 1,
+			PollInterval:       200 * time.Millisecond,
+		},
+		Limits: config.LimitsConfig{
+			nativeToken: config.LimitConfig{Daily: "1000000000000000000"},
+		},
+		Review:     review,
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+	holdAll := policy.NewRule("review", func(req *policy.Request) error {
+		return errors.New("manual review required")
+	})
+	svc, err := service.NewWithBackend(conf, env.client, service.WithRule(holdAll, policy.ActionHold))
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+
+	userAuth := copyAuth(env.auths[3])
+	userAuth.Value = big.NewInt(1e18)
+	_, err = env.contract.Deposit(userAuth, common.Address{}, big.NewInt(1e18))
+	require.NoError(t, err)
+	env.sim.Commit()
+	return svc
+}
+
+func TestReviewHeldWithdrawal(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	svc := newReviewService(t, env, config.ReviewConfig{Timeout: time.Hour, CheckInterval: 200 * time.Millisecond})
+
+	approved := startWithdraw(t, env, big.NewInt(1e17), 1)
+	recorded := waitForDecision(t, svc, approved, 30*time.Second)
+	require.Equal(t, store.DecisionHeld, recorded.Decision)
+	require.NotNil(t, recorded.ReviewDeadline)
+	assert.WithinDuration(t, recorded.BlockTime.Add(time.Hour), *recorded.ReviewDeadline, time.Second)
+
+	decision, err := svc.ReviewHeld(ctx, "", common.Hash(approved).Hex(), service.Review{Approve: true, Operator: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, "approved", decision)
+	assert.True(t, waitForWithdrawalOutcome(t, env, approved, 30*time.Second), "expected approved withdrawal to be finalized")
+
+	rejected := startWithdraw(t, env, big.NewInt(1e17), 2)
+	require.Equal(t, store.DecisionHeld, waitForDecision(t, svc, rejected, 30*time.Second).Decision)
+	decision, err = svc.ReviewHeld(ctx, "", common.Hash(rejected).Hex(), service.Review{Operator: "bob", Note: "unknown recipient"})
+	require.NoError(t, err)
+	assert.Equal(t, "rejected", decision)
+	assert.False(t, waitForWithdrawalOutcome(t, env, rejected, 30*time.Second), "expected rejected withdrawal to fail")
+
+	recorded = waitForDecision(t, svc, rejected, time.Second)
+	assert.Equal(t, "bob", recorded.ReviewedBy)
+	assert.Equal(t, "rejected on review by bob: unknown recipient", recorded.Reason)
+
+	_, err = svc.ReviewHeld(ctx, "", common.Hash(rejected).Hex(), service.Review{Approve: true, Operator: "alice"})
+	require.ErrorIs(t, err, service.ErrHoldNotFound)
+}
+
+func TestReviewWhilePaused(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	svc := newReviewService(t, env, config.ReviewConfig{Timeout: time.Hour, CheckInterval: 200 * time.Millisecond})
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(1e17), 1)
+	require.Equal(t, store.DecisionHeld, waitForDecision(t, svc, withdrawalID, 30*time.Second).Decision)
+	nonce, err := env.client.PendingNonceAt(ctx, env.nitewatchAddr())
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Pause("alice", "incident"))
+	_, err = svc.ReviewHeld(ctx, "", common.Hash(withdrawalID).Hex(), service.Review{Approve: true, Operator: "bob"})
+	require.ErrorIs(t, err, service.ErrPaused)
+
+	sent, err := env.client.PendingNonceAt(ctx, env.nitewatchAddr())
+	require.NoError(t, err)
+	assert.Equal(t, nonce, sent, "no transaction was sent")
+	recorded := waitForDecision(t, svc, withdrawalID, time.Second)
+	assert.Equal(t, store.DecisionHeld, recorded.Decision)
+	assert.Equal(t, "review", recorded.ReasonCode, "the hold is left for review")
+
+	require.NoError(t, svc.Resume("alice"))
+	decision, err := svc.ReviewHeld(ctx, "", common.Hash(withdrawalID).Hex(), service.Review{Approve: true, Operator: "bob"})
+	require.NoError(t, err)
+	assert.Equal(t, "approved", decision)
+	assert.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected withdrawal approved after resuming to be finalized")
+}
+
+func TestHeldWithdrawalExpires(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	svc := newReviewService(t, env, config.ReviewConfig{Timeout: 30 * time.Second, CheckInterval: 200 * time.Millisecond})
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(1e17), 1)
+	assert.False(t, waitForWithdrawalOutcome(t, env, withdrawalID, 60*time.Second), "expected unreviewed withdrawal to be rejected")
+
+	recorded := waitForDecision(t, svc, withdrawalID, time.Second)
+	assert.Equal(t, "rejected", recorded.Decision)
+	assert.Contains(t, recorded.Reason, "not reviewed by the deadline")
+
+	_, err := svc.ReviewHeld(ctx, "", common.Hash(withdrawalID).Hex(), service.Review{Approve: true, Operator: "alice"})
+	require.ErrorIs(t, err, service.ErrHoldNotFound)
+}
+
+func TestReviewMarginExceedsOperationExpiry(t *testing.T) {
+	env := newTestEnv(t)
+
+	conf := pauseTestConfig(t, env)
+	conf.Blockchain.OperationExpiry = 5 * time.Minute
+	conf.Review.Margin = 5 * time.Minute
+
+	// Every held withdrawal would be past its deadline as soon as it is held.
+	_, err := service.NewWithBackend(conf, env.client)
+	require.ErrorContains(t, err, "must exceed review.margin")
+}
+
+// pauseTestConfig has a 0.5 ETH daily limit and checks held withdrawals
+// every 200ms.
+func pauseTestConfig(t *testing.T, env *testEnv) config.Config {
+	return config.Config{
+		Blockchain: config.BlockchainConfig{
+			ContractAddr:       env.addr.Hex(),
+			PrivateKey:         fmt.Sprintf("%x", crypto.FromECDSA(env.nitewatchKey())),
+			ConfirmationBlocks: 1,
+			PollInterval:       200 * time.Millisecond,
+		},
+		Limits: config.LimitsConfig{
+			nativeToken: config.LimitConfig{Daily: "0.5 ETH"},
+		},
+		Review:     config.ReviewConfig{Timeout: time.Hour, CheckInterval: 200 * time.Millisecond},
+		DBPath:     filepath.Join(t.TempDir(), "nitewatch.db"),
+		ListenAddr: ":0",
+	}
+}
+
+func deposit(t *testing.T, env *testEnv, amount *big.Int) {
+	t.Helper()
+	userAuth := copyAuth(env.auths[3])
+	userAuth.Value = amount
+	_, err := env.contract.Deposit(userAuth, common.Address{}, amount)
+	require.NoError(t, err)
+	env.sim.Commit()
+}
+
+func TestPauseAndResume(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := pauseTestConfig(t, env)
+
+	// The pause state is kept across restarts.
+	paused, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	require.False(t, paused.Paused().Paused)
+	require.NoError(t, paused.Pause("alice", "incident"))
+
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	state := svc.Paused()
+	require.True(t, state.Paused)
+	assert.Equal(t, "alice", state.By)
+	assert.Equal(t, "incident", state.Reason)
+	runNitewatchService(t, svc)
+	deposit(t, env, big.NewInt(1e18))
+
+	held := startWithdraw(t, env, big.NewInt(1e17), 1)
+	recorded := waitForDecision(t, svc, held, 30*time.Second)
+	require.Equal(t, store.DecisionHeld, recorded.Decision)
+	assert.Equal(t, service.ReasonPaused, recorded.ReasonCode)
+	assert.Equal(t, "worker paused: incident", recorded.Reason)
+
+	// Withdrawals the policy rejects are still rejected.
+	rejected := startWithdraw(t, env, big.NewInt(6e17), 2)
+	assert.False(t, waitForWithdrawalOutcome(t, env, rejected, 30*time.Second), "expected withdrawal over the limit to be rejected while paused")
+
+	require.NoError(t, svc.Resume("bob"))
+	assert.False(t, svc.Paused().Paused)
+	assert.True(t, waitForWithdrawalOutcome(t, env, held, 30*time.Second), "expected held withdrawal to be finalized after resuming")
+	assert.Equal(t, "approved", waitForDecision(t, svc, held, 10*time.Second).Decision)
+}
+
+func TestPausedHoldOutlastsReviewTimeout(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	// The simulated chain advances a second per block, so the review
+	// timeout passes within a second.
+	conf := pauseTestConfig(t, env)
+	conf.Review.Timeout = 5 * time.Second
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	require.NoError(t, svc.Pause("alice", "incident"))
+	runNitewatchService(t, svc)
+	deposit(t, env, big.NewInt(1e18))
+
+	held := startWithdraw(t, env, big.NewInt(1e17), 1)
+	recorded := waitForDecision(t, svc, held, 30*time.Second)
+	require.Equal(t, store.DecisionHeld, recorded.Decision)
+
+	// The contract has no operation expiry, so the hold waits for the pause
+	// to end however long it lasts.
+	time.Sleep(3 * time.Second)
+	recorded = waitForDecision(t, svc, held, time.Second)
+	require.Equal(t, store.DecisionHeld, recorded.Decision)
+	require.Equal(t, service.ReasonPaused, recorded.ReasonCode)
+
+	require.NoError(t, svc.Resume("bob"))
+	assert.True(t, waitForWithdrawalOutcome(t, env, held, 30*time.Second), "expected held withdrawal to be finalized after resuming")
+}
+
+func TestCircuitBreakerConsecutiveRejections(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := pauseTestConfig(t, env)
+	conf.CircuitBreaker.MaxConsecutiveRejections = 2
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+	deposit(t, env, big.NewInt(2e18))
+
+	for nonce := int64(1); nonce <= 2; nonce++ {
+		withdrawalID := startWithdraw(t, env, big.NewInt(6e17), nonce)
+		require.False(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected withdrawal over the limit to be rejected")
+	}
+	state := svc.Paused()
+	require.True(t, state.Paused)
+	assert.Equal(t, "circuit_breaker", state.By)
+	assert.Equal(t, "2 consecutive withdrawals rejected by policy", state.Reason)
+
+	held := startWithdraw(t, env, big.NewInt(1e17), 3)
+	recorded := waitForDecision(t, svc, held, 30*time.Second)
+	assert.Equal(t, store.DecisionHeld, recorded.Decision)
+	assert.Equal(t, service.ReasonPaused, recorded.ReasonCode)
+}
+
+func TestCircuitBreakerOutflow(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := pauseTestConfig(t, env)
+	conf.CircuitBreaker.MaxOutflowPercent = 25
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+	deposit(t, env, big.NewInt(1e18))
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(2e17), 1)
+	require.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected 0.2 of 1 ETH to be finalized")
+
+	// 0.2 + 0.1 ETH within the hour is over 25% of the remaining 0.8 ETH.
+	held := startWithdraw(t, env, big.NewInt(1e17), 2)
+	recorded := waitForDecision(t, svc, held, 30*time.Second)
+	assert.Equal(t, store.DecisionHeld, recorded.Decision)
+	assert.Equal(t, service.ReasonPaused, recorded.ReasonCode)
+	assert.Contains(t, recorded.Reason, "over 25% of the custody balance 800000000000000000")
+	state := svc.Paused()
+	assert.True(t, state.Paused)
+	assert.Equal(t, "circuit_breaker", state.By)
+}
+
+// failingBalanceClient fails reading balances while failing is set.
+type failingBalanceClient struct {
+	custody.EthBackend
+	failing atomic.Bool
+}
+
+func (c *failingBalanceClient) BalanceAt(ctx context.Context, account common.Address, block *big.Int) (*big.Int, error) {
+	if c.failing.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return c.EthBackend.BalanceAt(ctx, account, block)
+}
+
+func TestCircuitBreakerOutflowUnavailable(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := pauseTestConfig(t, env)
+	conf.CircuitBreaker.MaxOutflowPercent = 50
+	// Leave reading the balance to the circuit breaker.
+	conf.Policy.Actions = map[string]string{checker.RuleLiquidity: "log"}
+	client := &failingBalanceClient{EthBackend: env.client}
+	svc, err := service.NewWithBackend(conf, client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+	deposit(t, env, big.NewInt(1e18))
+
+	client.failing.Store(true)
+	withdrawalID := startWithdraw(t, env, big.NewInt(1e17), 1)
+	recorded := waitForDecision(t, svc, withdrawalID, 30*time.Second)
+	require.Equal(t, store.DecisionHeld, recorded.Decision)
+	assert.Equal(t, service.ReasonOutflowUnavailable, recorded.ReasonCode)
+	assert.False(t, svc.Paused().Paused)
+
+	// Later withdrawals are not stuck behind it.
+	next := startWithdraw(t, env, big.NewInt(1e17), 2)
+	assert.Equal(t, service.ReasonOutflowUnavailable, waitForDecision(t, svc, next, 30*time.Second).ReasonCode)
+
+	client.failing.Store(false)
+	assert.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected held withdrawal to be finalized once the balance can be read")
+	assert.True(t, waitForWithdrawalOutcome(t, env, next, 30*time.Second), "expected held withdrawal to be finalized once the balance can be read")
+}
+
+func TestLiquidityHoldReleasedAfterTopUp(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := pauseTestConfig(t, env)
+	conf.Limits = config.LimitsConfig{
+		nativeToken: config.LimitConfig{Daily: "10 ETH", Reserve: "0.3 ETH"},
+	}
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+	deposit(t, env, big.NewInt(1e18))
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(5e17), 1)
+	require.True(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected withdrawal keeping the reserve to be finalized")
+
+	// 0.5 ETH are left, which would fall below the reserve.
+	held := startWithdraw(t, env, big.NewInt(4e17), 2)
+	recorded := waitForDecision(t, svc, held, 30*time.Second)
+	require.Equal(t, store.DecisionHeld, recorded.Decision)
+	assert.Equal(t, checker.ReasonReserveFloor, recorded.ReasonCode)
+	assert.Contains(t, recorded.Reason, "withdrawal would breach reserve floor")
+
+	deposit(t, env, big.NewInt(5e17))
+	assert.True(t, waitForWithdrawalOutcome(t, env, held, 30*time.Second), "expected held withdrawal to be finalized after the top-up")
+	assert.Equal(t, "approved", waitForDecision(t, svc, held, 10*time.Second).Decision)
+}
+
+func TestLiquidityReject(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	conf := pauseTestConfig(t, env)
+	conf.Limits = config.LimitsConfig{
+		nativeToken: config.LimitConfig{Daily: "10 ETH"},
+	}
+	conf.Policy.Actions = map[string]string{checker.RuleLiquidity: "reject"}
+	svc, err := service.NewWithBackend(conf, env.client)
+	require.NoError(t, err)
+	runNitewatchService(t, svc)
+	deposit(t, env, big.NewInt(1e18))
+
+	withdrawalID := startWithdraw(t, env, big.NewInt(2e18), 1)
+	require.False(t, waitForWithdrawalOutcome(t, env, withdrawalID, 30*time.Second), "expected withdrawal over the balance to be rejected")
+	recorded := waitForDecision(t, svc, withdrawalID, 10*time.Second)
+	assert.Equal(t, "rejected", recorded.Decision)
+	assert.Equal(t, checker.ReasonInsufficientLiquidity, recorded.ReasonCode)
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	env := newTestEnv(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go autoCommit(ctx, env.sim, 100*time.Millisecond)
+
+	svc := createNitewatchService(t, env, "100000000000000000000")
+
+	_, err := svc.ReplayDeadLetter(ctx, "", 1)
+	require.ErrorIs(t, err, service.ErrDeadLetterNotFound)
+
+	depositAmount := big.NewInt(1e18)
+	userAuth := copyAuth(env.auths[3])
+	userAuth.Value = depositAmount
+	_, err = env.contract.Deposit(userAuth, common.Address{}, depositAmount)
+	require.NoError(t, err)
+	env.sim.Commit()
+
+	tx, err := env.contract.StartWithdraw(copyAuth(env.neodaxAuth()), env.userAddr(), common.Address{}, big.NewInt(1e17), big.NewInt(1))
+	require.NoError(t, err)
+	env.sim.Commit()
+	receipt, err := env.client.TransactionReceipt(context.Background(), tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), receipt.Status, "startWithdraw tx failed")
+	require.Len(t, receipt.Logs, 1)
+
+	// Dead-letter the request as if the listener had failed to decode it.
+	gormDB, err := gorm.Open(sqlite.Open(svc.Config.DBPath), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := store.NewAdapter(gormDB)
+	require.NoError(t, err)
+	require.NoError(t, db.SaveDeadLetter("withdraw-started", *receipt.Logs[0], "unknown event topic"))
+	letters, err := db.ListDeadLetters(false)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+
+	event, err := svc.ReplayDeadLetter(ctx, "", letters[0].ID)
+	require.NoError(t, err)
+	require.IsType(t, &custody.WithdrawStartedEvent{}, event)
+
+	ev := waitForWithdrawFinalized(t, env, 30*time.Second)
+	assert.True(t, ev.Success, "expected replayed withdrawal to be finalized")
+
+	letters, err = db.ListDeadLetters(false)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
 // copyAuth creates a shallow copy of TransactOpts so concurrent uses don't race.
 func copyAuth(auth *bind.TransactOpts) *bind.TransactOpts {
 	cp := *auth
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/layer-3/nitewatch/config"
+	"github.com/layer-3/nitewatch/custody"
+	"github.com/layer-3/nitewatch/internal/checker"
+	"github.com/layer-3/nitewatch/internal/store"
+	"github.com/layer-3/nitewatch/policy"
+)
+
+// balanceTimeout bounds reading the balance of a custody contract for the
+// liquidity rule.
+const balanceTimeout = 10 * time.Second
+
+// liquiditySource reads the balances of the chain's custody contracts from
+// the chain and the withdrawals committed against them from its store.
+type liquiditySource struct {
+	ch *chain
+}
+
+func (s liquiditySource) Balance(contract, token common.Address) (*big.Int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), balanceTimeout)
+	defer cancel()
+	return tokenBalance(ctx, s.ch.ethClient, token, contract)
+}
+
+// Committed sums the withdrawals from contract of token still committed.
+// Withdrawals requested more than the contract's expiry before at can no
+// longer be finalized and are not counted; those other signers finalized are
+// settled by processHolds.
+func (s liquiditySource) Committed(contract, token common.Address, withdrawalID [32]byte, at time.Time) (*big.Int, error) {
+	var since time.Time
+	if c := s.ch.custodyAt(contract); c != nil && c.expiry > 0 {
+		since = at.Add(-c.expiry)
+	}
+	return s.ch.store.GetCommittedAmount(contract, token, common.Hash(withdrawalID).Hex(), since)
+}
+
+// settlePending records the withdrawals awaiting the approval threshold that
+// other signers finalized on-chain, so they no longer count as committed. It
+// runs with the held withdrawal processor rather than in the liquidity rule,
+// which would cost a call per pending withdrawal on every evaluation.
+// Withdrawals past their contract's expiry can no longer be finalized and are
+// not looked up.
+func (ch *chain) settlePending(ctx context.Context) error {
+	var now time.Time
+	for _, c := range ch.contracts {
+		pending, err := ch.store.ListPendingWithdrawEvents(c.address)
+		if err != nil {
+			return fmt.Errorf("failed to list withdrawals awaiting approvals: %w", err)
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		if c.expiry > 0 && now.IsZero() {
+			if now, err = ch.chainTime(ctx); err != nil {
+				return err
+			}
+		}
+		if err := ch.settlePendingOf(ctx, c, pending, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ch *chain) settlePendingOf(ctx context.Context, c *custodyContract, pending []store.WithdrawEventModel, now time.Time) error {
+	caller, err := custody.NewQuorumCustodyCaller(c.address, ch.ethClient)
+	if err != nil {
+		return fmt.Errorf("failed to bind QuorumCustody caller: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, balanceTimeout)
+	defer cancel()
+	for _, ev := range pending {
+		if c.expiry > 0 && ev.BlockTime.Before(now.Add(-c.expiry)) {
+			continue
+		}
+		w, err := caller.Withdrawals(&bind.CallOpts{Context: ctx}, common.HexToHash(ev.WithdrawalID))
+		if err != nil {
+			if isContractRevert(err) {
+				// The contract does not expose its withdrawals; they count
+				// until they expire.
+				return nil
+			}
+			return fmt.Errorf("failed to get status of withdrawal %s: %w", ev.WithdrawalID, err)
+		}
+		if !w.Finalized {
+			continue
+		}
+		if err := ch.store.CompletePendingWithdrawEvent(ev.WithdrawalID); err != nil {
+			return fmt.Errorf("failed to settle withdrawal %s: %w", ev.WithdrawalID, err)
+		}
+		ch.logger.Info("Withdrawal awaiting approvals was finalized on-chain", "withdrawal_id", ev.WithdrawalID)
+	}
+	return nil
+}
+
+// parseReserves parses the reserves of the limits configuration.
+func parseReserves(lc config.LimitsConfig, tokens *tokenResolver) (map[common.Address]*big.Int, error) {
+	reserves := make(map[common.Address]*big.Int)
+	for addrStr, conf := range lc {
+		if conf.Reserve == "" {
+			continue
+		}
+		addr := common.HexToAddress(addrStr)
+		parseAmount, err := tokens.amountParser(addr, conf)
+		if err != nil {
+			return nil, err
+		}
+		if reserves[addr], err = parseAmount(conf.Reserve); err != nil {
+			return nil, fmt.Errorf("invalid reserve for %s: %w", addrStr, err)
+		}
+	}
+	return reserves, nil
+}
+
+// isLiquidityHold reports whether a withdrawal was held because its contract
+// could not pay it, or its liquidity could not be read.
+func isLiquidityHold(ev *store.WithdrawEventModel) bool {
+	switch ev.ReasonCode {
+	case checker.ReasonInsufficientLiquidity, checker.ReasonReserveFloor, checker.ReasonLiquidityUnavailable:
+		return true
+	}
+	return false
+}
+
+// reportLiquidity publishes the shortfall of the contract and token of a
+// withdrawal the liquidity rule checked, and asks for a top-up if there is
+// one.
+func (ch *chain) reportLiquidity(logger *slog.Logger, req *policy.Request) {
+	l, ok := req.Annotation(checker.AnnotationLiquidity).(*checker.Liquidity)
+	if !ok {
+		return
+	}
+	shortfall, _ := new(big.Float).SetInt(l.Shortfall).Float64()
+	liquidityShortfallGauge.WithLabelValues(ch.name, req.Contract.Hex(), req.Token.Hex()).Set(shortfall)
+	if l.Shortfall.Sign() > 0 {
+		logger.Error("Custody contract needs a top-up to pay withdrawals",
+			"shortfall", l.Shortfall, "balance", l.Balance, "committed", l.Committed, "reserve", l.Reserve)
+	}
+}
+
+// liquidityRestored reports whether the contract of a withdrawal held for
+// lack of liquidity can now pay it. Like the re-evaluation of the withdrawal,
+// it checks as of the time the withdrawal was requested.
+func (ch *chain) liquidityRestored(held *store.WithdrawEventModel) bool {
+	amount, ok := new(big.Int).SetString(held.Amount, 10)
+	if !ok {
+		return false
+	}
+	_, err := ch.checker.CheckLiquidity(common.HexToAddress(held.Contract), common.HexToHash(held.WithdrawalID),
+		common.HexToAddress(held.TokenAddress), amount, held.BlockTime)
+	return err == nil
+}
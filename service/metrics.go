@@ -0,0 +1,50 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	listenerUpGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "nitewatch",
+		Subsystem: "worker",
+		Name:      "listener_up",
+		Help:      "Whether the event listener of a stream is running (1) or failed (0).",
+	}, []string{"stream"})
+
+	listenerRestartsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nitewatch",
+		Subsystem: "worker",
+		Name:      "listener_restarts_total",
+		Help:      "Number of times the event listener of a stream was restarted after failing.",
+	}, []string{"stream"})
+
+	healthyGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "nitewatch",
+		Subsystem: "worker",
+		Name:      "healthy",
+		Help:      "Whether the worker is healthy (1) or degraded (0).",
+	})
+
+	pausedGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "nitewatch",
+		Subsystem: "worker",
+		Name:      "paused",
+		Help:      "Whether the worker is paused and holds withdrawals (1) or not (0).",
+	})
+
+	circuitBreakerTripsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "nitewatch",
+		Subsystem: "worker",
+		Name:      "circuit_breaker_trips_total",
+		Help:      "Number of times the circuit breaker paused the worker.",
+	})
+
+	liquidityShortfallGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "nitewatch",
+		Subsystem: "worker",
+		Name:      "liquidity_shortfall",
+		Help:      "Amount of a token, in base units, a custody contract lacked to pay the last withdrawal checked and keep its reserve.",
+	}, []string{"chain", "contract", "token"})
+)
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/layer-3/nitewatch/custody"
+	"github.com/layer-3/nitewatch/internal/store"
+)
+
+// ReasonPaused is the reason code of withdrawals held because the worker was
+// paused. They are evaluated again once it resumes.
+const ReasonPaused = "paused"
+
+// ReasonOutflowUnavailable is the reason code of withdrawals held because the
+// custody balance the circuit breaker compares their outflow to could not be
+// read. They are evaluated again by the held withdrawal processor of their
+// chain.
+const ReasonOutflowUnavailable = "outflow_unavailable"
+
+// errOutflowUnavailable is returned by outflowTrip when the custody balance
+// cannot be read.
+var errOutflowUnavailable = errors.New("custody balance unavailable")
+
+// circuitBreaker is the operator recorded for pauses the circuit breaker
+// trips.
+const circuitBreaker = "circuit_breaker"
+
+// PauseState is whether the worker is paused, and by whom and why it was
+// last paused or resumed.
+type PauseState struct {
+	Paused bool      `json:"paused"`
+	Reason string    `json:"reason,omitempty"`
+	By     string    `json:"by,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// loadPause restores the pause state stored by a previous run. The worker is
+// paused if any chain's store says so, in case saving the state to all of
+// them failed halfway.
+func (svc *Service) loadPause() error {
+	var latest *store.PauseModel
+	for _, ch := range svc.chains {
+		p, err := ch.store.GetPause()
+		if err != nil {
+			return fmt.Errorf("chain %s: %w", ch.name, err)
+		}
+		if p == nil {
+			continue
+		}
+		if latest == nil || (p.Paused && !latest.Paused) || (p.Paused == latest.Paused && p.ChangedAt.After(latest.ChangedAt)) {
+			latest = p
+		}
+	}
+	if latest != nil {
+		svc.pause = PauseState{Paused: latest.Paused, Reason: latest.Reason, By: latest.ChangedBy, Since: latest.ChangedAt}
+	}
+	if svc.pause.Paused {
+		pausedGauge.Set(1)
+		svc.Logger.Warn("Worker is paused, holding withdrawals until resumed", "reason", svc.pause.Reason, "by", svc.pause.By, "since", svc.pause.Since)
+	} else {
+		pausedGauge.Set(0)
+	}
+	return nil
+}
+
+// Paused returns the pause state of the worker.
+func (svc *Service) Paused() PauseState {
+	svc.pauseMu.Lock()
+	defer svc.pauseMu.Unlock()
+	return svc.pause
+}
+
+// Pause makes the worker hold every withdrawal it would otherwise finalize,
+// until Resume is called. Withdrawals the policy rejects are still rejected.
+// The state is kept across restarts.
+func (svc *Service) Pause(by, reason string) error {
+	svc.pauseMu.Lock()
+	defer svc.pauseMu.Unlock()
+	return svc.pauseLocked(by, reason)
+}
+
+func (svc *Service) pauseLocked(by, reason string) error {
+	if svc.pause.Paused {
+		return nil
+	}
+	// Pause in memory even if the state cannot be saved.
+	svc.pause = PauseState{Paused: true, Reason: reason, By: by, Since: time.Now().UTC()}
+	pausedGauge.Set(1)
+	svc.Logger.Warn("Worker paused, holding withdrawals until resumed", "reason", reason, "by", by)
+	return svc.savePause()
+}
+
+// Resume ends a pause. Withdrawals held because of it are evaluated again by
+// the held withdrawal processor of their chain. Those from contracts with an
+// operation expiry are rejected instead if their review deadline passed; the
+// others get their full review time again if held anew.
+func (svc *Service) Resume(by string) error {
+	svc.pauseMu.Lock()
+	defer svc.pauseMu.Unlock()
+	if !svc.pause.Paused {
+		return nil
+	}
+	prev := svc.pause
+	svc.pause = PauseState{By: by, Since: time.Now().UTC()}
+	if err := svc.savePause(); err != nil {
+		svc.pause = prev
+		return err
+	}
+	svc.rejections = 0
+	pausedGauge.Set(0)
+	svc.Logger.Info("Worker resumed", "by", by)
+	for _, ch := range svc.chains {
+		select {
+		case ch.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// savePause stores the current pause state on every chain.
+func (svc *Service) savePause() error {
+	var errs []error
+	for _, ch := range svc.chains {
+		err := ch.store.SavePause(&store.PauseModel{
+			Paused:    svc.pause.Paused,
+			Reason:    svc.pause.Reason,
+			ChangedBy: svc.pause.By,
+			ChangedAt: svc.pause.Since,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chain %s: failed to save pause state: %w", ch.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendTimeout bounds broadcasting a finalize transaction, during which the
+// worker cannot be paused.
+const sendTimeout = 10 * time.Second
+
+// unlessPaused runs fn unless the worker is paused, and keeps it from being
+// paused until fn returns. fn is given at most sendTimeout so that a hung node
+// cannot hold up pausing or resuming.
+func (svc *Service) unlessPaused(ctx context.Context, fn func(context.Context) error) error {
+	svc.pauseMu.Lock()
+	defer svc.pauseMu.Unlock()
+	if svc.pause.Paused {
+		return ErrPaused
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// trip pauses the worker on behalf of the circuit breaker.
+func (svc *Service) trip(reason string) {
+	svc.pauseMu.Lock()
+	defer svc.pauseMu.Unlock()
+	svc.tripLocked(reason)
+}
+
+func (svc *Service) tripLocked(reason string) {
+	if svc.pause.Paused {
+		return
+	}
+	circuitBreakerTripsCounter.Inc()
+	if err := svc.pauseLocked(circuitBreaker, reason); err != nil {
+		svc.Logger.Error("Failed to save pause state", "error", err)
+	}
+}
+
+// countRejection counts a withdrawal rejected by the policy and trips the
+// circuit breaker after too many in a row.
+func (svc *Service) countRejection() {
+	limit := svc.Config.CircuitBreaker.MaxConsecutiveRejections
+	if limit <= 0 {
+		return
+	}
+	svc.pauseMu.Lock()
+	defer svc.pauseMu.Unlock()
+	svc.rejections++
+	if svc.rejections >= limit {
+		svc.tripLocked(fmt.Sprintf("%d consecutive withdrawals rejected by policy", svc.rejections))
+	}
+}
+
+// countApproval ends a run of rejected withdrawals.
+func (svc *Service) countApproval() {
+	svc.pauseMu.Lock()
+	defer svc.pauseMu.Unlock()
+	svc.rejections = 0
+}
+
+// outflowTrip returns why finalizing event would trip the outflow trigger of
+// the circuit breaker, or "" if it would not: the amount of its token
+// withdrawn within the outflow window, counting it, would exceed the
+// configured percentage of the balance of the chain's custody contracts. Errors
+// reading the balance wrap errOutflowUnavailable.
+func (ch *chain) outflowTrip(ctx context.Context, event *custody.WithdrawStartedEvent) (string, error) {
+	conf := ch.svc.Config.CircuitBreaker
+	if conf.MaxOutflowPercent <= 0 {
+		return "", nil
+	}
+	window := conf.OutflowWindow
+	if window <= 0 {
+		window = time.Hour
+	}
+	withdrawn, err := ch.store.GetTotalWithdrawn(event.Token, eventTime(event).Add(-window), eventTime(event))
+	if err != nil {
+		return "", fmt.Errorf("failed to get withdrawn amount: %w", err)
+	}
+	outflow := new(big.Int).Add(withdrawn, event.Amount)
+
+	ctx, cancel := context.WithTimeout(ctx, balanceTimeout)
+	defer cancel()
+	balance := new(big.Int)
+	for _, c := range ch.contracts {
+		b, err := tokenBalance(ctx, ch.ethClient, event.Token, c.address)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %w", errOutflowUnavailable, c.label, err)
+		}
+		balance.Add(balance, b)
+	}
+
+	threshold := new(big.Rat).SetInt(balance)
+	threshold.Mul(threshold, new(big.Rat).SetFloat64(conf.MaxOutflowPercent/100))
+	if new(big.Rat).SetInt(outflow).Cmp(threshold) <= 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("outflow of %s within %s would be %s, over %g%% of the custody balance %s",
+		event.Token.Hex(), window, outflow, conf.MaxOutflowPercent, balance), nil
+}
+
+// holdPaused holds a withdrawal the policy allowed because the worker is
+// paused.
+func (ch *chain) holdPaused(logger *slog.Logger, c *custodyContract, baseModel *store.WithdrawEventModel, reason string) error {
+	baseModel.ReasonCode = ReasonPaused
+	return ch.hold(logger, c, baseModel, fmt.Errorf("worker paused: %s", reason))
+}
+
+type pauseRequest struct {
+	Operator string `json:"operator" binding:"required,max=64"`
+	Reason   string `json:"reason"`
+}
+
+func (svc *Service) handleGetPause(c *gin.Context) {
+	c.JSON(http.StatusOK, svc.Paused())
+}
+
+func (svc *Service) handlePause(c *gin.Context) {
+	var req pauseRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "operator and reason are required"})
+		return
+	}
+	if err := svc.Pause(req.Operator, req.Reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, svc.Paused())
+}
+
+func (svc *Service) handleResume(c *gin.Context) {
+	var req pauseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "operator is required"})
+		return
+	}
+	if err := svc.Resume(req.Operator); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, svc.Paused())
+}
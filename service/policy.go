@@ -0,0 +1,77 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/layer-3/nitewatch/internal/checker"
+	"github.com/layer-3/nitewatch/policy"
+)
+
+// Option configures a Service.
+type Option func(*options)
+
+type options struct {
+	rules []customRule
+}
+
+type customRule struct {
+	rule   policy.Rule
+	action policy.Action
+}
+
+// WithRule adds a rule to the policy withdrawals are evaluated with on every
+// chain. Custom rules are evaluated after the built-in ones, in the order
+// they are added, and the name of a violated rule is recorded as the reason
+// code of the decision.
+func WithRule(rule policy.Rule, action policy.Action) Option {
+	return func(o *options) {
+		o.rules = append(o.rules, customRule{rule: rule, action: action})
+	}
+}
+
+// defaultActions are the actions of the built-in rules that do not reject
+// violations by default. Anomalies are statistical and left to an operator,
+// withdrawals the custody contract cannot pay wait for it to be topped up,
+// and those whose fiat value cannot be determined wait for the price source
+// to recover.
+var defaultActions = map[string]policy.Action{
+	checker.RulePrices:    policy.ActionHold,
+	checker.RuleAnomaly:   policy.ActionHold,
+	checker.RuleLiquidity: policy.ActionHold,
+}
+
+// newPolicy builds the policy of a chain from the built-in rules of its
+// checker, with the actions configured for them, followed by the custom rules.
+func newPolicy(c *checker.Checker, actions map[string]string, custom []customRule) (*policy.Policy, map[string]bool, error) {
+	builtin := make(map[string]bool)
+	p := policy.New()
+	for _, rule := range c.Rules() {
+		builtin[rule.Name()] = true
+		action, ok := defaultActions[rule.Name()]
+		if !ok {
+			action = policy.ActionReject
+		}
+		if s, ok := actions[rule.Name()]; ok {
+			if rule.Name() == checker.RuleSanity {
+				return nil, nil, fmt.Errorf("the action of policy rule %s cannot be changed", rule.Name())
+			}
+			var err error
+			if action, err = policy.ParseAction(s); err != nil {
+				return nil, nil, err
+			}
+		}
+		p.Add(rule, action)
+	}
+	for name := range actions {
+		if !builtin[name] {
+			return nil, nil, fmt.Errorf("unknown policy rule %s", name)
+		}
+	}
+	for _, r := range custom {
+		if builtin[r.rule.Name()] {
+			return nil, nil, fmt.Errorf("custom policy rule %s shadows a built-in rule", r.rule.Name())
+		}
+		p.Add(r.rule, r.action)
+	}
+	return p, builtin, nil
+}
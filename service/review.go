@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"github.com/layer-3/nitewatch/custody"
+	"github.com/layer-3/nitewatch/internal/checker"
+	"github.com/layer-3/nitewatch/internal/store"
+)
+
+var (
+	// ErrHoldNotFound is returned when reviewing a withdrawal that is not held.
+	ErrHoldNotFound = errors.New("held withdrawal not found")
+	// ErrReviewExpired is returned when approving a held withdrawal after its
+	// review deadline.
+	ErrReviewExpired = errors.New("review deadline passed")
+	// ErrPaused is returned when approving a held withdrawal while the worker
+	// is paused.
+	ErrPaused = errors.New("worker paused")
+)
+
+// HeldWithdrawal is the API representation of a withdrawal held for review.
+type HeldWithdrawal struct {
+	Chain          string    `json:"chain"`
+	WithdrawalID   string    `json:"withdrawal_id"`
+	Contract       string    `json:"contract"`
+	ContractLabel  string    `json:"contract_label"`
+	User           string    `json:"user"`
+	Token          string    `json:"token"`
+	Amount         string    `json:"amount"`
+	Reason         string    `json:"reason"`
+	ReasonCode     string    `json:"reason_code"`
+	PriceSnapshot  string    `json:"price_snapshot,omitempty"`
+	AnomalyStats   string    `json:"anomaly_stats,omitempty"`
+	BlockNumber    uint64    `json:"block_number"`
+	TxHash         string    `json:"tx_hash"`
+	RequestedAt    time.Time `json:"requested_at"`
+	ReviewDeadline time.Time `json:"review_deadline"`
+}
+
+func newHeldWithdrawal(chain string, m *store.WithdrawEventModel) HeldWithdrawal {
+	held := HeldWithdrawal{
+		Chain:         chain,
+		WithdrawalID:  m.WithdrawalID,
+		Contract:      m.Contract,
+		ContractLabel: m.ContractLabel,
+		User:          m.UserAddress,
+		Token:         m.TokenAddress,
+		Amount:        m.Amount,
+		Reason:        m.Reason,
+		ReasonCode:    m.ReasonCode,
+		PriceSnapshot: m.PriceSnapshot,
+		AnomalyStats:  m.AnomalyStats,
+		BlockNumber:   m.BlockNumber,
+		TxHash:        m.TxHash,
+		RequestedAt:   m.BlockTime,
+	}
+	if m.ReviewDeadline != nil {
+		held.ReviewDeadline = *m.ReviewDeadline
+	}
+	return held
+}
+
+// Review is an operator's verdict on a held withdrawal.
+type Review struct {
+	Approve bool
+	// Operator identifies who reviewed the withdrawal in the recorded
+	// decision.
+	Operator string
+	Note     string
+}
+
+type reviewRequest struct {
+	Operator string `json:"operator" binding:"required,max=64"`
+	Note     string `json:"note"`
+}
+
+func (svc *Service) handleListHolds(c *gin.Context) {
+	resp := []HeldWithdrawal{}
+	for _, ch := range svc.chains {
+		events, err := ch.store.ListHeldWithdrawEvents()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range events {
+			resp = append(resp, newHeldWithdrawal(ch.name, &events[i]))
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (svc *Service) handleApproveHold(c *gin.Context) { svc.handleReview(c, true) }
+func (svc *Service) handleRejectHold(c *gin.Context)  { svc.handleReview(c, false) }
+
+func (svc *Service) handleReview(c *gin.Context, approve bool) {
+	var req reviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "operator is required"})
+		return
+	}
+	id := c.Param("id")
+	decision, err := svc.ReviewHeld(c.Request.Context(), c.Query("chain"), id, Review{Approve: approve, Operator: req.Operator, Note: req.Note})
+	switch {
+	case errors.Is(err, ErrHoldNotFound), errors.Is(err, ErrUnknownChain):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrReviewExpired), errors.Is(err, ErrPaused):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"withdrawal_id": id, "decision": decision})
+	}
+}
+
+// ReviewHeld approves or rejects a withdrawal held for review and sends the
+// corresponding transaction. It returns the recorded decision, which may be
+// "pending" or "error" like that of any approval. Approvals are refused with
+// ErrPaused while the worker is paused. chainName may be empty if only one
+// chain is configured.
+func (svc *Service) ReviewHeld(ctx context.Context, chainName, withdrawalID string, review Review) (string, error) {
+	ch := svc.chainNamed(chainName)
+	if ch == nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownChain, chainName)
+	}
+	return ch.review(ctx, common.HexToHash(withdrawalID).Hex(), review)
+}
+
+// reviewDeadline is the time until which a withdrawal requested at the given
+// time can be approved.
+func (ch *chain) reviewDeadline(c *custodyContract, requested time.Time) time.Time {
+	conf := ch.svc.Config.Review
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = 24 * time.Hour
+	}
+	if c.expiry > 0 {
+		timeout = min(timeout, c.expiry-conf.Margin)
+	}
+	return requested.Add(timeout)
+}
+
+// chainTime returns the timestamp of the latest block, which the custody
+// contract checks operation expiry against.
+func (ch *chain) chainTime(ctx context.Context) (time.Time, error) {
+	header, err := ch.ethClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest block: %w", err)
+	}
+	return time.Unix(int64(header.Time), 0), nil
+}
+
+func (ch *chain) review(ctx context.Context, withdrawalID string, review Review) (string, error) {
+	ch.txMu.Lock()
+	defer ch.txMu.Unlock()
+
+	held, err := ch.store.GetWithdrawEvent(withdrawalID)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up withdraw event: %w", err)
+	}
+	if held == nil || held.Decision != store.DecisionHeld {
+		return "", fmt.Errorf("%w: %s", ErrHoldNotFound, withdrawalID)
+	}
+	c, event, err := ch.heldWithdrawal(held)
+	if err != nil {
+		return "", err
+	}
+	if ch.checker.HasCrossChainLimit(event.Token) {
+		ch.svc.crossChainMu.Lock()
+		defer ch.svc.crossChainMu.Unlock()
+	}
+	logger := ch.logger.With(
+		"contract", c.label,
+		"withdrawal_id", withdrawalID,
+		"user", event.User.Hex(),
+		"token", event.Token.Hex(),
+		"amount", event.Amount,
+		"operator", review.Operator,
+	)
+
+	model := *held
+	model.ReviewedBy = review.Operator
+	if !review.Approve {
+		reason := fmt.Sprintf("rejected on review by %s", review.Operator)
+		if review.Note != "" {
+			reason += ": " + review.Note
+		}
+		logger.Info("Held withdrawal rejected by operator")
+		if err := ch.reject(ctx, logger, c, event, &model, errors.New(reason)); err != nil {
+			return "", err
+		}
+		return model.Decision, nil
+	}
+
+	now, err := ch.chainTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	if held.ReviewDeadline != nil && !now.Before(*held.ReviewDeadline) && !ch.waitsForResume(held) {
+		return "", fmt.Errorf("%w: %s", ErrReviewExpired, held.ReviewDeadline.Format(time.RFC3339))
+	}
+	if ch.svc.Paused().Paused {
+		return "", ErrPaused
+	}
+	model.Reason = fmt.Sprintf("approved on review by %s", review.Operator)
+	if review.Note != "" {
+		model.Reason += ": " + review.Note
+	}
+	logger.Info("Held withdrawal approved by operator")
+	if err := ch.finalize(ctx, logger, c, event, &model); err != nil {
+		return "", err
+	}
+	return model.Decision, nil
+}
+
+// heldWithdrawal reconstructs the request of a recorded withdraw event.
+func (ch *chain) heldWithdrawal(ev *store.WithdrawEventModel) (*custodyContract, *custody.WithdrawStartedEvent, error) {
+	c := ch.custodyAt(common.HexToAddress(ev.Contract))
+	if c == nil {
+		return nil, nil, fmt.Errorf("withdrawal from unknown custody contract %s", ev.Contract)
+	}
+	amount, ok := new(big.Int).SetString(ev.Amount, 10)
+	if !ok {
+		return nil, nil, fmt.Errorf("corrupted amount in withdraw event %s: %q", ev.WithdrawalID, ev.Amount)
+	}
+	return c, &custody.WithdrawStartedEvent{
+		WithdrawalID: common.HexToHash(ev.WithdrawalID),
+		User:         common.HexToAddress(ev.UserAddress),
+		Token:        common.HexToAddress(ev.TokenAddress),
+		Amount:       amount,
+		Contract:     c.address,
+		BlockNumber:  ev.BlockNumber,
+		BlockHash:    common.HexToHash(ev.BlockHash),
+		BlockTime:    ev.BlockTime,
+		TxHash:       common.HexToHash(ev.TxHash),
+		LogIndex:     ev.LogIndex,
+	}, nil
+}
+
+// processHolds rejects held withdrawals whose review deadline passed, so that
+// they do not silently expire on-chain, evaluates those held while the worker
+// was paused, for lack of liquidity or because their prices or outflow could
+// not be read again once that is over, and completes review transactions
+// interrupted by a restart. It also settles withdrawals awaiting approvals
+// that other signers finalized; see settlePending. Held withdrawals are past
+// the stream cursor, so the listener does not redeliver them.
+func (ch *chain) processHolds(ctx context.Context) {
+	ch.txMu.Lock()
+	defer ch.txMu.Unlock()
+
+	intents, err := ch.store.ListReviewIntents()
+	if err != nil {
+		ch.logger.Error("Failed to list review intents", "error", err)
+		return
+	}
+	for i := range intents {
+		if err := ch.resumeReview(ctx, &intents[i]); err != nil {
+			ch.logger.Error("Failed to resume review transaction", "withdrawal_id", intents[i].WithdrawalID, "error", err)
+		}
+	}
+
+	// Settle first so that liquidity freed by withdrawals finalized on-chain
+	// releases the holds below.
+	if err := ch.settlePending(ctx); err != nil {
+		ch.logger.Error("Failed to settle withdrawals awaiting approvals", "error", err)
+	}
+
+	held, err := ch.store.ListHeldWithdrawEvents()
+	if err != nil {
+		ch.logger.Error("Failed to list held withdrawals", "error", err)
+		return
+	}
+	if len(held) == 0 {
+		return
+	}
+	now, err := ch.chainTime(ctx)
+	if err != nil {
+		ch.logger.Error("Failed to check review deadlines", "error", err)
+		return
+	}
+	paused := ch.svc.Paused().Paused
+	for i := range held {
+		ev := &held[i]
+		waitsForResume := ch.waitsForResume(ev)
+		if waitsForResume && paused {
+			continue
+		}
+		if !waitsForResume && ev.ReviewDeadline != nil && !now.Before(*ev.ReviewDeadline) {
+			if err := ch.expireHold(ctx, ev); err != nil {
+				ch.logger.Error("Failed to reject held withdrawal past its review deadline", "withdrawal_id", ev.WithdrawalID, "error", err)
+			}
+			continue
+		}
+		if (ev.ReasonCode == ReasonPaused && !paused) ||
+			ev.ReasonCode == checker.ReasonPriceUnavailable || ev.ReasonCode == ReasonOutflowUnavailable ||
+			(isLiquidityHold(ev) && ch.liquidityRestored(ev)) {
+			if err := ch.reevaluate(ctx, ev, now); err != nil {
+				ch.logger.Error("Failed to evaluate held withdrawal again", "withdrawal_id", ev.WithdrawalID, "error", err)
+			}
+		}
+	}
+}
+
+// waitsForResume reports whether a withdrawal is held for a pause that does not
+// use up its review time: its contract has no operation expiry, so it waits
+// for the pause to end and is then evaluated again with a new deadline.
+func (ch *chain) waitsForResume(held *store.WithdrawEventModel) bool {
+	if held.ReasonCode != ReasonPaused {
+		return false
+	}
+	c := ch.custodyAt(common.HexToAddress(held.Contract))
+	return c != nil && c.expiry == 0
+}
+
+// reevaluate decides again on a withdrawal held for a reason that no longer
+// applies, as if it had just been requested. now is the time of the latest
+// block.
+func (ch *chain) reevaluate(ctx context.Context, held *store.WithdrawEventModel, now time.Time) error {
+	c, event, err := ch.heldWithdrawal(held)
+	if err != nil {
+		return err
+	}
+	if ch.checker.HasCrossChainLimit(event.Token) {
+		ch.svc.crossChainMu.Lock()
+		defer ch.svc.crossChainMu.Unlock()
+	}
+	logger := ch.logger.With(
+		"contract", c.label,
+		"withdrawal_id", held.WithdrawalID,
+		"user", event.User.Hex(),
+		"token", event.Token.Hex(),
+		"amount", event.Amount,
+	)
+	logger.Info("Evaluating held withdrawal again", "held_for", held.ReasonCode)
+
+	// The review deadline is kept so that an interrupted transaction is
+	// resumed like that of a review; see processHolds. Withdrawals that
+	// waited for a pause to end get their full review time from now.
+	model := *held
+	if held.ReasonCode == ReasonPaused && c.expiry == 0 {
+		deadline := ch.reviewDeadline(c, now)
+		model.ReviewDeadline = &deadline
+	}
+	model.Reason, model.ReasonCode = "", ""
+	model.PriceSnapshot, model.AnomalyStats = "", ""
+	return ch.decide(ctx, logger, c, event, &model)
+}
+
+func (ch *chain) expireHold(ctx context.Context, held *store.WithdrawEventModel) error {
+	c, event, err := ch.heldWithdrawal(held)
+	if err != nil {
+		return err
+	}
+	if ch.checker.HasCrossChainLimit(event.Token) {
+		ch.svc.crossChainMu.Lock()
+		defer ch.svc.crossChainMu.Unlock()
+	}
+	logger := ch.logger.With("contract", c.label, "withdrawal_id", held.WithdrawalID, "review_deadline", held.ReviewDeadline)
+	logger.Warn("Held withdrawal was not reviewed in time")
+
+	model := *held
+	return ch.reject(ctx, logger, c, event, &model, fmt.Errorf("not reviewed by the deadline, held: %s", held.Reason))
+}
+
+// resumeReview completes the decision of a review whose transaction was sent
+// by a previous run, or holds the withdrawal again if it was dropped.
+func (ch *chain) resumeReview(ctx context.Context, intent *store.WithdrawEventModel) error {
+	c, event, err := ch.heldWithdrawal(intent)
+	if err != nil {
+		return err
+	}
+	logger := ch.logger.With("contract", c.label, "withdrawal_id", intent.WithdrawalID, "operator", intent.ReviewedBy)
+
+	tx, receipt, err := ch.recoverInFlight(ctx, logger, intent)
+	if err != nil {
+		return err
+	}
+	model := *intent
+	if receipt == nil {
+		logger.Warn("Review transaction was dropped, holding withdrawal again", "action", intent.Action, "tx_hash", intent.ActionTxHash)
+		model.Decision = store.DecisionHeld
+		model.Reason = "review transaction was dropped, review again"
+		model.Action, model.ActionTxHash, model.ActionRawTx = "", "", ""
+		model.ReviewedBy = ""
+		return ch.recordEvent(logger, &model)
+	}
+	logger.Info("Recovered review transaction", "action", intent.Action, "tx_hash", tx.Hash().Hex())
+	if intent.Action == store.ActionReject {
+		return ch.completeReject(logger, &model, intent.Reason, receipt)
+	}
+	return ch.completeFinalize(logger, c, event, &model, tx, receipt)
+}
@@ -9,24 +9,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 
 	"github.com/layer-3/nitewatch/config"
 	"github.com/layer-3/nitewatch/custody"
+	"github.com/layer-3/nitewatch/internal/addrlist"
 	"github.com/layer-3/nitewatch/internal/checker"
-	"github.com/layer-3/nitewatch/internal/store"
+	"github.com/layer-3/nitewatch/internal/price"
 )
 
 type httpServer struct {
@@ -37,6 +35,7 @@ type httpServer struct {
 func newHTTPServer(addr string) *httpServer {
 	engine := gin.New()
 	engine.Use(gin.Recovery())
+	engine.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	return &httpServer{
 		Engine: engine,
 		server: &http.Server{Addr: addr, Handler: engine},
@@ -46,112 +45,368 @@ func newHTTPServer(addr string) *httpServer {
 func (s *httpServer) Run() error                         { return s.server.ListenAndServe() }
 func (s *httpServer) Shutdown(ctx context.Context) error { return s.server.Shutdown(ctx) }
 
+// reorgHistoryDepth is how many recently processed blocks are handed to the
+// listener on startup to locate the fork point of a reorg that happened
+// while the worker was not running.
+const reorgHistoryDepth = 64
+
 type Service struct {
 	Config config.Config
 	Logger *slog.Logger
 
-	web       *httpServer
-	ethClient custody.EthBackend
-	contract  *custody.IWithdraw
-	listener  *custody.Listener
-	auth      *bind.TransactOpts
-	checker   *checker.Checker
-	store     *store.Adapter
+	web    *httpServer
+	chains []*chain
+	// addressLists are the recipient list files, reloaded by the worker.
+	addressLists []*addrlist.List
 
 	workerReady int32
+
+	// crossChainMu serializes decisions on withdrawals under cross-chain
+	// limits, which depend on each other's outcome, across all chains.
+	crossChainMu sync.Mutex
+
+	healthMu sync.RWMutex
+	degraded map[string]error
+
+	// pauseMu guards pause and rejections, the number of withdrawals rejected
+	// by the policy in a row.
+	pauseMu    sync.Mutex
+	pause      PauseState
+	rejections int
 }
 
-// New creates a Service that dials an Ethereum node via the configured RPC URL.
-func New(conf config.Config) (*Service, error) {
-	client, err := ethclient.Dial(conf.Blockchain.RPCURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ethereum RPC: %w", err)
+// New creates a Service that dials an Ethereum node for every configured
+// chain via its RPC URL. When fallback RPC URLs are configured, all endpoints
+// of the chain are dialed and combined into a custody.MultiBackend.
+func New(conf config.Config, opts ...Option) (*Service, error) {
+	var clients []custody.EthBackend
+	closeClients := func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}
+	for _, chainConf := range conf.ChainConfigs() {
+		client, err := dialBackend(chainConf.Blockchain)
+		if err != nil {
+			closeClients()
+			if chainConf.Name != "" {
+				return nil, fmt.Errorf("chain %s: %w", chainConf.Name, err)
+			}
+			return nil, err
+		}
+		clients = append(clients, client)
 	}
 
-	svc, err := NewWithBackend(conf, client)
+	svc, err := NewWithBackends(conf, clients, opts...)
 	if err != nil {
-		client.Close()
+		closeClients()
 		return nil, err
 	}
 	return svc, nil
 }
 
-// NewWithBackend creates a Service using a pre-existing Ethereum backend.
-// The caller is responsible for closing the backend when done.
-func NewWithBackend(conf config.Config, client custody.EthBackend) (*Service, error) {
-	if conf.Blockchain.ConfirmationBlocks == 0 {
-		return nil, fmt.Errorf("confirmation_blocks must be > 0")
+// NewWithBackend creates a single-chain Service using a pre-existing Ethereum
+// backend. The caller is responsible for closing the backend when done.
+func NewWithBackend(conf config.Config, client custody.EthBackend, opts ...Option) (*Service, error) {
+	return NewWithBackends(conf, []custody.EthBackend{client}, opts...)
+}
+
+// NewWithBackends creates a Service using a pre-existing Ethereum backend for
+// every configured chain, in the order of conf.ChainConfigs(). The caller is
+// responsible for closing the backends when done.
+func NewWithBackends(conf config.Config, clients []custody.EthBackend, opts ...Option) (*Service, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	chainConfs := conf.ChainConfigs()
+	if len(clients) != len(chainConfs) {
+		return nil, fmt.Errorf("got %d backends for %d chains", len(clients), len(chainConfs))
 	}
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil)).With("service", "nitewatch")
 
 	srv := newHTTPServer(conf.ListenAddr)
 
-	gormDB, err := gorm.Open(sqlite.Open(conf.DBPath), &gorm.Config{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+	svc := &Service{
+		Config: conf,
+		Logger: logger,
+		web:    srv,
+	}
+	for i, chainConf := range chainConfs {
+		ch, err := newChain(svc, chainConf, clients[i])
+		if err != nil {
+			if chainConf.Name != "" {
+				return nil, fmt.Errorf("chain %s: %w", chainConf.Name, err)
+			}
+			return nil, err
+		}
+		svc.chains = append(svc.chains, ch)
 	}
 
-	db, err := store.NewAdapter(gormDB)
+	recipients, err := svc.loadRecipientLists()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize database: %w", err)
+		return nil, fmt.Errorf("failed to load recipient lists: %w", err)
 	}
 
-	globalLimits, err := parseLimitsConfig(conf.Limits)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse global limits: %w", err)
+	var anomaly *checker.AnomalyDetection
+	if conf.Anomaly.Enabled() {
+		a, err := parseAnomalyConfig(conf.Anomaly)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse anomaly detection: %w", err)
+		}
+		anomaly = &a
 	}
 
-	userOverrides, err := parseUserOverrides(conf.PerUserOverrides)
+	// Checkers are created once every chain's store exists, since cross-chain
+	// limits read the withdrawals of all chains.
+	userTierConf, err := parseUserTiers(conf.UserTiers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse per-user overrides: %w", err)
+		return nil, err
 	}
 
-	chk := checker.New(globalLimits, userOverrides, db)
+	tokens := make(map[string]*tokenResolver)
+	for _, ch := range svc.chains {
+		tokens[ch.name] = newTokenResolver(ch.ethClient)
+	}
+	for i, ch := range svc.chains {
+		globalLimits, err := parseLimitsConfig(chainConfs[i].Limits, tokens[ch.name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse global limits: %w", err)
+		}
 
-	chainID, err := client.ChainID(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+		userOverrides, err := parseUserOverrides(chainConfs[i].PerUserOverrides, tokens[ch.name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse per-user overrides: %w", err)
+		}
+
+		defaultUserLimits, err := parseLimitsConfig(chainConfs[i].UserLimits, tokens[ch.name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse user limits: %w", err)
+		}
+
+		tiers := make(map[string]map[common.Address]checker.Limit)
+		for tier, lc := range chainConfs[i].Tiers {
+			if tiers[tier], err = parseLimitsConfig(lc, tokens[ch.name]); err != nil {
+				return nil, fmt.Errorf("failed to parse limits of tier %s: %w", tier, err)
+			}
+		}
+
+		reserves, err := parseReserves(chainConfs[i].Limits, tokens[ch.name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse reserves: %w", err)
+		}
+
+		crossChainLimits, err := svc.crossChainLimits(ch.name, tokens)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cross-chain limits: %w", err)
+		}
+
+		checkerOpts := []checker.Option{
+			checker.WithCrossChainLimits(crossChainLimits...),
+			checker.WithRecipientLists(recipients),
+			checker.WithDefaultUserLimits(defaultUserLimits),
+			checker.WithLiquidity(reserves, liquiditySource{ch: ch}),
+		}
+		if len(tiers) > 0 {
+			checkerOpts = append(checkerOpts, checker.WithUserTiers(tiers, userTiers{conf: userTierConf, store: ch.store}))
+		}
+		if fiat := chainConfs[i].FiatLimits; fiat.Enabled() {
+			limits, err := parseFiatLimits(fiat)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse fiat limits: %w", err)
+			}
+			checkerOpts = append(checkerOpts, checker.WithFiatLimits(limits, newPriceSource(fiat.PriceSource)))
+		}
+		if anomaly != nil {
+			checkerOpts = append(checkerOpts, checker.WithAnomalyDetection(*anomaly))
+		}
+
+		ch.checker = checker.New(globalLimits, userOverrides, ch.store, checkerOpts...)
+		ch.policy, ch.builtinRules, err = newPolicy(ch.checker, conf.Policy.Actions, o.rules)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build policy: %w", err)
+		}
 	}
 
-	pk := strings.TrimPrefix(conf.Blockchain.PrivateKey, "0x")
-	key, err := crypto.HexToECDSA(pk)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	if err := svc.loadPause(); err != nil {
+		return nil, fmt.Errorf("failed to load pause state: %w", err)
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(key, chainID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create transactor: %w", err)
+	srv.Engine.GET("/health", svc.handleHealth)
+	svc.registerAdminRoutes(srv.Engine)
+	return svc, nil
+}
+
+// loadRecipientLists loads the configured denylist and allowlists.
+func (svc *Service) loadRecipientLists() (checker.RecipientLists, error) {
+	var lists checker.RecipientLists
+	conf := svc.Config.Recipients
+	if conf.Denylist != "" {
+		l, err := addrlist.Load(conf.Denylist)
+		if err != nil {
+			return lists, err
+		}
+		svc.Logger.Info("Loaded recipient denylist", "path", l.Path(), "addresses", l.Len())
+		lists.Denylist = l
+		svc.addressLists = append(svc.addressLists, l)
 	}
+	for token, path := range conf.Allowlists {
+		if !common.IsHexAddress(token) {
+			return lists, fmt.Errorf("invalid token address: %s", token)
+		}
+		l, err := addrlist.Load(path)
+		if err != nil {
+			return lists, err
+		}
+		svc.Logger.Info("Loaded recipient allowlist", "token", token, "path", l.Path(), "addresses", l.Len())
+		if lists.Allowlists == nil {
+			lists.Allowlists = make(map[common.Address]checker.AddressSet)
+		}
+		lists.Allowlists[common.HexToAddress(token)] = l
+		svc.addressLists = append(svc.addressLists, l)
+	}
+	return lists, nil
+}
+
+// crossChainLimits returns the cross-chain limits that apply to tokens of the
+// named chain. Limits in whole tokens require the tokens to have the same
+// decimals on every chain.
+func (svc *Service) crossChainLimits(chainName string, tokens map[string]*tokenResolver) ([]checker.CrossChainLimit, error) {
+	var limits []checker.CrossChainLimit
+	for _, lc := range svc.Config.CrossChainLimits {
+		token, ok := lc.Tokens[chainName]
+		if !ok {
+			continue
+		}
+		parsed, err := parseLimitsConfig(config.LimitsConfig{token: lc.LimitConfig}, tokens[chainName])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", lc.Name, err)
+		}
+		if lc.InTokens() {
+			if err := sameDecimals(lc, tokens); err != nil {
+				return nil, fmt.Errorf("%s: %w", lc.Name, err)
+			}
+		}
 
-	addr := common.HexToAddress(conf.Blockchain.ContractAddr)
+		limit := checker.CrossChainLimit{
+			Name:  lc.Name,
+			Token: common.HexToAddress(token),
+			Limit: parsed[common.HexToAddress(token)],
+		}
+		for _, ch := range svc.chains {
+			if t, ok := lc.Tokens[ch.name]; ok {
+				limit.Tokens = append(limit.Tokens, checker.ChainToken{Token: common.HexToAddress(t), Store: ch.store})
+			}
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
 
-	// Verify that the configured private key's address is authorized on the
-	// contract before starting. This catches misconfiguration early rather
-	// than failing on the first finalizeWithdraw/rejectWithdraw call.
-	if err := verifySigner(client, addr, auth.From, logger); err != nil {
-		return nil, err
+// sameDecimals checks that the tokens of a cross-chain limit have the same
+// decimals on every chain.
+func sameDecimals(lc config.CrossChainLimitConfig, tokens map[string]*tokenResolver) error {
+	var first string
+	var decimals uint8
+	for chainName, token := range lc.Tokens {
+		info, err := tokens[chainName].resolve(common.HexToAddress(token))
+		if err != nil {
+			return fmt.Errorf("chain %s: %w", chainName, err)
+		}
+		if first == "" {
+			first, decimals = chainName, info.decimals
+		} else if info.decimals != decimals {
+			return fmt.Errorf("token has %d decimals on chain %s but %d on chain %s", decimals, first, info.decimals, chainName)
+		}
+	}
+	return nil
+}
+
+// chainNamed returns the chain with the given name, or the only chain if name
+// is empty and there is just one.
+func (svc *Service) chainNamed(name string) *chain {
+	if name == "" && len(svc.chains) == 1 {
+		return svc.chains[0]
+	}
+	for _, ch := range svc.chains {
+		if ch.name == name {
+			return ch
+		}
+	}
+	return nil
+}
+
+// dialBackend connects to the configured RPC endpoints. Unreachable fallback
+// endpoints are dialed again when needed, as long as enough are reachable to
+// satisfy the quorum.
+func dialBackend(conf config.BlockchainConfig) (custody.EthBackend, error) {
+	urls := conf.RPCURLs()
+	if len(urls) == 1 {
+		client, err := ethclient.Dial(conf.RPCURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to Ethereum RPC: %w", err)
+		}
+		return client, nil
 	}
 
-	withdrawContract, err := custody.NewIWithdraw(addr, client)
+	dialers := make([]custody.Dialer, len(urls))
+	names := make([]string, len(urls))
+	for i, url := range urls {
+		dialers[i] = func(ctx context.Context) (custody.EthBackend, error) {
+			return ethclient.DialContext(ctx, url)
+		}
+		// Endpoints are named by position since URLs usually embed API keys.
+		names[i] = fmt.Sprintf("rpc-%d", i)
+	}
+	multi, err := custody.DialMultiBackend(context.Background(), dialers, names, conf.RPCQuorum)
 	if err != nil {
-		return nil, fmt.Errorf("failed to bind IWithdraw contract: %w", err)
+		return nil, fmt.Errorf("failed to connect to Ethereum RPC: %w", err)
 	}
+	return multi, nil
+}
 
-	listener := custody.NewListener(client, addr, conf.Blockchain.ConfirmationBlocks, conf.Blockchain.PollInterval, withdrawContract, nil)
+// Health returns nil while the worker is healthy, or the error of a stream
+// that failed and has not yet recovered.
+func (svc *Service) Health() error {
+	svc.healthMu.RLock()
+	defer svc.healthMu.RUnlock()
+	for _, err := range svc.degraded {
+		return err
+	}
+	return nil
+}
 
-	return &Service{
-		Config:    conf,
-		Logger:    logger,
-		web:       srv,
-		ethClient: client,
-		contract:  withdrawContract,
-		listener:  listener,
-		auth:      auth,
-		checker:   chk,
-		store:     db,
-	}, nil
+func (svc *Service) setDegraded(stream string, err error) {
+	svc.healthMu.Lock()
+	defer svc.healthMu.Unlock()
+	if svc.degraded == nil {
+		svc.degraded = make(map[string]error)
+	}
+	svc.degraded[stream] = fmt.Errorf("%s: %w", stream, err)
+	healthyGauge.Set(0)
+}
+
+func (svc *Service) setHealthy(stream string) {
+	svc.healthMu.Lock()
+	defer svc.healthMu.Unlock()
+	delete(svc.degraded, stream)
+	if len(svc.degraded) == 0 {
+		healthyGauge.Set(1)
+	}
+}
+
+func (svc *Service) handleHealth(c *gin.Context) {
+	if err := svc.Health(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "degraded", "error": err.Error()})
+		return
+	}
+	if !svc.IsWorkerReady() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "starting"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
 func (svc *Service) IsWorkerReady() bool {
@@ -177,38 +432,55 @@ func (svc *Service) RunWorkerWithContext(ctx context.Context) error {
 		return svc.web.Run()
 	})
 
-	g.Go(func() error {
-		fromBlock, fromLogIdx, err := svc.store.GetCursor("withdraw_started")
-		if err != nil {
-			svc.Logger.Warn("Failed to read withdraw_started cursor, starting from head", "error", err)
-			// If cursor is missing, we default to 0. But if StartBlock is configured, we should use that.
-		}
-		if fromBlock == 0 && svc.Config.Blockchain.StartBlock > 0 {
-			fromBlock = svc.Config.Blockchain.StartBlock
-		}
-
-		svc.Logger.Info("Starting WithdrawStarted event watcher", "from_block", fromBlock, "from_log_index", fromLogIdx)
-		withdrawals := make(chan *custody.WithdrawStartedEvent)
-		go svc.listener.WatchWithdrawStarted(ctx, withdrawals, fromBlock, fromLogIdx)
-		for event := range withdrawals {
-			svc.processWithdrawal(ctx, event)
-		}
-		return nil
-	})
+	for _, ch := range svc.chains {
+		g.Go(func() error {
+			return ch.superviseWithdrawStream(ctx)
+		})
+
+		g.Go(func() error {
+			ch.logger.Info("Starting deferred rejection processor")
+			ticker := time.NewTicker(5 * time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+					ch.processDeferredRejections(ctx)
+				}
+			}
+		})
 
-	g.Go(func() error {
-		svc.Logger.Info("Starting deferred rejection processor")
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return nil
-			case <-ticker.C:
-				svc.processDeferredRejections(ctx)
+		g.Go(func() error {
+			ch.logger.Info("Starting held withdrawal processor")
+			interval := svc.Config.Review.CheckInterval
+			if interval <= 0 {
+				interval = 30 * time.Second
 			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				case <-ch.wake:
+				}
+				ch.processHolds(ctx)
+			}
+		})
+	}
+
+	if len(svc.addressLists) > 0 {
+		interval := svc.Config.Recipients.ReloadInterval
+		if interval <= 0 {
+			interval = 10 * time.Second
 		}
-	})
+		g.Go(func() error {
+			addrlist.Watch(ctx, interval, svc.Logger, svc.addressLists...)
+			return nil
+		})
+	}
 
 	g.Go(func() error {
 		<-ctx.Done()
@@ -220,241 +492,160 @@ func (svc *Service) RunWorkerWithContext(ctx context.Context) error {
 
 	g.Go(func() error {
 		<-ctx.Done()
-		svc.Logger.Info("Closing Ethereum client")
-		svc.ethClient.Close()
+		for _, ch := range svc.chains {
+			ch.logger.Info("Closing Ethereum client")
+			ch.ethClient.Close()
+		}
 		return nil
 	})
 
+	healthyGauge.Set(1)
 	svc.setWorkerReady()
 	svc.Logger.Info("Worker started")
 
 	return g.Wait()
 }
 
-func (svc *Service) processWithdrawal(ctx context.Context, event *custody.WithdrawStartedEvent) {
-	wID := common.Hash(event.WithdrawalID).Hex()
-	logger := svc.Logger.With(
-		"withdrawal_id", wID,
-		"user", event.User.Hex(),
-		"token", event.Token.Hex(),
-		"amount", event.Amount,
-	)
-
-	if svc.store.HasWithdrawEvent(wID) {
-		logger.Info("Event already processed, skipping")
-		return
-	}
-
-	logger.Info("Processing withdrawal request")
-
-	baseModel := store.WithdrawEventModel{
-		WithdrawalID: wID,
-		UserAddress:  event.User.Hex(),
-		TokenAddress: event.Token.Hex(),
-		Amount:       event.Amount.String(),
-		BlockNumber:  event.BlockNumber,
-		TxHash:       event.TxHash.Hex(),
-		LogIndex:     uint(event.LogIndex),
-	}
-
-	if err := svc.checker.Check(event.User, event.Token, event.Amount); err != nil {
-		logger.Warn("Withdrawal blocked by policy, rejecting", "reason", err)
+// parseLimitsConfig converts limits to base units, reading the decimals and
+// symbol of tokens whose limits are in whole tokens from the chain.
+func parseLimitsConfig(lc config.LimitsConfig, tokens *tokenResolver) (map[common.Address]checker.Limit, error) {
+	limits := make(map[common.Address]checker.Limit)
+	for addrStr, conf := range lc {
+		if !common.IsHexAddress(addrStr) {
+			return nil, fmt.Errorf("invalid address: %s", addrStr)
+		}
+		addr := common.HexToAddress(addrStr)
 
-		txAuth := *svc.auth
-		txAuth.Context = ctx
-		tx, txErr := svc.contract.RejectWithdraw(&txAuth, event.WithdrawalID)
-		if txErr != nil {
-			// Rejection may fail if the contract requires expiry (ThresholdCustody).
-			// Schedule a deferred retry.
-			logger.Warn("Immediate reject failed, deferring until expiry", "error", txErr)
-			pending := &store.PendingRejectionModel{
-				WithdrawalID: wID,
-				Reason:       err.Error(),
+		parseAmount, err := tokens.amountParser(addr, conf)
+		if err != nil {
+			return nil, err
+		}
+		l := checker.Limit{}
+		if conf.Hourly != "" {
+			if l.Hourly, err = parseAmount(conf.Hourly); err != nil {
+				return nil, fmt.Errorf("invalid hourly limit for %s: %w", addrStr, err)
 			}
-			if dbErr := svc.store.SavePendingRejection(pending); dbErr != nil {
-				logger.Error("Failed to save pending rejection", "error", dbErr)
+		}
+		if conf.Daily != "" {
+			if l.Daily, err = parseAmount(conf.Daily); err != nil {
+				return nil, fmt.Errorf("invalid daily limit for %s: %w", addrStr, err)
 			}
-			baseModel.Decision = "rejected"
-			baseModel.Reason = err.Error()
-			svc.recordEvent(logger, &baseModel)
-			return
-		}
-
-		logger.Info("Sent reject transaction", "tx_hash", tx.Hash().Hex())
-		receipt, txErr := bind.WaitMined(ctx, svc.ethClient, tx)
-		if txErr != nil {
-			logger.Error("Failed waiting for reject tx to be mined", "error", txErr)
-			baseModel.Decision = "error"
-			baseModel.Reason = fmt.Sprintf("reject tx mining failed: %v", txErr)
-			svc.recordEvent(logger, &baseModel)
-			return
-		}
-
-		if receipt.Status == 1 {
-			baseModel.Decision = "rejected"
-			baseModel.Reason = err.Error()
-		} else {
-			// On-chain revert (e.g. WithdrawalNotExpired). Defer the rejection.
-			logger.Warn("Reject tx reverted on-chain, deferring until expiry")
-			pending := &store.PendingRejectionModel{
-				WithdrawalID: wID,
-				Reason:       err.Error(),
+		}
+		for _, w := range conf.Windows {
+			period, err := config.ParsePeriod(w.Period)
+			if err != nil {
+				return nil, fmt.Errorf("limit window for %s: %w", addrStr, err)
 			}
-			if dbErr := svc.store.SavePendingRejection(pending); dbErr != nil {
-				logger.Error("Failed to save pending rejection", "error", dbErr)
+			val, err := parseAmount(w.Max)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s limit for %s: %w", w.Period, addrStr, err)
 			}
-			baseModel.Decision = "rejected"
-			baseModel.Reason = err.Error()
+			l.Windows = append(l.Windows, checker.WindowLimit{Period: period, Max: val})
 		}
-		svc.recordEvent(logger, &baseModel)
-		return
-	}
-
-	txAuth := *svc.auth
-	txAuth.Context = ctx
-
-	tx, err := svc.contract.FinalizeWithdraw(&txAuth, event.WithdrawalID)
-	if err != nil {
-		logger.Error("Failed to finalize withdrawal", "error", err)
-		baseModel.Decision = "error"
-		baseModel.Reason = fmt.Sprintf("finalize tx failed: %v", err)
-		svc.recordEvent(logger, &baseModel)
-		return
-	}
-
-	logger.Info("Sent finalize transaction", "tx_hash", tx.Hash().Hex())
-
-	receipt, err := bind.WaitMined(ctx, svc.ethClient, tx)
-	if err != nil {
-		logger.Error("Transaction mining failed", "error", err)
-		baseModel.Decision = "error"
-		baseModel.Reason = fmt.Sprintf("finalize tx mining failed: %v", err)
-		svc.recordEvent(logger, &baseModel)
-		return
-	}
-
-	if receipt.Status != 1 {
-		logger.Error("Withdrawal finalization tx reverted")
-		baseModel.Decision = "error"
-		baseModel.Reason = "finalize tx reverted on-chain"
-		svc.recordEvent(logger, &baseModel)
-		return
-	}
-
-	// Check receipt logs for WithdrawFinalized event to confirm actual execution.
-	// In ThresholdCustody, finalizeWithdraw adds an approval; the withdrawal only
-	// executes when the threshold is met and emits WithdrawFinalized.
-	executed := false
-	for _, log := range receipt.Logs {
-		finalized, parseErr := svc.contract.ParseWithdrawFinalized(*log)
-		if parseErr != nil {
-			continue
+		if l.Count, err = parseCountLimitConfig(conf.Count); err != nil {
+			return nil, fmt.Errorf("count limit for %s: %w", addrStr, err)
 		}
-		if finalized.WithdrawalId == event.WithdrawalID && finalized.Success {
-			executed = true
-			break
+		if l.UserCount, err = parseCountLimitConfig(conf.UserCount); err != nil {
+			return nil, fmt.Errorf("user count limit for %s: %w", addrStr, err)
 		}
-	}
-
-	if executed {
-		logger.Info("Withdrawal finalized successfully on-chain")
-
-		record := &custody.Withdrawal{
-			WithdrawalID: event.WithdrawalID,
-			User:         event.User,
-			Token:        event.Token,
-			Amount:       event.Amount,
-			BlockNumber:  receipt.BlockNumber.Uint64(),
-			TxHash:       tx.Hash(),
-			Timestamp:    time.Now(),
-		}
-		if err := svc.checker.Record(record); err != nil {
-			logger.Error("Failed to record withdrawal in DB", "error", err)
+		switch conf.Window {
+		case "", config.LimitWindowCalendar:
+			l.Window = checker.WindowCalendar
+		case config.LimitWindowRolling:
+			l.Window = checker.WindowRolling
+		default:
+			return nil, fmt.Errorf("invalid limit window for %s: %s", addrStr, conf.Window)
 		}
-
-		baseModel.Decision = "approved"
-		svc.recordEvent(logger, &baseModel)
-	} else {
-		logger.Info("Approval recorded on-chain, threshold not yet met")
-		baseModel.Decision = "pending"
-		baseModel.Reason = "approval added, awaiting threshold"
-		svc.recordEvent(logger, &baseModel)
+		limits[addr] = l
 	}
+	return limits, nil
 }
 
-func (svc *Service) processDeferredRejections(ctx context.Context) {
-	pending, err := svc.store.GetPendingRejections()
-	if err != nil {
-		svc.Logger.Error("Failed to get pending rejections", "error", err)
-		return
+func parseFiatLimits(conf config.FiatLimitsConfig) (checker.FiatLimits, error) {
+	limits := checker.FiatLimits{
+		Currency: conf.Currency,
+		Tokens:   make(map[common.Address]uint8),
+		Window:   checker.WindowCalendar,
 	}
-
-	for _, p := range pending {
-		logger := svc.Logger.With("withdrawal_id", p.WithdrawalID, "reason", p.Reason)
-
-		var wID [32]byte
-		copy(wID[:], common.FromHex(p.WithdrawalID))
-
-		txAuth := *svc.auth
-		txAuth.Context = ctx
-		tx, txErr := svc.contract.RejectWithdraw(&txAuth, wID)
-		if txErr != nil {
-			// Will retry on next tick; may still be before expiry
-			logger.Warn("Deferred reject tx failed (may not be expired yet)", "error", txErr)
-			continue
+	for addrStr, token := range conf.Tokens {
+		if !common.IsHexAddress(addrStr) {
+			return checker.FiatLimits{}, fmt.Errorf("invalid address: %s", addrStr)
 		}
-
-		logger.Info("Sent deferred reject transaction", "tx_hash", tx.Hash().Hex())
-		receipt, txErr := bind.WaitMined(ctx, svc.ethClient, tx)
-		if txErr != nil {
-			logger.Error("Deferred reject tx mining failed", "error", txErr)
-			continue
+		if token.Decimals == nil {
+			return checker.FiatLimits{}, fmt.Errorf("missing decimals for %s", addrStr)
 		}
+		limits.Tokens[common.HexToAddress(addrStr)] = *token.Decimals
+	}
+	if conf.Window == config.LimitWindowRolling {
+		limits.Window = checker.WindowRolling
+	}
 
-		if receipt.Status == 1 {
-			logger.Info("Deferred rejection finalized on-chain")
-		} else {
-			logger.Warn("Deferred rejection tx reverted (may already be finalized)")
-		}
+	var err error
+	if limits.FiatLimit, err = parseFiatLimit(conf.FiatLimitConfig); err != nil {
+		return checker.FiatLimits{}, err
+	}
+	if limits.PerUser, err = parseFiatLimit(conf.PerUser); err != nil {
+		return checker.FiatLimits{}, fmt.Errorf("per-user: %w", err)
+	}
+	return limits, nil
+}
 
-		if err := svc.store.CompletePendingRejection(p.WithdrawalID); err != nil {
-			logger.Error("Failed to mark pending rejection as completed", "error", err)
+func parseFiatLimit(conf config.FiatLimitConfig) (checker.FiatLimit, error) {
+	var l checker.FiatLimit
+	if conf.Hourly != "" {
+		val, ok := new(big.Rat).SetString(conf.Hourly)
+		if !ok {
+			return l, fmt.Errorf("invalid hourly limit: %s", conf.Hourly)
 		}
+		l.Hourly = val
 	}
+	if conf.Daily != "" {
+		val, ok := new(big.Rat).SetString(conf.Daily)
+		if !ok {
+			return l, fmt.Errorf("invalid daily limit: %s", conf.Daily)
+		}
+		l.Daily = val
+	}
+	return l, nil
 }
 
-func (svc *Service) recordEvent(logger *slog.Logger, ev *store.WithdrawEventModel) {
-	if err := svc.store.RecordWithdrawEvent(ev); err != nil {
-		logger.Error("Failed to record withdraw event", "error", err)
+func newPriceSource(conf config.PriceSourceConfig) checker.PriceSource {
+	opts := price.Options{RefreshInterval: conf.RefreshInterval, MaxAge: conf.MaxAge}
+	if conf.URL != "" {
+		return price.NewHTTP(conf.URL, nil, opts)
 	}
+	return price.NewFile(conf.File, opts)
 }
 
-func parseLimitsConfig(lc config.LimitsConfig) (map[common.Address]checker.Limit, error) {
-	limits := make(map[common.Address]checker.Limit)
-	for addrStr, conf := range lc {
-		if !common.IsHexAddress(addrStr) {
-			return nil, fmt.Errorf("invalid address: %s", addrStr)
-		}
-		addr := common.HexToAddress(addrStr)
+func parseAnomalyConfig(conf config.AnomalyConfig) (checker.AnomalyDetection, error) {
+	lookback, err := config.ParsePeriod(conf.Lookback)
+	if err != nil {
+		return checker.AnomalyDetection{}, err
+	}
+	window, err := config.ParsePeriod(conf.FrequencyWindow)
+	if err != nil {
+		return checker.AnomalyDetection{}, err
+	}
+	return checker.AnomalyDetection{
+		Lookback:        lookback,
+		MinHistory:      conf.MinHistory,
+		AmountFactor:    conf.AmountFactor,
+		FrequencyFactor: conf.FrequencyFactor,
+		FrequencyWindow: window,
+		NewToken:        conf.NewToken,
+	}, nil
+}
 
-		l := checker.Limit{}
-		if conf.Hourly != "" {
-			val, ok := new(big.Int).SetString(conf.Hourly, 10)
-			if !ok {
-				return nil, fmt.Errorf("invalid hourly limit for %s: %s", addrStr, conf.Hourly)
-			}
-			l.Hourly = val
-		}
-		if conf.Daily != "" {
-			val, ok := new(big.Int).SetString(conf.Daily, 10)
-			if !ok {
-				return nil, fmt.Errorf("invalid daily limit for %s: %s", addrStr, conf.Daily)
-			}
-			l.Daily = val
+func parseCountLimitConfig(conf config.CountLimitConfig) (checker.CountLimit, error) {
+	l := checker.CountLimit{Hourly: conf.Hourly, Daily: conf.Daily}
+	for _, w := range conf.Windows {
+		period, err := config.ParsePeriod(w.Period)
+		if err != nil {
+			return checker.CountLimit{}, err
 		}
-		limits[addr] = l
+		l.Windows = append(l.Windows, checker.WindowCount{Period: period, Max: w.Max})
 	}
-	return limits, nil
+	return l, nil
 }
 
 // verifySigner checks that signerAddr is authorized on the custody contract
@@ -500,14 +691,14 @@ func isContractRevert(err error) bool {
 	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == 3
 }
 
-func parseUserOverrides(overrides map[string]config.LimitsConfig) (map[common.Address]map[common.Address]checker.Limit, error) {
+func parseUserOverrides(overrides map[string]config.LimitsConfig, tokens *tokenResolver) (map[common.Address]map[common.Address]checker.Limit, error) {
 	result := make(map[common.Address]map[common.Address]checker.Limit)
 	for userAddrStr, tokenLimits := range overrides {
 		if !common.IsHexAddress(userAddrStr) {
 			return nil, fmt.Errorf("invalid user address in per_user_overrides: %s", userAddrStr)
 		}
 		userAddr := common.HexToAddress(userAddrStr)
-		parsed, err := parseLimitsConfig(tokenLimits)
+		parsed, err := parseLimitsConfig(tokenLimits, tokens)
 		if err != nil {
 			return nil, fmt.Errorf("per-user overrides for %s: %w", userAddrStr, err)
 		}
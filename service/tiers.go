@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"github.com/layer-3/nitewatch/internal/store"
+)
+
+var (
+	// ErrUnknownTier is returned when assigning a user to a tier that no
+	// chain configures.
+	ErrUnknownTier = errors.New("unknown tier")
+	// ErrTierInConfig is returned when changing the tier of a user that is
+	// assigned one in user_tiers, which takes precedence.
+	ErrTierInConfig = errors.New("tier is assigned in the configuration")
+)
+
+// userTiers assigns users to tiers by user_tiers, else by the assignments
+// stored in the database of the chain.
+type userTiers struct {
+	conf  map[common.Address]string
+	store *store.Adapter
+}
+
+func (t userTiers) UserTier(user common.Address) (string, error) {
+	if tier, ok := t.conf[user]; ok {
+		return tier, nil
+	}
+	return t.store.UserTier(user)
+}
+
+// parseUserTiers parses the user_tiers configuration.
+func parseUserTiers(conf map[string]string) (map[common.Address]string, error) {
+	tiers := make(map[common.Address]string, len(conf))
+	for user, tier := range conf {
+		if !common.IsHexAddress(user) {
+			return nil, fmt.Errorf("invalid user address in user_tiers: %s", user)
+		}
+		tiers[common.HexToAddress(user)] = tier
+	}
+	return tiers, nil
+}
+
+// UserTier is the API representation of the tier a user is assigned to.
+// Source is "config" for user_tiers and "database" for assignments made
+// through the API, which are stored on every chain.
+type UserTier struct {
+	Chain  string `json:"chain,omitempty"`
+	User   string `json:"user"`
+	Tier   string `json:"tier"`
+	Source string `json:"source"`
+}
+
+type tierRequest struct {
+	Tier string `json:"tier" binding:"required,max=64"`
+}
+
+func (svc *Service) handleListTiers(c *gin.Context) {
+	resp := []UserTier{}
+	for user, tier := range svc.Config.UserTiers {
+		resp = append(resp, UserTier{User: common.HexToAddress(user).Hex(), Tier: tier, Source: "config"})
+	}
+	for _, ch := range svc.chains {
+		tiers, err := ch.store.ListUserTiers()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, t := range tiers {
+			resp = append(resp, UserTier{Chain: ch.name, User: t.UserAddress, Tier: t.Tier, Source: "database"})
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (svc *Service) handleSetTier(c *gin.Context) {
+	var req tierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tier is required"})
+		return
+	}
+	svc.respondTier(c, req.Tier)
+}
+
+func (svc *Service) handleDeleteTier(c *gin.Context) { svc.respondTier(c, "") }
+
+func (svc *Service) respondTier(c *gin.Context, tier string) {
+	user := c.Param("user")
+	if !common.IsHexAddress(user) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user address"})
+		return
+	}
+	err := svc.SetUserTier(common.HexToAddress(user), tier)
+	switch {
+	case errors.Is(err, ErrUnknownTier):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTierInConfig):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"user": common.HexToAddress(user).Hex(), "tier": tier})
+	}
+}
+
+// SetUserTier assigns user to tier on every chain, or removes its assignment
+// if tier is empty. Users assigned a tier in user_tiers cannot be changed.
+func (svc *Service) SetUserTier(user common.Address, tier string) error {
+	for u := range svc.Config.UserTiers {
+		if common.HexToAddress(u) == user {
+			return fmt.Errorf("%w: %s", ErrTierInConfig, user.Hex())
+		}
+	}
+	if tier != "" && !svc.hasTier(tier) {
+		return fmt.Errorf("%w: %q", ErrUnknownTier, tier)
+	}
+	for _, ch := range svc.chains {
+		if err := ch.store.SetUserTier(user, tier); err != nil {
+			return fmt.Errorf("chain %s: %w", ch.name, err)
+		}
+	}
+	return nil
+}
+
+// hasTier reports whether any chain configures limits for tier.
+func (svc *Service) hasTier(tier string) bool {
+	for _, chain := range svc.Config.ChainConfigs() {
+		if _, ok := chain.Tiers[tier]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/layer-3/nitewatch/config"
+	"github.com/layer-3/nitewatch/custody"
+)
+
+// erc20ABI is the part of the ERC20 interface the service needs.
+const erc20ABI = `[
+	{"type":"function","name":"decimals","inputs":[],"outputs":[{"name":"","type":"uint8"}],"stateMutability":"view"},
+	{"type":"function","name":"symbol","inputs":[],"outputs":[{"name":"","type":"string"}],"stateMutability":"view"},
+	{"type":"function","name":"balanceOf","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}],"stateMutability":"view"}
+]`
+
+var erc20 = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(erc20ABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// tokenInfo is what amounts in whole tokens are converted with.
+type tokenInfo struct {
+	decimals uint8
+	symbol   string
+}
+
+// tokenResolver reads the decimals and symbol of tokens from a chain, once per
+// token.
+type tokenResolver struct {
+	client bind.ContractCaller
+	tokens map[common.Address]tokenInfo
+}
+
+func newTokenResolver(client bind.ContractCaller) *tokenResolver {
+	return &tokenResolver{client: client, tokens: make(map[common.Address]tokenInfo)}
+}
+
+// resolve returns the decimals and symbol of token. The native token has 18
+// decimals and symbol ETH.
+func (r *tokenResolver) resolve(token common.Address) (tokenInfo, error) {
+	if info, ok := r.tokens[token]; ok {
+		return info, nil
+	}
+	info := tokenInfo{decimals: 18, symbol: "ETH"}
+	if token != (common.Address{}) {
+		contract := bind.NewBoundContract(token, erc20, r.client, nil, nil)
+		var out []any
+		if err := contract.Call(&bind.CallOpts{}, &out, "decimals"); err != nil {
+			return tokenInfo{}, fmt.Errorf("failed to read decimals of %s: %w", token.Hex(), err)
+		}
+		info.decimals = *abi.ConvertType(out[0], new(uint8)).(*uint8)
+		out = nil
+		if err := contract.Call(&bind.CallOpts{}, &out, "symbol"); err != nil {
+			return tokenInfo{}, fmt.Errorf("failed to read symbol of %s: %w", token.Hex(), err)
+		}
+		info.symbol = *abi.ConvertType(out[0], new(string)).(*string)
+	}
+	r.tokens[token] = info
+	return info, nil
+}
+
+// amountParser returns the function that converts the amounts of lim to base
+// units of token. Declared decimals and symbol must match those of the token
+// contract; the native token takes them as declared.
+func (r *tokenResolver) amountParser(token common.Address, lim config.LimitConfig) (func(string) (*big.Int, error), error) {
+	if !lim.InTokens() {
+		return func(s string) (*big.Int, error) {
+			v, ok := new(big.Int).SetString(s, 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid amount %q", s)
+			}
+			return v, nil
+		}, nil
+	}
+	info, err := r.resolve(token)
+	if err != nil {
+		return nil, err
+	}
+	native := token == (common.Address{})
+	if lim.Decimals != nil && *lim.Decimals != info.decimals {
+		if !native {
+			return nil, fmt.Errorf("declared decimals %d of %s do not match the token's %d", *lim.Decimals, token.Hex(), info.decimals)
+		}
+		info.decimals = *lim.Decimals
+	}
+	if lim.Symbol != "" && !strings.EqualFold(lim.Symbol, info.symbol) {
+		if !native {
+			return nil, fmt.Errorf("declared symbol %s of %s does not match the token's %s", lim.Symbol, token.Hex(), info.symbol)
+		}
+		info.symbol = lim.Symbol
+	}
+	return func(s string) (*big.Int, error) {
+		return config.ParseAmount(s, info.decimals, info.symbol)
+	}, nil
+}
+
+// tokenBalance returns the balance of token held by account, in base units.
+func tokenBalance(ctx context.Context, client custody.EthBackend, token, account common.Address) (*big.Int, error) {
+	if token == (common.Address{}) {
+		return client.BalanceAt(ctx, account, nil)
+	}
+	var out []any
+	contract := bind.NewBoundContract(token, erc20, client, nil, nil)
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &out, "balanceOf", account); err != nil {
+		return nil, fmt.Errorf("failed to read balance of %s: %w", token.Hex(), err)
+	}
+	return abi.ConvertType(out[0], new(big.Int)).(*big.Int), nil
+}